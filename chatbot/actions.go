@@ -0,0 +1,217 @@
+package chatbot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+// scheduleTimes describes when news of schedules is sent
+var scheduleTimes = map[string]string{
+	models.ScheduleRealtime: "as it happens",
+	models.ScheduleMorning:  "every morning",
+	models.ScheduleEvening:  "every evening",
+	models.ScheduleWeekly:   "every week",
+}
+
+// processAction runs the action resolved from the user's input
+func (b *Chatbot) processAction(st *Statement, action string, params utils.Map) {
+	if params == nil {
+		params = utils.Map{}
+	}
+	switch action {
+	case utils.ActionNewsSearch:
+
+		b.Logger.Debug("Processing news search action")
+		params["page"] = 1
+
+		b.searchNews(st, params, 1)
+
+	case utils.ActionNewsSearchNext:
+
+		b.Logger.Debug("Processing next news search action")
+		params, page := b.lastSearch(st.UserID)
+		b.searchNews(st, params, page+1)
+
+	case utils.ActionNewsSearchPrevious:
+
+		b.Logger.Debug("Processing previous news search action")
+		params, page := b.lastSearch(st.UserID)
+		b.searchNews(st, params, page-1)
+
+	case utils.ActionNewsSearchRepeat:
+
+		b.Logger.Debug("Processing repeat news search action")
+		params, page := b.lastSearch(st.UserID)
+		b.searchNews(st, params, page)
+
+	case utils.ActionStop:
+
+		b.Logger.Debug("Processing stop subscription action")
+		if topic, ok := params["topic"]; ok {
+			b.stopSubscription(st.UserID, topic.(string))
+		}
+
+	case utils.ActionReset:
+
+		b.Logger.Debug("Processing reset subscription action")
+		reply := utils.NewSubscribeMenu(st.UserID)
+		st.AddResponse(reply)
+
+	case utils.ActionTopics:
+
+		b.Logger.Debug("Processing topics list action")
+		reply := utils.NewQuickReply(st.UserID, "Here are some options ⬇️")
+		topics := models.GetUnsubscribedTopics(st.UserID, 5)
+		for _, topic := range topics {
+			reply.AddTextQuickReply(topic.Name, topic.Name)
+		}
+		st.AddResponse(reply)
+
+	case utils.ActionSubscribe:
+
+		b.Logger.Debug("Processing subscribe action")
+		if topic, ok := params["topic"]; ok {
+			b.subscribe(st.UserID, topic.(string))
+			// ask for permission to send alerts outside the messaging window
+			title := fmt.Sprintf(utils.NotifyTitle, topic)
+			st.AddResponse(utils.NewNotificationRequest(st.UserID, title, utils.NotifyPayload+topic.(string), utils.NotificationFrequencyDaily))
+		}
+
+		if subs, err := models.GetUserSubscriptions(st.UserID); err == nil && len(subs) > 1 {
+			break
+		}
+		reply := utils.NewQuickReply(st.UserID, "Do you want to subscribe to anything else?")
+		reply.AddTextQuickReply("No, thanks!", "No, thanks!")
+		reply.AddTextQuickReply("Other topics", "Other topics")
+		st.AddResponse(reply)
+
+	case utils.ActionSchedule:
+
+		b.Logger.Debug("Processing subscription schedule action")
+		schedule, _ := params.Get("schedule", "").(string)
+		if !models.IsSchedule(schedule) {
+			reply := utils.NewQuickReply(st.UserID, utils.ScheduleText)
+			for _, s := range models.Schedules {
+				reply.AddTextQuickReply(strings.Title(s), s)
+			}
+			st.AddResponse(reply)
+			break
+		}
+		topic, _ := params.Get("topic", "").(string)
+		if b.setSchedule(st.UserID, topic, schedule) == 0 {
+			st.AddTextResponse(utils.NoSubscriptionText)
+			st.AddResponse(utils.NewSubscribeMenu(st.UserID))
+			break
+		}
+		st.AddTextResponse(fmt.Sprintf(utils.ScheduleSetText, scheduleTimes[schedule]))
+
+	case utils.ActionManageAlerts:
+		b.Logger.Debug("Processing alerts action")
+		if subs, err := models.GetUserSubscriptions(st.UserID); err == nil && len(subs) > 0 {
+			gm := utils.NewGenericMessage(st.UserID)
+			for _, sub := range subs {
+				gm.AddNewElement(sub.Topic.Name, sub.Description(), "", "", sub.StopButton())
+			}
+			st.AddResponse(gm)
+			break
+		}
+		st.AddTextResponse(utils.NoSubscriptionText)
+		st.AddResponse(utils.NewSubscribeMenu(st.UserID))
+	default:
+		b.Logger.Debug("Processing default action")
+	}
+
+}
+
+func (b *Chatbot) subscribe(userID, topic string) {
+	sub := models.NewSubscription(userID, topic)
+	sub.Save()
+}
+
+func (b *Chatbot) stopSubscription(userID, topic string) {
+	subs, _ := models.GetUserSubscriptions(userID)
+
+	for _, sub := range subs {
+		if topic == sub.Topic.Name {
+			sub.Delete()
+		} else if topic == "" {
+			sub.Delete()
+		}
+	}
+}
+
+// setSchedule sets delivery schedule of subscriptions of user to topic,
+// all subscriptions when topic is empty, it returns number of changes
+func (b *Chatbot) setSchedule(userID, topic, schedule string) int {
+	subs, _ := models.GetUserSubscriptions(userID)
+
+	n := 0
+	for _, sub := range subs {
+		if topic != "" && topic != sub.Topic.Name {
+			continue
+		}
+		sub.Schedule = schedule
+		sub.Save()
+		n++
+	}
+	return n
+}
+
+func (b *Chatbot) searchNews(st *Statement, params utils.Map, page int) error {
+	// get news articles
+	articles, err := models.SearchArticle(params, page)
+	if err != nil {
+		b.Logger.Error("News search error:", err)
+		return err
+	}
+	if len(articles) < 1 {
+		return fmt.Errorf("No articles found")
+	}
+	// create generic message for news articles
+	gm := utils.NewGenericMessage(st.UserID)
+	for _, article := range articles {
+		gm.AddElement(article.ToStoryElement(st.UserID))
+	}
+	st.AddResponse(gm)
+
+	// add quick replies
+	cat := params.Get("category", "").(string)
+	reply := utils.NewQuickReply(st.UserID, "You can view more of this news or other topics")
+	reply.AddTextQuickReply("Show me more", "Show me more")
+	topics := models.GetUnsubscribedTopics(st.UserID, 4)
+	for _, topic := range topics {
+		if topic.Name == cat {
+			continue
+		}
+		txt := strings.Title(topic.Name) + " news"
+		reply.AddTextQuickReply(txt, txt)
+	}
+	st.AddResponse(reply)
+
+	// update pagination param
+	params.Set("page", page)
+	if err := b.Sessions.Set(st.UserID, params); err != nil {
+		b.Logger.Error("Session error:", err)
+	}
+
+	return nil
+}
+
+// lastSearch returns params and page of the last news search of user
+func (b *Chatbot) lastSearch(userID string) (utils.Map, int) {
+	params, err := b.Sessions.Get(userID)
+	if err != nil {
+		b.Logger.Error("Session error:", err)
+	}
+	// numbers of persisted sessions are decoded as float64
+	switch page := params.Get("page", 1).(type) {
+	case int:
+		return params, page
+	case float64:
+		return params, int(page)
+	}
+	return params, 1
+}
@@ -0,0 +1,72 @@
+package chatbot
+
+import (
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+// AttachmentLogic logic adapter that responds to images,
+// audio, locations and shared links
+type AttachmentLogic struct {
+	bot *Chatbot
+}
+
+// NewAttachmentLogic returns new AttachmentLogic
+func NewAttachmentLogic() *AttachmentLogic {
+	return &AttachmentLogic{}
+}
+
+func (l *AttachmentLogic) setChatbot(b *Chatbot) {
+	l.bot = b
+}
+
+func (l *AttachmentLogic) canProcess(s *Statement) bool {
+	return len(s.Attachments) > 0
+}
+
+// Process responds to the first attachment of the statement, shared
+// links of crawled articles are answered with the article
+func (l *AttachmentLogic) Process(st *Statement) *Statement {
+	l.bot.Logger.Debug("Using attachment logic")
+	st.SetScore(1)
+
+	// respond to the first attachment only, messenger sends
+	// several attachments of the same type at once
+	a := st.Attachments[0]
+	switch a.Type {
+	case AttachmentLink:
+		l.bot.Logger.Debugf("Processing shared link %s", a.URL)
+
+		article, err := models.GetArticleByLink(a.URL)
+		if err != nil {
+			st.AddTextResponse(utils.UnknownLinkText)
+			break
+		}
+		st.AddTextResponse(utils.SharedLinkText)
+		gm := utils.NewGenericMessage(st.UserID)
+		gm.AddElement(article.ToMessengerElement(st.UserID))
+		st.AddResponse(gm)
+
+	case AttachmentLocation:
+		l.bot.Logger.Debugf("Processing location %v,%v", a.Lat, a.Long)
+
+		st.AddTextResponse(utils.LocationText)
+		st.SetAction(utils.ActionNewsSearch, utils.Map{})
+
+	case AttachmentImage:
+		l.bot.Logger.Debug("Processing image")
+
+		st.AddTextResponse(utils.ImageText)
+		st.AddResponse(utils.NewSubscribeMenu(st.UserID))
+
+	case AttachmentAudio:
+		l.bot.Logger.Debug("Processing audio")
+
+		st.AddTextResponse(utils.AudioText)
+
+	default:
+		l.bot.Logger.Debugf("Unsupported attachment: %s", a.Type)
+		st.SetScore(0)
+	}
+	return st
+}
@@ -0,0 +1,55 @@
+package chatbot
+
+import (
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAttachmentLogic(t *testing.T) {
+	assert := assert.New(t)
+	l := NewAttachmentLogic()
+	l.setChatbot(ch)
+
+	st := NewStatement("", user.ID)
+	assert.False(l.canProcess(st))
+
+	pub := time.Now()
+	link := "https://" + fake.DomainName() + "/news/1"
+	article := models.NewArticle(fake.SentencesN(1), fake.Characters(), fake.SentencesN(2), link, fake.DomainName(), link, &pub, []string{})
+	article.SetTopic(fake.Word(), []string{})
+	article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(5), fake.SentencesN(2)))
+	article.Save()
+
+	st.AddAttachment(&Attachment{Type: AttachmentLink, URL: link})
+	assert.True(l.canProcess(st))
+	res := l.Process(st)
+	assert.Equal(float32(1), res.Score)
+	assert.Len(res.Responses, 2)
+	assert.Contains(res.SerializeResponse(), article.ID)
+
+	st = NewStatement("", user.ID)
+	st.AddAttachment(&Attachment{Type: AttachmentLink, URL: link + "/unknown"})
+	res = l.Process(st)
+	assert.Contains(res.SerializeResponse(), utils.UnknownLinkText)
+
+	st = NewStatement("", user.ID)
+	st.AddAttachment(&Attachment{Type: AttachmentLocation, Lat: 5.6, Long: -0.2})
+	res = l.Process(st)
+	assert.Equal(utils.ActionNewsSearch, res.Action)
+
+	st = NewStatement("", user.ID)
+	st.AddAttachment(&Attachment{Type: AttachmentAudio})
+	res = l.Process(st)
+	assert.Contains(res.SerializeResponse(), utils.AudioText)
+
+	st = NewStatement("", user.ID)
+	st.AddAttachment(&Attachment{Type: "video"})
+	res = l.Process(st)
+	assert.Equal(float32(0), res.Score)
+}
@@ -1,19 +1,68 @@
 package chatbot
 
-// BestLogic best logic adapter
+import (
+	"os"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+)
+
+const (
+	defaultLogicThreshold = 0.5
+	defaultLogicTimeout   = 5 * time.Second
+)
+
+// BestLogic logic adapter that runs all eligible adapters
+// and picks the response with the highest confidence
 type BestLogic struct {
-	bot    *Chatbot
-	logics []LogicAdapter
+	bot      *Chatbot
+	logics   []LogicAdapter
+	fallback LogicAdapter
+	// Threshold minimum score of a response, LOGIC_THRESHOLD overrides it
+	Threshold float32
+	// Timeout maximum time to wait for adapters
+	Timeout time.Duration
+}
+
+// logicResult response of an adapter
+type logicResult struct {
+	index    int
+	response *Statement
 }
 
 // NewBestLogic returns new BestLogic
 func NewBestLogic() *BestLogic {
-	return &BestLogic{
-		logics: []LogicAdapter{
-			NewPostBackLogic(),
-			NewDialogFlowLogic(),
-		},
+	logics := []LogicAdapter{NewReferralLogic(), NewPostBackLogic(), NewAttachmentLogic()}
+	fallback := []string{utils.DefaultResponseText}
+
+	if il, err := NewIntentLogic(""); err != nil && os.Getenv("INTENTS_FILE") != "" {
+		logger.Criticalf("Failed to load intents: %v", err)
+	} else if err != nil {
+		logger.Error("Intent logic disabled: ", err)
+	} else {
+		logics = append(logics, il)
+		if len(il.Intents.Fallback) > 0 {
+			fallback = il.Intents.Fallback
+		}
+	}
+	if df, err := NewDialogFlowLogic(); err != nil {
+		logger.Warn("Dialogflow logic disabled: ", err)
+	} else {
+		logics = append(logics, df)
+	}
+
+	l := &BestLogic{
+		logics:    logics,
+		fallback:  NewDefaultLogic(fallback...),
+		Threshold: defaultLogicThreshold,
+		Timeout:   defaultLogicTimeout,
+	}
+	if th, err := strconv.ParseFloat(os.Getenv("LOGIC_THRESHOLD"), 32); err == nil {
+		l.Threshold = float32(th)
 	}
+	return l
 }
 
 func (l *BestLogic) setChatbot(b *Chatbot) {
@@ -21,23 +70,71 @@ func (l *BestLogic) setChatbot(b *Chatbot) {
 	for _, logic := range l.logics {
 		logic.setChatbot(b)
 	}
+	l.fallback.setChatbot(b)
 }
 
 func (l *BestLogic) canProcess(s *Statement) bool {
 	return true
 }
 
-// Process reads the user's input from the terminal.
+// Process runs eligible adapters concurrently and returns the response
+// with the highest score, adapters that time out are ignored
 func (l *BestLogic) Process(st *Statement) *Statement {
 	l.bot.Logger.Info("Finding best response...")
 
-	var response *Statement
+	results := make(chan *logicResult, len(l.logics))
+	running := 0
+	for i, logic := range l.logics {
+		if !logic.canProcess(st) {
+			continue
+		}
+		running++
+		go func(i int, logic LogicAdapter, s *Statement) {
+			results <- &logicResult{i, logic.Process(s)}
+		}(i, logic, st.clone())
+	}
 
-	for _, logic := range l.logics {
-		if logic.canProcess(st) {
-			response = logic.Process(st)
-			break
+	responses := make([]*Statement, len(l.logics))
+	timeout := time.After(l.Timeout)
+wait:
+	for ; running > 0; running-- {
+		select {
+		case r := <-results:
+			responses[r.index] = r.response
+		case <-timeout:
+			l.bot.Logger.Warnf("%d logic adapters timed out", running)
+			break wait
+		}
+	}
+
+	// earlier adapters win ties
+	var best *Statement
+	var name string
+	scores := utils.Map{}
+	for i, res := range responses {
+		if res == nil {
+			continue
+		}
+		scores.Set(logicName(l.logics[i]), res.Score)
+		if best == nil || res.Score > best.Score {
+			best, name = res, logicName(l.logics[i])
 		}
 	}
-	return response
+	if best == nil || best.Score < l.Threshold {
+		best, name = l.fallback.Process(st.clone()), logicName(l.fallback)
+	}
+	l.bot.Logger.Debugf("Using %s response: %v", name, scores)
+
+	best.Meta.Set("logic", name)
+	best.Meta.Set("scores", scores)
+	return best
+}
+
+// logicName returns the type name of logic adapter
+func logicName(logic LogicAdapter) string {
+	t := reflect.TypeOf(logic)
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Name()
 }
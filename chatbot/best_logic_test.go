@@ -0,0 +1,77 @@
+package chatbot
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type scoreLogic struct {
+	bot   *Chatbot
+	text  string
+	score float32
+	delay time.Duration
+}
+
+func (l *scoreLogic) setChatbot(b *Chatbot) {
+	l.bot = b
+}
+
+func (l *scoreLogic) canProcess(s *Statement) bool {
+	return l.score >= 0
+}
+
+func (l *scoreLogic) Process(st *Statement) *Statement {
+	time.Sleep(l.delay)
+	st.AddTextResponse(l.text)
+	st.SetScore(l.score)
+	return st
+}
+
+func newTestBestLogic(logics ...LogicAdapter) *BestLogic {
+	l := &BestLogic{
+		logics:    logics,
+		fallback:  NewDefaultLogic("fallback"),
+		Threshold: 0.5,
+		Timeout:   100 * time.Millisecond,
+	}
+	l.setChatbot(ch)
+	return l
+}
+
+func TestBestLogic(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newTestBestLogic(
+		&scoreLogic{text: "low", score: 0.6},
+		&scoreLogic{text: "high", score: 0.9},
+		&scoreLogic{text: "skipped", score: -1},
+		&scoreLogic{text: "slow", score: 1, delay: time.Second},
+	)
+	res := l.Process(NewStatement("Hi", user.ID))
+	assert.Len(res.Responses, 1)
+	assert.Equal("high", fmt.Sprintf("%s", res.Responses[0]))
+	assert.Equal("scoreLogic", res.Meta.Get("logic", ""))
+	assert.Contains(res.Meta.Get("scores", nil), "scoreLogic")
+
+	l = newTestBestLogic(&scoreLogic{text: "first", score: 0.7}, &scoreLogic{text: "second", score: 0.7})
+	res = l.Process(NewStatement("Hi", user.ID))
+	assert.Contains(res.SerializeResponse(), "first")
+}
+
+func TestBestLogicFallback(t *testing.T) {
+	assert := assert.New(t)
+
+	l := newTestBestLogic(&scoreLogic{text: "unsure", score: 0.2})
+	res := l.Process(NewStatement("Hi", user.ID))
+	assert.Equal("Hi", res.Text)
+	assert.Contains(res.SerializeResponse(), "fallback")
+	assert.NotContains(res.SerializeResponse(), "unsure")
+	assert.Equal("DefaultLogic", res.Meta.Get("logic", ""))
+
+	l = newTestBestLogic()
+	res = l.Process(NewStatement("Hi", user.ID))
+	assert.Contains(res.SerializeResponse(), "fallback")
+}
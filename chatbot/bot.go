@@ -5,19 +5,23 @@ import (
 	"github.com/epigos/newsbot/web"
 )
 
+var logger = utils.NewLogger("chatbot")
+
 // Chatbot A convensational chat dialog
 type Chatbot struct {
-	Name   string
-	Logger *utils.Logger
-	Logic  LogicAdapter
+	Name     string
+	Logger   *utils.Logger
+	Logic    LogicAdapter
+	Sessions SessionStore
 }
 
 // New creates a new pointer of Chatbot
 func New(n string) *Chatbot {
 	bot := &Chatbot{
-		Name:   n,
-		Logger: utils.NewLogger(n),
-		Logic:  NewBestLogic(),
+		Name:     n,
+		Logger:   utils.NewLogger(n),
+		Logic:    NewBestLogic(),
+		Sessions: NewSessionStore(),
 	}
 	// initialize bot functions
 	bot.initialize()
@@ -38,6 +42,10 @@ func (b *Chatbot) initialize() {
 func (b *Chatbot) GetResponse(st *Statement) *Statement {
 	// get response statement
 	response := b.Logic.Process(st)
+	// run action resolved by the chosen logic
+	if response.Action != "" {
+		b.processAction(response, response.Action, response.Params)
+	}
 	// return output
 	return response
 }
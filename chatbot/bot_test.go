@@ -2,9 +2,12 @@ package chatbot
 
 import (
 	"log"
+	"os"
 	"testing"
+	"time"
 
 	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
 
 	"github.com/icrowley/fake"
 	"github.com/stretchr/testify/assert"
@@ -37,6 +40,7 @@ func TestGetResponse(t *testing.T) {
 	assert.Equal(res.Text, n)
 
 	st = NewStatement("Get Started", user.ID)
+	st.SetPayload("get_started")
 	res = ch.GetResponse(st)
 	assert.Len(res.Responses, 3)
 }
@@ -47,7 +51,8 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	models.Connect()
+	os.Setenv("INTENTS_FILE", "intents.yml")
+	models.UseStore(models.NewMemoryStore())
 	user = models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
 
 	ch = New("Test")
@@ -55,3 +60,70 @@ func TestMain(m *testing.M) {
 	m.Run()
 	models.Close()
 }
+
+func TestScheduleAction(t *testing.T) {
+	assert := assert.New(t)
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+
+	st := NewStatement("", bu.ID)
+	ch.processAction(st, utils.ActionSchedule, utils.Map{"schedule": "weekly"})
+	assert.Contains(st.SerializeResponse(), utils.NoSubscriptionText)
+
+	ch.subscribe(bu.ID, "sports")
+	ch.subscribe(bu.ID, "politics")
+
+	st = NewStatement("", bu.ID)
+	ch.processAction(st, utils.ActionSchedule, utils.Map{})
+	assert.Contains(st.SerializeResponse(), utils.ScheduleText)
+
+	st = NewStatement("", bu.ID)
+	ch.processAction(st, utils.ActionSchedule, utils.Map{"schedule": "morning", "topic": "sports"})
+	assert.Contains(st.SerializeResponse(), "every morning")
+
+	subs, err := models.GetUserSubscriptions(bu.ID)
+	assert.NoError(err)
+	schedules := map[string]string{}
+	for _, sub := range subs {
+		schedules[sub.Topic.Name] = sub.Schedule
+	}
+	assert.Equal(map[string]string{"sports": models.ScheduleMorning, "politics": ""}, schedules)
+}
+
+func TestSubscribeAction(t *testing.T) {
+	assert := assert.New(t)
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+
+	st := NewStatement("", bu.ID)
+	ch.processAction(st, utils.ActionSubscribe, utils.Map{"topic": "sports"})
+	assert.Contains(st.SerializeResponse(), utils.NotifyPayload+"sports")
+
+	_, err := models.GetUserSubscription(bu.ID, "sports")
+	assert.NoError(err)
+}
+
+func TestOtherSources(t *testing.T) {
+	assert := assert.New(t)
+
+	title := fake.Sentence()
+	var articles []*models.Article
+	for _, domain := range []string{"citinewsroom.com", "myjoyonline.com"} {
+		pub := time.Now()
+		link := "https://" + domain + "/" + fake.Characters()
+		article := models.NewArticle(title, link, fake.Sentence(), link, domain, link, &pub, []string{})
+		ta := utils.NewTextAnalysis(fake.SentencesN(5), "")
+		article.AddAssessment(ta)
+		article.SetTopic(fake.Word(), []string{})
+		_, err := article.AssignStory(ta)
+		assert.NoError(err)
+		article.Save()
+		articles = append(articles, article)
+	}
+
+	st := NewStatement(utils.PostBackOtherSources, user.ID)
+	st.SetPayload(articles[0].StoryKey.Name)
+	res := ch.GetResponse(st)
+	if assert.Len(res.Responses, 2) {
+		assert.Contains(res.SerializeResponse(), "2 sources")
+		assert.Contains(res.SerializeResponse(), articles[1].Link)
+	}
+}
@@ -0,0 +1,31 @@
+package chatbot
+
+// DefaultLogic logic adapter that returns a default response
+// when no other adapter is confident enough
+type DefaultLogic struct {
+	bot       *Chatbot
+	Responses []string
+}
+
+// NewDefaultLogic returns new DefaultLogic
+func NewDefaultLogic(responses ...string) *DefaultLogic {
+	return &DefaultLogic{Responses: responses}
+}
+
+func (l *DefaultLogic) setChatbot(b *Chatbot) {
+	l.bot = b
+}
+
+func (l *DefaultLogic) canProcess(s *Statement) bool {
+	return true
+}
+
+// Process reads the user's input from the terminal.
+func (l *DefaultLogic) Process(st *Statement) *Statement {
+	l.bot.Logger.Debug("Using default logic")
+
+	if reply := randomResponse(l.Responses); reply != "" {
+		st.AddTextResponse(reply)
+	}
+	return st
+}
@@ -0,0 +1,291 @@
+package chatbot
+
+import (
+	"math/rand"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+const (
+	defaultIntentsFile = "chatbot/intents.yml"
+	defaultThreshold   = 0.5
+	dateLayout         = "2006-01-02"
+	// topicsRefresh how often topics are loaded again as categories
+	topicsRefresh = 10 * time.Minute
+)
+
+// datePhrases maps date phrases to the number of days they go back
+var datePhrases = map[string]int{
+	"today":      0,
+	"tonight":    0,
+	"yesterday":  1,
+	"this week":  7,
+	"last week":  7,
+	"past week":  7,
+	"this month": 30,
+	"last month": 30,
+}
+
+// IntentLogic logic adapter that classifies the user's input into
+// actions offline using rules and a classifier trained from intents file
+type IntentLogic struct {
+	bot        *Chatbot
+	Intents    *Intents
+	classifier *classifier
+	actions    map[string]*Intent
+	now        func() time.Time
+	// topics names of topics created by the crawler
+	topicsMu     sync.Mutex
+	topics       []string
+	topicsLoaded time.Time
+}
+
+// intentMatch an intent matched from user's input
+type intentMatch struct {
+	intent *Intent
+	score  float64
+	params utils.Map
+}
+
+// NewIntentLogic creates a new intent logic from intents file,
+// INTENTS_FILE or the default file is used when path is empty
+func NewIntentLogic(path string) (*IntentLogic, error) {
+	if path == "" {
+		path = os.Getenv("INTENTS_FILE")
+	}
+	if path == "" {
+		path = utils.RepoPath(defaultIntentsFile)
+	}
+	intents, err := LoadIntents(path)
+	if err != nil {
+		return nil, err
+	}
+	return newIntentLogic(intents), nil
+}
+
+func newIntentLogic(intents *Intents) *IntentLogic {
+	l := &IntentLogic{
+		Intents:    intents,
+		classifier: newClassifier(),
+		actions:    map[string]*Intent{},
+		now:        time.Now,
+	}
+	if l.Intents.Threshold <= 0 {
+		l.Intents.Threshold = defaultThreshold
+	}
+	for _, intent := range intents.Intents {
+		l.actions[intent.Action] = intent
+		for _, ex := range intent.Examples {
+			l.classifier.train(intent.Action, ex)
+		}
+	}
+	l.topicNames()
+	return l
+}
+
+func (l *IntentLogic) setChatbot(b *Chatbot) {
+	l.bot = b
+}
+
+func (l *IntentLogic) canProcess(s *Statement) bool {
+	return s.Text != "" || s.Payload != ""
+}
+
+// Process classifies the quick reply payload or the text of the
+// statement and sets the action and parameters of the matched intent
+func (l *IntentLogic) Process(st *Statement) *Statement {
+	l.bot.Logger.Debug("Using intent logic")
+
+	// quick reply payloads name the intended action
+	var m *intentMatch
+	if st.Payload != "" {
+		m = l.match(st.Payload)
+	}
+	if m == nil {
+		m = l.match(st.Text)
+	}
+	if m == nil {
+		l.bot.Logger.Debug("No intent matched")
+		return st
+	}
+	l.bot.Logger.Debugf("Matched intent %s: %v", m.intent.Action, m.score)
+
+	st.SetScore(float32(m.score))
+	st.Meta.Set("intent", m.intent.Action)
+	if reply := randomResponse(m.intent.Responses); reply != "" {
+		st.AddTextResponse(reply)
+	}
+	st.SetAction(m.intent.Action, m.params)
+
+	return st
+}
+
+// match classifies text with patterns first and then with the classifier,
+// it returns nil when no intent reaches the threshold
+func (l *IntentLogic) match(text string) *intentMatch {
+	var m *intentMatch
+	for _, intent := range l.Intents.Intents {
+		for _, re := range intent.regexps {
+			if re.MatchString(text) {
+				m = &intentMatch{intent: intent, score: 1}
+				break
+			}
+		}
+		if m != nil {
+			break
+		}
+	}
+	if m == nil {
+		action, score := l.classifier.classify(text)
+		if action == "" || score < l.Intents.Threshold {
+			return nil
+		}
+		m = &intentMatch{intent: l.actions[action], score: score}
+	}
+	m.params = l.extract(m.intent.Action, utils.Words(text))
+	return m
+}
+
+// topicNames returns names of topics, they're loaded again every
+// topicsRefresh and the last loaded topics are kept on errors
+func (l *IntentLogic) topicNames() []string {
+	l.topicsMu.Lock()
+	defer l.topicsMu.Unlock()
+
+	fresh := !l.topicsLoaded.IsZero() && time.Since(l.topicsLoaded) < topicsRefresh
+	if fresh || models.DS == nil {
+		return l.topics
+	}
+	l.topicsLoaded = time.Now()
+	topics, err := models.GetAllTopics()
+	if err != nil {
+		logger.Error("Failed to load topics: ", err)
+		return l.topics
+	}
+	names := make([]string, len(topics))
+	for i, topic := range topics {
+		names[i] = strings.ToLower(topic.Name)
+	}
+	l.topics = names
+	return names
+}
+
+// extract returns action parameters found in words
+func (l *IntentLogic) extract(action string, words []string) utils.Map {
+	params := utils.Map{}
+	used := make([]bool, len(words))
+
+	entities := map[string]map[string][]string{}
+	for name, values := range l.Intents.Entities {
+		entities[name] = values
+	}
+	// topics created by the crawler are categories too
+	if topics := l.topicNames(); len(topics) > 0 {
+		cats := map[string][]string{}
+		for value, synonyms := range entities["category"] {
+			cats[value] = synonyms
+		}
+		for _, name := range topics {
+			cats[name] = append(cats[name], name)
+		}
+		entities["category"] = cats
+	}
+	dates := map[string][]string{}
+	for phrase, days := range datePhrases {
+		value := l.now().AddDate(0, 0, -days).Format(dateLayout)
+		dates[value] = append(dates[value], phrase)
+	}
+	entities["date-time"] = dates
+
+	names := make([]string, 0, len(entities))
+	for name := range entities {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if value := findEntity(entities[name], words, used); value != "" {
+			params.Set(name, value)
+		}
+	}
+	// subscriptions refer to categories as topics
+	cat := params.Get("category", "")
+	if cat != "" && (action == utils.ActionSubscribe || action == utils.ActionStop || action == utils.ActionSchedule) {
+		params.Set("topic", cat)
+	}
+
+	if action == utils.ActionNewsSearch {
+		ignore := map[string]bool{}
+		for _, w := range l.Intents.Ignore {
+			ignore[w] = true
+		}
+		var kwds []string
+		for i, w := range words {
+			if used[i] || len(w) < 2 || ignore[w] || utils.IsStopWord(w) {
+				continue
+			}
+			kwds = append(kwds, w)
+		}
+		if len(kwds) > 0 {
+			params.Set("keyword", strings.Join(kwds, " "))
+		}
+	}
+	return params
+}
+
+// findEntity returns the value of the longest synonym found in words
+// and marks its words as used
+func findEntity(values map[string][]string, words []string, used []bool) string {
+	type synonym struct {
+		value string
+		words []string
+	}
+	var synonyms []*synonym
+	for value, ss := range values {
+		for _, s := range ss {
+			synonyms = append(synonyms, &synonym{value, utils.Words(s)})
+		}
+	}
+	sort.Slice(synonyms, func(i, j int) bool {
+		if len(synonyms[i].words) != len(synonyms[j].words) {
+			return len(synonyms[i].words) > len(synonyms[j].words)
+		}
+		return synonyms[i].value < synonyms[j].value
+	})
+
+	for _, s := range synonyms {
+		if len(s.words) == 0 {
+			continue
+		}
+		for i := 0; i+len(s.words) <= len(words); i++ {
+			if phraseAt(words, used, i, s.words) {
+				for j := range s.words {
+					used[i+j] = true
+				}
+				return s.value
+			}
+		}
+	}
+	return ""
+}
+
+func phraseAt(words []string, used []bool, i int, phrase []string) bool {
+	for j, w := range phrase {
+		if used[i+j] || words[i+j] != w {
+			return false
+		}
+	}
+	return true
+}
+
+func randomResponse(responses []string) string {
+	if len(responses) == 0 {
+		return ""
+	}
+	return responses[rand.Intn(len(responses))]
+}
@@ -0,0 +1,139 @@
+package chatbot
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestIntentLogic(t *testing.T) *IntentLogic {
+	l, err := NewIntentLogic("intents.yml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.setChatbot(ch)
+	l.now = func() time.Time {
+		return time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC)
+	}
+	return l
+}
+
+func TestLoadIntents(t *testing.T) {
+	assert := assert.New(t)
+
+	intents, err := LoadIntents("intents.yml")
+	assert.NoError(err)
+	assert.NotEmpty(intents.Intents)
+	assert.Contains(intents.Entities, "category")
+
+	dir, _ := ioutil.TempDir("", "intents")
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "intents.json")
+	data := `{"intents": [{"action": "reset", "patterns": ["^reset$"], "examples": ["start over"]}]}`
+	ioutil.WriteFile(path, []byte(data), 0644)
+	intents, err = LoadIntents(path)
+	assert.NoError(err)
+	assert.Equal(utils.ActionReset, intents.Intents[0].Action)
+
+	ioutil.WriteFile(path, []byte(`{"intents": [{"action": "reset", "patterns": ["("]}]}`), 0644)
+	_, err = LoadIntents(path)
+	assert.Error(err)
+
+	_, err = LoadIntents(filepath.Join(dir, "missing.yml"))
+	assert.Error(err)
+}
+
+func TestIntentLogicMatch(t *testing.T) {
+	assert := assert.New(t)
+	l := newTestIntentLogic(t)
+
+	cases := []struct {
+		text   string
+		action string
+		params utils.Map
+	}{
+		{"Show me more", utils.ActionNewsSearchNext, utils.Map{}},
+		{"stop politics alerts", utils.ActionStop, utils.Map{"category": "politics", "topic": "politics"}},
+		{"Subscribe me to sports", utils.ActionSubscribe, utils.Map{"category": "sports", "topic": "sports"}},
+		{"other topics", utils.ActionTopics, utils.Map{}},
+		{"manage my alerts", utils.ActionManageAlerts, utils.Map{}},
+		{"start over", utils.ActionReset, utils.Map{}},
+		{"weekly", utils.ActionSchedule, utils.Map{"schedule": "weekly"}},
+		{"send me a morning digest of sports", utils.ActionSchedule, utils.Map{
+			"schedule": "morning", "category": "sports", "topic": "sports",
+		}},
+		{"latest business news from bbc today", utils.ActionNewsSearch, utils.Map{
+			"category": "business", "source": "bbc.com", "date-time": "2018-06-15",
+		}},
+		{"any news about the Kumasi floods yesterday", utils.ActionNewsSearch, utils.Map{
+			"keyword": "kumasi floods", "date-time": "2018-06-14",
+		}},
+	}
+	for _, c := range cases {
+		m := l.match(c.text)
+		if !assert.NotNil(m, c.text) {
+			continue
+		}
+		assert.Equal(c.action, m.intent.Action, c.text)
+		assert.Equal(c.params, m.params, c.text)
+		assert.True(m.score >= l.Intents.Threshold, c.text)
+	}
+
+	assert.Nil(l.match("Hi"))
+}
+
+func TestIntentLogicProcess(t *testing.T) {
+	assert := assert.New(t)
+	l := newTestIntentLogic(t)
+
+	assert.True(l.canProcess(NewStatement("Hi", user.ID)))
+	assert.False(l.canProcess(NewStatement("", user.ID)))
+
+	res := l.Process(NewStatement("Hi", user.ID))
+	assert.Empty(res.Responses)
+	assert.Equal(float32(0), res.Score)
+	assert.Empty(res.Action)
+
+	res = l.Process(NewStatement("subscribe to politics", user.ID))
+	assert.Equal(utils.ActionSubscribe, res.Meta.Get("intent", ""))
+	assert.Equal(float32(1), res.Score)
+	assert.Equal(utils.ActionSubscribe, res.Action)
+	assert.Equal("politics", res.Params.Get("topic", ""))
+
+	ch.processAction(res, res.Action, res.Params)
+	subs, err := models.GetUserSubscriptions(user.ID)
+	assert.NoError(err)
+	assert.Len(subs, 1)
+
+	res = l.Process(NewStatement("stop politics", user.ID))
+	ch.processAction(res, res.Action, res.Params)
+	subs, err = models.GetUserSubscriptions(user.ID)
+	assert.NoError(err)
+	assert.Empty(subs)
+}
+
+func TestIntentLogicTopics(t *testing.T) {
+	assert := assert.New(t)
+
+	// the default intents file is found outside the repository root
+	os.Unsetenv("INTENTS_FILE")
+	l, err := NewIntentLogic("")
+	assert.NoError(err)
+
+	// topics are loaded once and refreshed periodically
+	models.GetOrCreateTopic("Aviation", []string{})
+	params := l.extract(utils.ActionSubscribe, []string{"aviation"})
+	assert.Equal("", params.Get("topic", ""))
+
+	l.topicsLoaded = time.Now().Add(-topicsRefresh)
+	params = l.extract(utils.ActionSubscribe, []string{"aviation"})
+	assert.Equal("aviation", params.Get("topic", ""))
+}
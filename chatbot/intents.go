@@ -0,0 +1,154 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/epigos/newsbot/utils"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Intents training data of the intent engine
+type Intents struct {
+	// Threshold minimum confidence of a classified intent
+	Threshold float64 `json:"threshold" yaml:"threshold"`
+	// Fallback responses when no intent is matched
+	Fallback []string `json:"fallback" yaml:"fallback"`
+	// Ignore words which are never used as search keywords
+	Ignore []string `json:"ignore" yaml:"ignore"`
+	// Entities maps parameter names to values and their synonyms
+	Entities map[string]map[string][]string `json:"entities" yaml:"entities"`
+	Intents  []*Intent                      `json:"intents" yaml:"intents"`
+}
+
+// Intent an action and the utterances that trigger it
+type Intent struct {
+	Action    string   `json:"action" yaml:"action"`
+	Patterns  []string `json:"patterns" yaml:"patterns"`
+	Examples  []string `json:"examples" yaml:"examples"`
+	Responses []string `json:"responses" yaml:"responses"`
+	regexps   []*regexp.Regexp
+}
+
+// LoadIntents reads intents from a YAML or JSON file
+func LoadIntents(path string) (*Intents, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	intents := &Intents{}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, intents)
+	default:
+		err = yaml.Unmarshal(data, intents)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid intents file %s: %v", path, err)
+	}
+	return intents, intents.compile()
+}
+
+// compile validates intents and compiles their patterns
+func (in *Intents) compile() error {
+	for _, intent := range in.Intents {
+		if intent.Action == "" {
+			return fmt.Errorf("intent without action")
+		}
+		intent.regexps = nil
+		for _, p := range intent.Patterns {
+			re, err := regexp.Compile("(?i)" + p)
+			if err != nil {
+				return fmt.Errorf("invalid pattern for %s: %v", intent.Action, err)
+			}
+			intent.regexps = append(intent.regexps, re)
+		}
+	}
+	return nil
+}
+
+// classifier multinomial naive bayes text classifier
+type classifier struct {
+	docs   map[string]int
+	words  map[string]map[string]int
+	totals map[string]int
+	vocab  map[string]bool
+	count  int
+}
+
+func newClassifier() *classifier {
+	return &classifier{
+		docs:   map[string]int{},
+		words:  map[string]map[string]int{},
+		totals: map[string]int{},
+		vocab:  map[string]bool{},
+	}
+}
+
+// stems returns stemmed words of text used as classifier features
+func stems(text string) []string {
+	words := utils.Words(text)
+	for i, w := range words {
+		words[i] = utils.Stem(w)
+	}
+	return words
+}
+
+// train adds an example text of class
+func (c *classifier) train(class, text string) {
+	if _, ok := c.words[class]; !ok {
+		c.words[class] = map[string]int{}
+	}
+	c.docs[class]++
+	c.count++
+	for _, w := range stems(text) {
+		c.words[class][w]++
+		c.totals[class]++
+		c.vocab[w] = true
+	}
+}
+
+// classify returns the most likely class of text and its probability,
+// text without any known words is not classified
+func (c *classifier) classify(text string) (string, float64) {
+	var words []string
+	for _, w := range stems(text) {
+		if c.vocab[w] {
+			words = append(words, w)
+		}
+	}
+	if len(words) == 0 {
+		return "", 0
+	}
+
+	classes := make([]string, 0, len(c.docs))
+	for class := range c.docs {
+		classes = append(classes, class)
+	}
+	sort.Strings(classes)
+
+	scores := make([]float64, len(classes))
+	best := 0
+	for i, class := range classes {
+		score := math.Log(float64(c.docs[class]) / float64(c.count))
+		for _, w := range words {
+			score += math.Log(float64(c.words[class][w]+1) / float64(c.totals[class]+len(c.vocab)))
+		}
+		scores[i] = score
+		if score > scores[best] {
+			best = i
+		}
+	}
+	// normalize log likelihoods into the probability of best class
+	sum := 0.0
+	for _, score := range scores {
+		sum += math.Exp(score - scores[best])
+	}
+	return classes[best], 1 / sum
+}
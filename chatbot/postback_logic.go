@@ -9,6 +9,9 @@ import (
 	"github.com/epigos/newsbot/utils"
 )
 
+// maxElements maximum number of elements of generic messages
+const maxElements = 10
+
 // PostBackLogic logic to processs postback
 type PostBackLogic struct {
 	bot     *Chatbot
@@ -21,8 +24,13 @@ func NewPostBackLogic() *PostBackLogic {
 	actions := []string{
 		utils.PostBackGetStarted,
 		utils.PostBackGetSummary,
+		utils.PostBackOtherSources,
+	}
+	quoted := make([]string, len(actions))
+	for i, action := range actions {
+		quoted[i] = regexp.QuoteMeta(action)
 	}
-	regex := regexp.MustCompile(fmt.Sprintf(`%s`, strings.Join(actions, "|")))
+	regex := regexp.MustCompile(fmt.Sprintf(`^(?:%s)$`, strings.Join(quoted, "|")))
 	return &PostBackLogic{Actions: actions, regex: regex}
 }
 
@@ -30,13 +38,17 @@ func (l *PostBackLogic) setChatbot(b *Chatbot) {
 	l.bot = b
 }
 
+// canProcess reports whether statement is a postback or quick reply
+// of a known action, free text naming an action isn't a postback
 func (l *PostBackLogic) canProcess(s *Statement) bool {
-	return l.regex.MatchString(s.Text)
+	return s.Payload != "" && l.regex.MatchString(s.Text)
 }
 
-// Process reads the user's input from the terminal.
+// Process responds to postback actions, postbacks which can't
+// be answered score 0 so another response is picked
 func (l *PostBackLogic) Process(st *Statement) *Statement {
 	l.bot.Logger.Debug("Using postback logic")
+	st.SetScore(1)
 
 	switch st.Text {
 	case utils.PostBackGetStarted:
@@ -54,6 +66,7 @@ func (l *PostBackLogic) Process(st *Statement) *Statement {
 		article, err := models.GetArticle(st.Payload)
 		if err != nil {
 			l.bot.Logger.Error("Article summary:", err)
+			st.SetScore(0)
 		} else {
 			for _, sumr := range article.Summary {
 				st.AddTextResponse(sumr)
@@ -66,8 +79,33 @@ func (l *PostBackLogic) Process(st *Statement) *Statement {
 				a.Save()
 			}(st, article)
 		}
+	case utils.PostBackOtherSources:
+		l.bot.Logger.Debugf("Processing %s", utils.PostBackOtherSources)
+
+		story, err := models.GetStory(st.Payload)
+		if err != nil {
+			l.bot.Logger.Error("Story sources:", err)
+			st.SetScore(0)
+			break
+		}
+		articles, err := story.GetArticles()
+		if err != nil {
+			l.bot.Logger.Error("Story sources:", err)
+			st.SetScore(0)
+			break
+		}
+		st.AddTextResponse(fmt.Sprintf(utils.OtherSourcesText, story.Sources()))
+		gm := utils.NewGenericMessage(st.UserID)
+		for i, article := range articles {
+			if i == maxElements {
+				break
+			}
+			gm.AddElement(article.ToMessengerElement(st.UserID))
+		}
+		st.AddResponse(gm)
 	default:
 		l.bot.Logger.Debugf("Default post back: %+v", st.Text)
+		st.SetScore(0)
 	}
 
 	return st
@@ -0,0 +1,37 @@
+package chatbot
+
+import (
+	"testing"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPostBackLogic(t *testing.T) {
+	assert := assert.New(t)
+	l := NewPostBackLogic()
+	l.setChatbot(ch)
+
+	// actions in free text aren't postbacks
+	st := NewStatement(utils.PostBackGetSummary, user.ID)
+	assert.False(l.canProcess(st))
+	st = NewStatement("Summary of the news please", user.ID)
+	st.SetPayload("Summary of the news please")
+	assert.False(l.canProcess(st))
+
+	st = NewStatement(utils.PostBackGetStarted, user.ID)
+	st.SetPayload("get_started")
+	assert.True(l.canProcess(st))
+	res := l.Process(st)
+	assert.Equal(float32(1), res.Score)
+	assert.Contains(res.SerializeResponse(), utils.GetStartedMsg)
+
+	// postbacks which can't be answered don't win
+	st = NewStatement(utils.PostBackGetSummary, user.ID)
+	st.SetPayload(fake.Characters())
+	assert.True(l.canProcess(st))
+	res = l.Process(st)
+	assert.Equal(float32(0), res.Score)
+}
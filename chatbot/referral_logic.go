@@ -0,0 +1,95 @@
+package chatbot
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+// refSeparator separates action and value of ref params,
+// e.g. m.me/newsbot?ref=subscribe:sports
+const refSeparator = ":"
+
+// ReferralLogic logic adapter that starts deep-linked flows from
+// m.me links, ads, QR codes and messenger plugins
+type ReferralLogic struct {
+	bot *Chatbot
+}
+
+// NewReferralLogic returns new ReferralLogic
+func NewReferralLogic() *ReferralLogic {
+	return &ReferralLogic{}
+}
+
+func (l *ReferralLogic) setChatbot(b *Chatbot) {
+	l.bot = b
+}
+
+func (l *ReferralLogic) canProcess(s *Statement) bool {
+	return s.Referral != ""
+}
+
+// Process starts the flow of the referral ref param
+func (l *ReferralLogic) Process(st *Statement) *Statement {
+	l.bot.Logger.Debugf("Using referral logic: %s", st.Referral)
+	st.SetScore(1)
+
+	action, value := parseRef(st.Referral)
+	switch {
+	case action == utils.ActionSubscribe && l.isTopic(value):
+		st.AddTextResponse(fmt.Sprintf(utils.ReferralSubscribeText, value))
+		st.SetAction(utils.ActionSubscribe, utils.Map{"topic": value})
+
+	case action == utils.ActionSubscribe:
+		l.bot.Logger.Debugf("Unknown topic %s, listing topics", value)
+		st.SetAction(utils.ActionTopics, utils.Map{})
+
+	case action == utils.ActionNewsSearch && value != "":
+		st.SetAction(utils.ActionNewsSearch, utils.Map{"keyword": value})
+
+	case action == utils.ActionTopics:
+		st.SetAction(utils.ActionTopics, utils.Map{})
+
+	default:
+		l.bot.Logger.Debugf("Unknown ref %s, getting started", st.Referral)
+		st.AddTextResponse(utils.GetStartedMsg)
+		st.AddTextResponse(utils.SubscribeText)
+		st.AddResponse(utils.NewSubscribeMenu(st.UserID))
+	}
+	return st
+}
+
+// isTopic reports whether name is a topic of articles
+func (l *ReferralLogic) isTopic(name string) bool {
+	if name == "" {
+		return false
+	}
+	topics, err := models.GetTopics()
+	if err != nil {
+		l.bot.Logger.Error("Failed to load topics:", err)
+		return false
+	}
+	for _, topic := range topics {
+		if strings.ToLower(topic.Name) == name {
+			return true
+		}
+	}
+	return false
+}
+
+// parseRef splits ref into its action and value
+func parseRef(ref string) (string, string) {
+	parts := strings.SplitN(ref, refSeparator, 2)
+	action := strings.ToLower(parts[0])
+	if len(parts) < 2 {
+		return action, ""
+	}
+	value, err := url.QueryUnescape(parts[1])
+	if err != nil {
+		value = parts[1]
+	}
+	return action, strings.ToLower(strings.TrimSpace(value))
+}
@@ -0,0 +1,62 @@
+package chatbot
+
+import (
+	"testing"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRef(t *testing.T) {
+	assert := assert.New(t)
+
+	action, value := parseRef("subscribe:Sports")
+	assert.Equal(utils.ActionSubscribe, action)
+	assert.Equal("sports", value)
+
+	action, value = parseRef("news.search:black%20stars")
+	assert.Equal(utils.ActionNewsSearch, action)
+	assert.Equal("black stars", value)
+
+	action, value = parseRef("topics.lists")
+	assert.Equal(utils.ActionTopics, action)
+	assert.Empty(value)
+}
+
+func TestReferralLogic(t *testing.T) {
+	assert := assert.New(t)
+	l := NewReferralLogic()
+	l.setChatbot(ch)
+	models.GetOrCreateTopic("Sports", []string{})
+
+	st := NewStatement("", user.ID)
+	assert.False(l.canProcess(st))
+
+	st.SetReferral("subscribe:sports")
+	assert.True(l.canProcess(st))
+	res := l.Process(st)
+	assert.Equal(float32(1), res.Score)
+	assert.Equal(utils.ActionSubscribe, res.Action)
+	assert.Equal("sports", res.Params["topic"])
+
+	// unknown topics list topics instead
+	st = NewStatement("", user.ID)
+	st.SetReferral("subscribe:no-such-topic")
+	res = l.Process(st)
+	assert.Equal(utils.ActionTopics, res.Action)
+	assert.Nil(res.Params["topic"])
+
+	st = NewStatement("", user.ID)
+	st.SetReferral("news.search:ghana")
+	res = l.Process(st)
+	assert.Equal(utils.ActionNewsSearch, res.Action)
+	assert.Equal("ghana", res.Params["keyword"])
+
+	st = NewStatement("", user.ID)
+	st.SetReferral("unknown")
+	res = l.Process(st)
+	assert.Empty(res.Action)
+	assert.Contains(res.SerializeResponse(), utils.GetStartedMsg)
+}
@@ -0,0 +1,176 @@
+package chatbot
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	defaultSessionTTL = 24 * time.Hour
+	memorySessions    = "memory"
+)
+
+// SessionStore an interface for storing conversation state of users,
+// sessions expire after a period of inactivity
+type SessionStore interface {
+	// Get returns the state of user, empty when there's no session
+	Get(userID string) (utils.Map, error)
+	Set(userID string, state utils.Map) error
+	Delete(userID string) error
+}
+
+// NewSessionStore returns session store configured with SESSION_STORE
+// and SESSION_TTL, sessions are persisted in the database by default
+func NewSessionStore() SessionStore {
+	ttl := defaultSessionTTL
+	if d, err := time.ParseDuration(os.Getenv("SESSION_TTL")); err == nil {
+		ttl = d
+	}
+	if os.Getenv("SESSION_STORE") == memorySessions {
+		return NewMemorySessionStore(ttl)
+	}
+	return NewDBSessionStore(ttl)
+}
+
+type memorySession struct {
+	state   utils.Map
+	expires time.Time
+}
+
+// MemorySessionStore session store kept in memory
+type MemorySessionStore struct {
+	TTL       time.Duration
+	mu        sync.Mutex
+	sessions  map[string]*memorySession
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemorySessionStore returns new in-memory session store
+func NewMemorySessionStore(ttl time.Duration) *MemorySessionStore {
+	return &MemorySessionStore{
+		TTL:      ttl,
+		sessions: map[string]*memorySession{},
+		now:      time.Now,
+	}
+}
+
+// Get returns the state of user
+func (s *MemorySessionStore) Get(userID string) (utils.Map, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[userID]
+	if !ok {
+		return utils.Map{}, nil
+	}
+	if !sess.expires.After(s.now()) {
+		delete(s.sessions, userID)
+		return utils.Map{}, nil
+	}
+	return copyState(sess.state), nil
+}
+
+// Set saves the state of user and extends the session
+func (s *MemorySessionStore) Set(userID string, state utils.Map) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	s.sessions[userID] = &memorySession{copyState(state), now.Add(s.TTL)}
+
+	// evict expired sessions once per ttl
+	if now.Sub(s.lastSweep) >= s.TTL {
+		for id, sess := range s.sessions {
+			if !sess.expires.After(now) {
+				delete(s.sessions, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+// Delete removes the session of user
+func (s *MemorySessionStore) Delete(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.sessions, userID)
+	return nil
+}
+
+// DBSessionStore session store persisted in the database,
+// sessions are shared between replicas and survive restarts
+type DBSessionStore struct {
+	TTL       time.Duration
+	mu        sync.Mutex
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewDBSessionStore returns new database session store
+func NewDBSessionStore(ttl time.Duration) *DBSessionStore {
+	return &DBSessionStore{TTL: ttl, now: time.Now}
+}
+
+// Get returns the state of user
+func (s *DBSessionStore) Get(userID string) (utils.Map, error) {
+	sess, err := models.GetSession(userID)
+	if err == datastore.ErrNoSuchEntity {
+		return utils.Map{}, nil
+	}
+	if err != nil {
+		return utils.Map{}, err
+	}
+	if sess.IsExpired(s.now()) {
+		return utils.Map{}, sess.Delete()
+	}
+	state := utils.Map{}
+	if err := json.Unmarshal([]byte(sess.Data), &state); err != nil {
+		return utils.Map{}, err
+	}
+	return state, nil
+}
+
+// Set saves the state of user and extends the session
+func (s *DBSessionStore) Set(userID string, state utils.Map) error {
+	data, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	now := s.now()
+	models.NewSession(userID, string(data), now.Add(s.TTL)).Save()
+
+	// delete expired sessions once per ttl
+	s.mu.Lock()
+	sweep := now.Sub(s.lastSweep) >= s.TTL
+	if sweep {
+		s.lastSweep = now
+	}
+	s.mu.Unlock()
+	if sweep {
+		_, err = models.DeleteExpiredSessions(now)
+	}
+	return err
+}
+
+// Delete removes the session of user
+func (s *DBSessionStore) Delete(userID string) error {
+	return models.NewSession(userID, "", time.Time{}).Delete()
+}
+
+func copyState(state utils.Map) utils.Map {
+	c := utils.Map{}
+	for k, v := range state {
+		c[k] = v
+	}
+	return c
+}
@@ -0,0 +1,82 @@
+package chatbot
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func testSessionStore(t *testing.T, store SessionStore, advance func(time.Duration)) {
+	assert := assert.New(t)
+	userID := fake.Characters()
+
+	state, err := store.Get(userID)
+	assert.NoError(err)
+	assert.Empty(state)
+
+	assert.NoError(store.Set(userID, utils.Map{"keyword": "ghana", "page": 2}))
+	state, err = store.Get(userID)
+	assert.NoError(err)
+	assert.Equal("ghana", state.Get("keyword", ""))
+
+	// changing returned state doesn't change the session
+	state.Set("keyword", "sports")
+	state, _ = store.Get(userID)
+	assert.Equal("ghana", state.Get("keyword", ""))
+
+	assert.NoError(store.Delete(userID))
+	state, _ = store.Get(userID)
+	assert.Empty(state)
+
+	store.Set(userID, utils.Map{"keyword": "ghana"})
+	advance(2 * time.Hour)
+	state, err = store.Get(userID)
+	assert.NoError(err)
+	assert.Empty(state)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			store.Set(userID, utils.Map{"page": i})
+			store.Get(userID)
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestMemorySessionStore(t *testing.T) {
+	now := time.Now()
+	store := NewMemorySessionStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	testSessionStore(t, store, func(d time.Duration) { now = now.Add(d) })
+}
+
+func TestDBSessionStore(t *testing.T) {
+	now := time.Now()
+	store := NewDBSessionStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	testSessionStore(t, store, func(d time.Duration) { now = now.Add(d) })
+}
+
+func TestLastSearch(t *testing.T) {
+	assert := assert.New(t)
+	bot := &Chatbot{Logger: ch.Logger, Sessions: NewDBSessionStore(time.Hour)}
+
+	params, page := bot.lastSearch(user.ID)
+	assert.Empty(params)
+	assert.Equal(1, page)
+
+	bot.Sessions.Set(user.ID, utils.Map{"keyword": "ghana", "page": 3})
+	params, page = bot.lastSearch(user.ID)
+	assert.Equal("ghana", params.Get("keyword", ""))
+	assert.Equal(3, page)
+}
@@ -9,15 +9,36 @@ import (
 	dgcm "github.com/mlabouardy/dialogflow-go-client/models"
 )
 
+// Attachment types of statements
+const (
+	AttachmentImage    = "image"
+	AttachmentAudio    = "audio"
+	AttachmentLocation = "location"
+	AttachmentLink     = "link"
+)
+
+// Attachment media, location or link sent with a statement
+type Attachment struct {
+	Type  string  `json:"type"`
+	URL   string  `json:"url,omitempty"`
+	Title string  `json:"title,omitempty"`
+	Lat   float64 `json:"lat,omitempty"`
+	Long  float64 `json:"long,omitempty"`
+}
+
 // Statement represents a single spoken entity, sentence or
 // phrase that someone can say
 type Statement struct {
-	Text      string        `json:"text"`
-	Payload   string        `json:"payload,omitempty"`
-	UserID    string        `json:"-"`
-	Responses []interface{} `json:"responses"`
-	Score     float32       `json:"-"`
-	Meta      utils.Map     `json:"meta"`
+	Text        string        `json:"text"`
+	Payload     string        `json:"payload,omitempty"`
+	UserID      string        `json:"-"`
+	Responses   []interface{} `json:"responses"`
+	Score       float32       `json:"-"`
+	Meta        utils.Map     `json:"meta"`
+	Action      string        `json:"action,omitempty"`
+	Params      utils.Map     `json:"-"`
+	Attachments []*Attachment `json:"attachments,omitempty"`
+	Referral    string        `json:"referral,omitempty"`
 }
 
 // NewStatement creates and returns a pointer of new Statement
@@ -30,6 +51,18 @@ func NewStatement(t, userID string) *Statement {
 	return s
 }
 
+// clone returns a copy of statement without responses
+func (s *Statement) clone() *Statement {
+	c := NewStatement(s.Text, s.UserID)
+	c.Payload = s.Payload
+	c.Attachments = s.Attachments
+	c.Referral = s.Referral
+	for k, v := range s.Meta {
+		c.Meta.Set(k, v)
+	}
+	return c
+}
+
 // SerializeResponse statement into JSON
 func (s *Statement) SerializeResponse() string {
 	bs, _ := json.Marshal(s.Responses)
@@ -46,6 +79,22 @@ func (s *Statement) SetScore(c float32) {
 	s.Score = c
 }
 
+// SetAction set action resolved from statement and its params
+func (s *Statement) SetAction(action string, params utils.Map) {
+	s.Action = action
+	s.Params = params
+}
+
+// AddAttachment add attachment to statement
+func (s *Statement) AddAttachment(a *Attachment) {
+	s.Attachments = append(s.Attachments, a)
+}
+
+// SetReferral set ref param of the referral which started the conversation
+func (s *Statement) SetReferral(ref string) {
+	s.Referral = ref
+}
+
 // SetPayload set Payload of intent
 func (s *Statement) SetPayload(p string) {
 	s.Payload = p
@@ -2,36 +2,99 @@ package crawler
 
 import (
 	"fmt"
+	"github.com/epigos/newsbot/models"
 	"github.com/epigos/newsbot/utils"
 	"os"
+	"strconv"
 	"sync"
 	"sync/atomic"
 	"time"
 )
 
 const (
-	delayInterval         = 5
-	responseBuffer        = 10
-	topStories            = "Top stories"
-	politicsCategory      = "Politics"
-	worldCategory         = "World"
-	sportsCategory        = "Sports"
-	businessCategory      = "Business"
-	lifestyleCategory     = "Lifestyle"
-	entertainmentCategory = "Entertainment"
-	techCategory          = "Tech"
-	africaCategory        = "Africa"
+	responseBuffer = 10
 )
 
-// Crawler contains spiders to be crawled
+// Crawler contains spiders to be crawled, spiders
+// are reloaded when the sources file changes
 type Crawler struct {
 	Spiders       []Spider
 	Logger        *utils.Logger
+	fetcher       *Fetcher
 	resCh         chan *crawlResponse
 	wg            sync.WaitGroup
 	stopCh        chan bool
-	ops           uint64
 	crawlInterval time.Duration
+	// refresh how long after their update crawled items are fetched again
+	refresh time.Duration
+	// DoneCh receives a signal each time a crawl run completes
+	DoneCh         chan bool
+	mu             sync.Mutex
+	sourcesFile    string
+	sourcesModTime time.Time
+	reloadInterval time.Duration
+	// lastCrawl start of the last crawl of spiders
+	lastCrawl map[string]time.Time
+	// stats of spiders of the current crawl run
+	stats   map[string]*CrawlStats
+	started time.Time
+	// failures consecutive runs of spiders without articles
+	failures  map[string]int
+	alertRuns int
+}
+
+// CrawlStats counts of feed items of a spider during a crawl run
+type CrawlStats struct {
+	// Fetched items whose page was fetched
+	Fetched uint64
+	// Skipped items which were crawled before
+	Skipped uint64
+	// New items saved as new articles
+	New uint64
+	// Saved items saved as new or updated articles
+	Saved uint64
+	// Errors items whose page couldn't be fetched or parsed
+	Errors uint64
+	// skip reasons of fetched pages which are not saved
+	NoImage       uint64
+	NoDescription uint64
+	EmptyBody     uint64
+	mu            sync.Mutex
+	feeds         []models.FeedRun
+}
+
+func (s *CrawlStats) String() string {
+	return fmt.Sprintf("fetched %d, skipped %d, new %d, saved %d, errors %d",
+		atomic.LoadUint64(&s.Fetched), atomic.LoadUint64(&s.Skipped), atomic.LoadUint64(&s.New),
+		atomic.LoadUint64(&s.Saved), atomic.LoadUint64(&s.Errors))
+}
+
+// addFeed records result of a feed request
+func (s *CrawlStats) addFeed(l *link, status string, err error, items int) {
+	f := models.FeedRun{URL: l.url, Category: l.category, Status: status, Items: items}
+	if err != nil {
+		f.Error = err.Error()
+	}
+	s.mu.Lock()
+	s.feeds = append(s.feeds, f)
+	s.mu.Unlock()
+}
+
+// copy returns copy of stats
+func (s *CrawlStats) copy() *CrawlStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return &CrawlStats{
+		Fetched:       atomic.LoadUint64(&s.Fetched),
+		Skipped:       atomic.LoadUint64(&s.Skipped),
+		New:           atomic.LoadUint64(&s.New),
+		Saved:         atomic.LoadUint64(&s.Saved),
+		Errors:        atomic.LoadUint64(&s.Errors),
+		NoImage:       atomic.LoadUint64(&s.NoImage),
+		NoDescription: atomic.LoadUint64(&s.NoDescription),
+		EmptyBody:     atomic.LoadUint64(&s.EmptyBody),
+		feeds:         append([]models.FeedRun(nil), s.feeds...),
+	}
 }
 
 type link struct {
@@ -52,6 +115,7 @@ type crawlResponse struct {
 type Spider interface {
 	getName() string
 	getLinks() links
+	getInterval() time.Duration
 	makeRequest(l *link)
 	process(r *crawlResponse)
 	setCrawler(c *Crawler)
@@ -65,7 +129,11 @@ func (l *link) String() string {
 	return fmt.Sprintf("%s: %s", l.category, l.url)
 }
 
-// New creates a new crawler
+// New creates a new crawler of the sources of SOURCES_FILE,
+// the file is checked for changes every SOURCES_RELOAD, crawled
+// items updated within CRAWL_REFRESH are fetched again, spiders
+// without articles in CRAWL_ALERT_RUNS consecutive runs are reported.
+// It exits when no sources are loaded
 func New() *Crawler {
 
 	strInv := os.Getenv("CRAWL_INTERVAL")
@@ -73,36 +141,62 @@ func New() *Crawler {
 	if err != nil {
 		crawlInterval = time.Minute * 60
 	}
+	refresh, _ := time.ParseDuration(os.Getenv("CRAWL_REFRESH"))
+	path := sourcesFile()
+	reloadInterval, err := time.ParseDuration(os.Getenv("SOURCES_RELOAD"))
+	if err != nil || reloadInterval <= 0 {
+		reloadInterval = defaultReloadInterval
+	}
 
-	spiders := []Spider{
-		newCitinews(),
-		newMyjoyOnline(),
-		newModernghana(),
-		newGhanaweb(),
-		newPulse(),
-		newBBC(),
+	c := &Crawler{
+		Logger:         utils.NewLogger("crawler"),
+		fetcher:        NewFetcher(),
+		resCh:          make(chan *crawlResponse, responseBuffer),
+		wg:             sync.WaitGroup{},
+		stopCh:         make(chan bool),
+		crawlInterval:  crawlInterval,
+		refresh:        refresh,
+		sourcesFile:    path,
+		reloadInterval: reloadInterval,
+		lastCrawl:      map[string]time.Time{},
+		stats:          map[string]*CrawlStats{},
+		failures:       map[string]int{},
+		alertRuns:      defaultAlertRuns,
+	}
+	if n, err := strconv.Atoi(os.Getenv("CRAWL_ALERT_RUNS")); err == nil && n > 0 {
+		c.alertRuns = n
+	}
+	// the crawler doesn't start without sources
+	if err := c.loadSources(); err != nil {
+		c.Logger.Criticalf("Failed to load sources, check SOURCES_FILE: %v", err)
 	}
-	return &Crawler{
-		Spiders:       spiders,
-		Logger:        utils.NewLogger("crawler"),
-		resCh:         make(chan *crawlResponse, responseBuffer),
-		wg:            sync.WaitGroup{},
-		stopCh:        make(chan bool),
-		crawlInterval: crawlInterval,
+	if len(c.Spiders) == 0 {
+		c.Logger.Criticalf("No sources in %s", path)
 	}
+	return c
 }
 
 func (c *Crawler) String() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
 	return fmt.Sprintf("Crawler: %s", c.Spiders)
 }
 
-// Run starts crawling
+// Run starts crawling spiders which are due
 func (c *Crawler) Run() {
 	c.Logger.Info("Starting crawler")
-	// reset counter
-	atomic.StoreUint64(&c.ops, 0)
 
-	for _, spider := range c.Spiders {
+	now := time.Now()
+	spiders := c.due(now)
+	c.mu.Lock()
+	c.started = now
+	c.stats = map[string]*CrawlStats{}
+	for _, spider := range spiders {
+		c.stats[spider.getName()] = &CrawlStats{}
+	}
+	c.mu.Unlock()
+
+	for _, spider := range spiders {
 		c.wg.Add(1)
 		spider.setCrawler(c)
 		go c.Crawl(spider)
@@ -112,14 +206,85 @@ func (c *Crawler) Run() {
 	c.Done()
 }
 
-// Listen listens to crawl response
+// due returns spiders whose interval passed since their last crawl
+func (c *Crawler) due(now time.Time) []Spider {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var spiders []Spider
+	for _, s := range c.Spiders {
+		last, ok := c.lastCrawl[s.getName()]
+		if ok && now.Sub(last) < c.interval(s) {
+			continue
+		}
+		c.lastCrawl[s.getName()] = now
+		spiders = append(spiders, s)
+	}
+	return spiders
+}
+
+// spiderStats returns stats of spider in the current crawl run
+func (c *Crawler) spiderStats(name string) *CrawlStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	st, ok := c.stats[name]
+	if !ok {
+		st = &CrawlStats{}
+		c.stats[name] = st
+	}
+	return st
+}
+
+// Stats returns stats of spiders of the last crawl run
+func (c *Crawler) Stats() map[string]*CrawlStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := make(map[string]*CrawlStats, len(c.stats))
+	for name, st := range c.stats {
+		stats[name] = st.copy()
+	}
+	return stats
+}
+
+// interval returns how often spider is crawled
+func (c *Crawler) interval(s Spider) time.Duration {
+	if d := s.getInterval(); d > 0 {
+		return d
+	}
+	return c.crawlInterval
+}
+
+// nextRun returns time until the next crawl, the shortest interval of spiders
+func (c *Crawler) nextRun() time.Duration {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	next := c.crawlInterval
+	for _, s := range c.Spiders {
+		if d := c.interval(s); d < next {
+			next = d
+		}
+	}
+	return next
+}
+
+// Listen listens to crawl response and reloads changed sources
 func (c *Crawler) Listen() {
+	reload := time.NewTicker(c.reloadInterval)
+	defer reload.Stop()
 	for {
 		select {
 		case r := <-c.resCh:
 			// process response
 			go r.spider.process(r)
 
+		case <-reload.C:
+			if err := c.loadSources(); err != nil {
+				c.Logger.Error("Failed to reload sources, keeping current spiders:", err)
+			}
+
 		case <-c.stopCh:
 			close(c.resCh)
 			c.Logger.Info("Stoping crawler")
@@ -146,13 +311,23 @@ func (c *Crawler) Stop() {
 	c.stopCh <- true
 }
 
-// Done done crawling
+// Done done crawling, the run is recorded
 func (c *Crawler) Done() {
-	opsFinal := atomic.LoadUint64(&c.ops)
-	c.Logger.Infof("Done crawling %d news feed", opsFinal)
+	run := c.record(time.Now())
+	fresh := 0
+	for _, sr := range run.Spiders {
+		fresh += sr.New
+	}
+	c.Logger.Infof("Done crawling %d new articles in %.0fs", fresh, run.Duration)
+
+	if c.DoneCh != nil {
+		go func() {
+			c.DoneCh <- true
+		}()
+	}
 
 	go func() {
-		time.Sleep(c.crawlInterval)
+		time.Sleep(c.nextRun())
 		c.Run()
 	}()
 }
@@ -3,9 +3,11 @@ package crawler
 import (
 	"fmt"
 	"log"
+	"os"
 	"testing"
 
 	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
 
 	"github.com/joho/godotenv"
 	"github.com/stretchr/testify/assert"
@@ -17,7 +19,8 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	models.Connect()
+	os.Setenv("SOURCES_FILE", "sources.yml")
+	models.UseStore(models.NewMemoryStore())
 	m.Run()
 	models.Close()
 }
@@ -28,8 +31,8 @@ func TestCrawler(t *testing.T) {
 	testSpider := newFeedSpider(
 		"citinewsroom",
 		"citinewsroom.com",
-		newLink(topStories, "https://citinewsroom.com/ghana-news/top-stories/feed/"),
-		newLink(worldCategory, "http://example.com/feed/"),
+		newLink(utils.TopStories, "https://citinewsroom.com/ghana-news/top-stories/feed/"),
+		newLink("World", "http://example.com/feed/"),
 	)
 	testSpider.Config.Set("UseMetaDesc", true)
 	testSpider.Config.Set("BodySelector", ".entry-content p")
@@ -55,30 +58,39 @@ func TestCrawler(t *testing.T) {
 func TestLink(t *testing.T) {
 	assert := assert.New(t)
 
-	ln := newLink(topStories, "http://example.com")
+	ln := newLink(utils.TopStories, "http://example.com")
 	assert.Equal(ln.url, "http://example.com")
-	assert.Equal(ln.category, topStories)
+	assert.Equal(ln.category, utils.TopStories)
 	assert.Equal(ln.String(), fmt.Sprintf("%s: %s", ln.category, ln.url))
 }
 
 func TestSpider(t *testing.T) {
 	assert := assert.New(t)
 
-	sm := newMyjoyOnline()
-	assert.Equal(sm.getName, "myjoyonline")
-
-	cn := newCitinews()
-	assert.Equal(cn.getName(), "citinewsroom")
-
-	bb := newBBC()
-	assert.Equal(bb.getName(), "bbc")
-
-	gw := newGhanaweb()
-	assert.Equal(gw.getName(), "ghanaweb")
+	c := New()
+	var names []string
+	for _, s := range c.Spiders {
+		names = append(names, s.getName())
+	}
+	assert.Equal([]string{"citinewsroom", "myjoyonline", "modernghana", "ghanaweb", "pulse", "bbc"}, names)
 
-	mg := newModernghana()
-	assert.Equal(mg.getName(), "modernghana")
+	cn := c.Spiders[0].(*feedSpider)
+	assert.Equal("citinewsroom.com", cn.Domain)
+	assert.Equal(true, cn.Config.Get("UseMetaDesc", nil))
+	assert.Equal(".entry-content p", cn.Config.Get("BodySelector", nil))
+	assert.Equal(utils.TopStories, cn.Links[0].category)
+}
 
-	pl := newPulse()
-	assert.Equal(pl.getName(), "pulse")
+// request returns response of spider to link, nil when the request failed
+func request(c *Crawler, sp Spider, l *link) *crawlResponse {
+	sp.setCrawler(c)
+	c.wg.Add(1)
+	sp.makeRequest(l)
+	select {
+	case r := <-c.resCh:
+		c.wg.Done()
+		return r
+	default:
+		return nil
+	}
 }
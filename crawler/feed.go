@@ -1,22 +1,24 @@
 package crawler
 
 import (
+	"strings"
 	"sync/atomic"
 	"time"
 
 	"github.com/epigos/newsbot/models"
 	"github.com/epigos/newsbot/utils"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/mmcdole/gofeed"
 )
 
 type feedSpider struct {
-	Name    string
-	Domain  string
-	Links   links
-	Config  utils.Map
-	crawler *Crawler
-	parser  *gofeed.Parser
+	Name     string
+	Domain   string
+	Links    links
+	Config   utils.Map
+	Interval time.Duration
+	crawler  *Crawler
 }
 
 func newFeedSpider(name, domain string, links ...*link) *feedSpider {
@@ -24,7 +26,6 @@ func newFeedSpider(name, domain string, links ...*link) *feedSpider {
 		Name:   name,
 		Domain: domain,
 		Config: utils.Map{},
-		parser: gofeed.NewParser(),
 		Links:  links,
 	}
 }
@@ -41,50 +42,109 @@ func (s *feedSpider) getLinks() links {
 	return s.Links
 }
 
+func (s *feedSpider) getInterval() time.Duration {
+	return s.Interval
+}
+
 func (s *feedSpider) getName() string {
 	return s.Name
 }
 
 func (s *feedSpider) makeRequest(l *link) {
-	defer s.crawler.wg.Done()
+	s.request(s, l, s.crawler.fetcher.Feed)
+}
 
-	feed, err := s.parser.ParseURL(l.url)
+// request fetches items of link with fetch and adds them as response of spider sp
+func (s *feedSpider) request(sp Spider, l *link, fetch func(string) (*gofeed.Feed, error)) {
+	defer s.crawler.wg.Done()
 
+	stats := s.crawler.spiderStats(s.Name)
+	feed, err := fetch(l.url)
+	if err == ErrNotModified {
+		s.crawler.Logger.Debugf("%s is not modified", l)
+		stats.addFeed(l, models.FeedNotModified, nil, 0)
+		return
+	}
 	if err != nil {
-		s.crawler.Logger.Debugf("%s might be down!", err)
+		s.crawler.Logger.Warnf("%s might be down!", err)
+		stats.addFeed(l, models.FeedError, err, 0)
 		return
 	}
 	s.crawler.Logger.Debugf("%s is up!", l)
-	s.crawler.AddResponse(&crawlResponse{s, l, feed})
+	stats.addFeed(l, models.FeedOK, nil, len(feed.Items))
+	s.crawler.AddResponse(&crawlResponse{sp, l, feed})
+}
+
+// crawled checks if page of feed item was crawled, items updated
+// within the refresh time since they were crawled are not
+func (s *feedSpider) crawled(i *gofeed.Item) bool {
+	item, err := models.GetCrawledItem(i.GUID, i.Link)
+	if err != nil {
+		return false
+	}
+	updated := i.UpdatedParsed
+	if updated == nil {
+		updated = i.PublishedParsed
+	}
+	refresh := s.crawler.refresh > 0 && updated != nil &&
+		time.Since(*updated) <= s.crawler.refresh && updated.After(item.Updated)
+	return !refresh
+}
+
+// body returns article text of page with the body selector of the spider,
+// the main content of the page is extracted when the selector is not
+// configured or doesn't match
+func (s *feedSpider) body(doc *goquery.Document) string {
+	if sel, _ := s.Config.Get("BodySelector", "").(string); sel != "" {
+		if body := strings.TrimSpace(doc.Find(sel).Text()); body != "" {
+			return body
+		}
+	}
+	return utils.ExtractContent(doc)
 }
 
-// Process process items from crawl
+// Process process items from crawl, items crawled before are skipped,
+// missing fields of items are taken from their pages. Items are
+// crawled once they are saved, unchanged pages are skipped
 func (s *feedSpider) process(r *crawlResponse) {
 	defer s.crawler.wg.Done()
 
 	feed := r.response.(*gofeed.Feed)
 	s.crawler.Logger.Infof("Found %v items at %s", len(feed.Items), r.link.url)
 
+	stats := s.crawler.spiderStats(s.Name)
 	for _, i := range feed.Items {
-		// delay to avoid ddos on news sites
-		time.Sleep(time.Second * delayInterval)
-
-		doc, err := utils.LinkToDoc(i.Link)
-
+		if s.crawled(i) {
+			atomic.AddUint64(&stats.Skipped, 1)
+			continue
+		}
+		// requests are rate limited by the fetcher
+		doc, err := s.crawler.fetcher.Document(i.Link)
+		if err == ErrNotModified {
+			atomic.AddUint64(&stats.Skipped, 1)
+			continue
+		}
 		if err != nil {
 			s.crawler.Logger.Debug(err)
+			atomic.AddUint64(&stats.Errors, 1)
 			continue
 		}
+		atomic.AddUint64(&stats.Fetched, 1)
 
 		meta, err := utils.ExtractMetaTags(doc, "og:")
 		if err != nil {
 			s.crawler.Logger.Debug(err)
+			atomic.AddUint64(&stats.Errors, 1)
 			continue
 		}
 
-		img := meta.Get("image", nil)
-		if img == nil {
+		am := utils.ExtractArticleMeta(doc)
+		if am.Image == "" && i.Image != nil {
+			am.Image = i.Image.URL
+		}
+		if am.Image == "" {
 			s.crawler.Logger.Debug("Image not found: ", meta)
+			atomic.AddUint64(&stats.NoImage, 1)
 			continue
 		}
 
@@ -94,134 +154,60 @@ func (s *feedSpider) process(r *crawlResponse) {
 			de := meta.Get("description", nil)
 			if de == nil {
 				s.crawler.Logger.Debug("description not found: ", meta)
+				atomic.AddUint64(&stats.NoDescription, 1)
 				continue
 			}
 			desc = de.(string)
 		}
+		// items of sitemaps and listing pages have no description
+		if desc == "" {
+			desc, _ = meta.Get("description", "").(string)
+		}
 
-		sel := s.Config.Get("BodySelector", nil)
-		body := doc.Find(sel.(string)).Text()
+		body := s.body(doc)
 		if body == "" {
+			atomic.AddUint64(&stats.EmptyBody, 1)
 			continue
 		}
 		ta := utils.NewTextAnalysis(body, desc)
 
-		article := models.NewArticle(i.Title, i.GUID, desc, i.Link, s.Domain, img.(string), i.PublishedParsed, ta.Tags())
+		title := i.Title
+		if title == "" {
+			title, _ = meta.Get("title", "").(string)
+		}
+		if title == "" {
+			title = strings.TrimSpace(doc.Find("title").First().Text())
+		}
+		published := i.PublishedParsed
+		if published == nil {
+			published = am.Published
+		}
+		article := models.NewArticle(title, i.GUID, desc, i.Link, s.Domain, am.Image, published, ta.Tags())
 		article.SetTopic(r.link.category, []string{})
 
-		if i.Author != nil {
+		article.Author = am.Author
+		if i.Author != nil && i.Author.Name != "" {
 			article.Author = i.Author.Name
 		}
 
 		article.Summary = ta.Sentences(3)
 		article.AddAssessment(ta)
 
-		_, er := models.GetArticle(article.ID)
-		if er != nil {
+		if old, er := models.GetArticle(article.ID); er != nil {
 			// found new article
-			atomic.AddUint64(&s.crawler.ops, 1)
+			atomic.AddUint64(&stats.New, 1)
+		} else {
+			article.StoryKey = old.StoryKey
 		}
 		article.Save()
+		atomic.AddUint64(&stats.Saved, 1)
+		// items are marked crawled once saved, dropped items are retried
+		models.SetCrawled(i.GUID, i.Link)
+		if _, err := article.AssignStory(ta); err != nil {
+			s.crawler.Logger.Error("failed to cluster article: ", err)
+		}
+		if err := article.Index(body); err != nil {
+			s.crawler.Logger.Error("failed to index article: ", err)
+		}
 	}
 }
-
-// newBBC creates new feed spider for bbc
-func newBBC() *feedSpider {
-	sp := newFeedSpider(
-		"bbc",
-		"bbc.com",
-		newLink(africaCategory, "http://feeds.bbci.co.uk/news/world/africa/rss.xml"),
-		newLink(worldCategory, "http://feeds.bbci.co.uk/news/world/rss.xml"),
-	)
-	sp.Config.Set("UseMetaDesc", false)
-	sp.Config.Set("BodySelector", ".story-body__inner p")
-
-	return sp
-}
-
-// newCitinews creates new feed spider for citi news
-func newCitinews() *feedSpider {
-	sp := newFeedSpider(
-		"citinewsroom",
-		"citinewsroom.com",
-		newLink(topStories, "https://citinewsroom.com/ghana-news/top-stories/feed/"),
-		newLink(politicsCategory, "https://citinewsroom.com/ghana-news/politics/feed/"),
-		newLink(sportsCategory, "https://citinewsroom.com/ghana-news/sports/feed/"),
-		newLink(businessCategory, "https://citinewsroom.com/ghana-news/business/feed/"),
-		newLink(entertainmentCategory, "https://citinewsroom.com/ghana-news/showbiz/feed/"),
-	)
-	sp.Config.Set("UseMetaDesc", true)
-	sp.Config.Set("BodySelector", ".entry-content p")
-
-	return sp
-}
-
-// newGhanaweb creates new feed spider for ghanaweb
-func newGhanaweb() *feedSpider {
-	sp := newFeedSpider(
-		"ghanaweb",
-		"ghanaweb.com",
-		newLink(topStories, "https://cdn.ghanaweb.com/feed/newsfeed.xml"),
-		newLink(sportsCategory, "https://cdn.ghanaweb.com/feed/soccerfeed.xml"),
-		newLink(sportsCategory, "https://cdn.ghanaweb.com/feed/other_sportsfeed.xml"),
-		newLink(entertainmentCategory, "https://cdn.ghanaweb.com/feed/entertainmentfeed.xml"),
-	)
-	sp.Config.Set("UseMetaDesc", false)
-	sp.Config.Set("BodySelector", "#medsection1 > p:nth-child(8)")
-
-	return sp
-}
-
-// newModernghana creates new feed spider for modernghana
-func newModernghana() *feedSpider {
-	sp := newFeedSpider(
-		"modernghana",
-		"modernghana.com",
-		newLink(topStories, "https://rss.modernghana.com/news.xml?cat_id=1&group_id=1"),
-		newLink(politicsCategory, "https://rss.modernghana.com/news.xml?cat_id=1&group_id=5"),
-		newLink(sportsCategory, "https://rss.modernghana.com/news.xml?cat_id=2"),
-		newLink(businessCategory, "https://rss.modernghana.com/news.xml?cat_id=1&group_id=6"),
-		newLink(entertainmentCategory, "https://rss.modernghana.com/news.xml?cat_id=3"),
-		newLink(worldCategory, "https://rss.modernghana.com/news.xml?cat_id=1&group_id=8"),
-		newLink(africaCategory, "https://rss.modernghana.com/news.xml?cat_id=1&group_id=2"),
-	)
-	sp.Config.Set("UseMetaDesc", false)
-	sp.Config.Set("BodySelector", ".blog-content p")
-
-	return sp
-}
-
-// newPulse creates new feed spider for pulse.com.gh
-func newPulse() *feedSpider {
-	sp := newFeedSpider(
-		"pulse",
-		"pulse.com.gh",
-		newLink(topStories, "http://www.pulse.com.gh/rss"),
-	)
-
-	sp.Config.Set("UseMetaDesc", false)
-	sp.Config.Set("BodySelector", ".article_text p")
-
-	return sp
-}
-
-// newMyjoyOnline creates new feed spider for MyjoyOnline
-func newMyjoyOnline() *feedSpider {
-	sp := newFeedSpider(
-		"myjoyonline",
-		"myjoyonline.com",
-		newLink(topStories, "https://www.myjoyonline.com/pages/rss/site_edition.xml"),
-		newLink(politicsCategory, "https://www.myjoyonline.com/pages/rss/site_politics.xml"),
-		newLink(worldCategory, "https://www.myjoyonline.com/pages/rss/site_world.xml"),
-		newLink(sportsCategory, "https://www.myjoyonline.com/pages/rss/site_sports.xml"),
-		newLink(businessCategory, "https://www.myjoyonline.com/pages/rss/site_business.xml"),
-		newLink(lifestyleCategory, "https://www.myjoyonline.com/pages/rss/site_lifestyle.xml"),
-		newLink(entertainmentCategory, "https://www.myjoyonline.com/pages/rss/site_entertainment.xml"),
-		newLink(techCategory, "https://www.myjoyonline.com/pages/rss/site_technology.xml"),
-	)
-
-	sp.Config.Set("UseMetaDesc", false)
-	sp.Config.Set("BodySelector", ".article-text p")
-
-	return sp
-}
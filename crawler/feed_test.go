@@ -0,0 +1,140 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/icrowley/fake"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessFeed(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	fetched := map[string]int{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetched[r.URL.Path]++
+		mu.Unlock()
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<html><head><meta property="og:image" content="http://%s/img.jpg"></head>
+			<body><div class="entry-content"><p>%s</p></div></body></html>`, r.Host, fake.SentencesN(5))
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.fetcher.Delay = 0
+	c.refresh = time.Hour
+	sp := newFeedSpider("test", "example.com", newLink("World", ts.URL+"/feed"))
+	sp.Config.Set("UseMetaDesc", false)
+	sp.Config.Set("BodySelector", ".entry-content p")
+	sp.setCrawler(c)
+
+	old := time.Now().Add(-2 * time.Hour)
+	var items []*gofeed.Item
+	for i := 0; i < 2; i++ {
+		link := fmt.Sprintf("%s/news/%s", ts.URL, fake.Characters())
+		items = append(items, &gofeed.Item{Title: fake.Sentence(), Link: link, GUID: link, PublishedParsed: &old})
+	}
+	process := func() *CrawlStats {
+		c.stats = map[string]*CrawlStats{}
+		c.wg.Add(1)
+		sp.process(&crawlResponse{sp, sp.Links[0], &gofeed.Feed{Items: items}})
+		return c.Stats()["test"]
+	}
+
+	assert.Equal(&CrawlStats{Fetched: 2, New: 2, Saved: 2}, process())
+	_, err := models.GetArticle(items[0].GUID)
+	assert.NoError(err)
+
+	// crawled items are skipped before fetching
+	assert.Equal(&CrawlStats{Skipped: 2}, process())
+	assert.Equal(1, fetched[items[0].Link[len(ts.URL):]])
+
+	// items updated since they were crawled are fetched again
+	updated := time.Now()
+	items[1].UpdatedParsed = &updated
+	assert.Equal(&CrawlStats{Fetched: 1, Skipped: 1, Saved: 1}, process())
+
+	item, err := models.GetCrawledItem("", items[1].Link)
+	assert.NoError(err)
+	assert.Equal(items[1].Link, item.Link)
+}
+
+func TestProcessFeedDropped(t *testing.T) {
+	assert := assert.New(t)
+
+	image := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<html><head>%s</head><body><div class="entry-content"><p>%s</p></div></body></html>`,
+			image, fake.SentencesN(5))
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.fetcher.Delay = 0
+	sp := newFeedSpider("test", "example.com", newLink("World", ts.URL+"/feed"))
+	sp.Config.Set("UseMetaDesc", false)
+	sp.Config.Set("BodySelector", ".entry-content p")
+	sp.setCrawler(c)
+
+	link := fmt.Sprintf("%s/news/%s", ts.URL, fake.Characters())
+	items := []*gofeed.Item{{Title: fake.Sentence(), Link: link, GUID: link}}
+	process := func() *CrawlStats {
+		c.stats = map[string]*CrawlStats{}
+		c.wg.Add(1)
+		sp.process(&crawlResponse{sp, sp.Links[0], &gofeed.Feed{Items: items}})
+		return c.Stats()["test"]
+	}
+
+	// items dropped without an image are crawled again
+	assert.Equal(&CrawlStats{Fetched: 1, NoImage: 1}, process())
+	_, err := models.GetCrawledItem(link, link)
+	assert.Error(err)
+
+	image = fmt.Sprintf(`<meta property="og:image" content="%s/img.jpg">`, ts.URL)
+	assert.Equal(&CrawlStats{Fetched: 1, New: 1, Saved: 1}, process())
+	_, err = models.GetCrawledItem(link, link)
+	assert.NoError(err)
+}
+
+func TestFeedSpiderBody(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(`<html><body>
+		<div class="story"><p class="lead">Floods hit Accra after heavy rains on Monday night.</p>
+		<p>Rescue teams, soldiers and volunteers were deployed to the worst affected areas of the city.</p>
+		<p>Residents called on the government to clear the drains before the rainy season.</p></div>
+		</body></html>`))
+	assert.NoError(err)
+
+	sp := newFeedSpider("test", "example.com")
+	sp.Config.Set("BodySelector", ".lead")
+	assert.Equal("Floods hit Accra after heavy rains on Monday night.", sp.body(doc))
+
+	// the main content is extracted when the selector doesn't match
+	sp.Config.Set("BodySelector", "#medsection1 > p:nth-child(8)")
+	body := sp.body(doc)
+	assert.Contains(body, "Floods hit Accra")
+	assert.Contains(body, "clear the drains")
+
+	sp.Config.Remove("BodySelector")
+	assert.Equal(body, sp.body(doc))
+}
@@ -0,0 +1,403 @@
+package crawler
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+const (
+	defaultUserAgent   = "newsbot/1.0"
+	defaultConcurrency = 2
+	defaultFetchDelay  = time.Second
+	defaultFetchSize   = 5 << 20
+	defaultFetchTime   = 30 * time.Second
+	// robotsTTL how long robots.txt of hosts is cached
+	robotsTTL = 24 * time.Hour
+	// robotsRetry how long hosts are not crawled when robots.txt is unreachable
+	robotsRetry = 10 * time.Minute
+	// maxValidators maximum number of urls whose validators are kept
+	maxValidators = 20000
+)
+
+// Fetch errors
+var (
+	ErrNotModified = errors.New("not modified")
+	ErrDisallowed  = errors.New("disallowed by robots.txt")
+	ErrTooLarge    = errors.New("response too large")
+)
+
+// Fetcher http client shared by spiders, requests to a host are limited to
+// Concurrency at a time and one every Delay or the robots.txt crawl delay,
+// urls disallowed by robots.txt are not fetched and unchanged responses
+// are not downloaded again
+type Fetcher struct {
+	UserAgent string
+	// Concurrency maximum number of parallel requests to a host
+	Concurrency int
+	// Delay minimum time between requests to a host
+	Delay time.Duration
+	// MaxSize maximum size of responses in bytes
+	MaxSize int64
+	client  *http.Client
+	mu      sync.Mutex
+	hosts   map[string]*hostState
+	// validators etag and last modified time of fetched urls
+	validators map[string]*validator
+	now        func() time.Time
+	sleep      func(time.Duration)
+}
+
+// hostState limits and robots.txt rules of a host
+type hostState struct {
+	sem    chan bool
+	mu     sync.Mutex
+	next   time.Time
+	robots *robotsRules
+	// expires when robots.txt is fetched again
+	expires time.Time
+	// fetching is closed when the robots.txt being fetched is stored
+	fetching chan bool
+}
+
+type validator struct {
+	etag         string
+	lastModified string
+}
+
+// NewFetcher returns fetcher configured with CRAWL_USER_AGENT, CRAWL_CONCURRENCY,
+// CRAWL_DELAY, CRAWL_MAX_SIZE and CRAWL_TIMEOUT
+func NewFetcher() *Fetcher {
+	f := &Fetcher{
+		UserAgent:   defaultUserAgent,
+		Concurrency: defaultConcurrency,
+		Delay:       defaultFetchDelay,
+		MaxSize:     defaultFetchSize,
+		client:      &http.Client{Timeout: defaultFetchTime},
+		hosts:       map[string]*hostState{},
+		validators:  map[string]*validator{},
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+	if ua := os.Getenv("CRAWL_USER_AGENT"); ua != "" {
+		f.UserAgent = ua
+	}
+	if n, err := strconv.Atoi(os.Getenv("CRAWL_CONCURRENCY")); err == nil && n > 0 {
+		f.Concurrency = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("CRAWL_DELAY")); err == nil && d >= 0 {
+		f.Delay = d
+	}
+	if n, err := strconv.ParseInt(os.Getenv("CRAWL_MAX_SIZE"), 10, 64); err == nil && n > 0 {
+		f.MaxSize = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("CRAWL_TIMEOUT")); err == nil && d > 0 {
+		f.client.Timeout = d
+	}
+	return f
+}
+
+// Feed fetches and parses feed at url
+func (f *Fetcher) Feed(u string) (*gofeed.Feed, error) {
+	body, err := f.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	return gofeed.NewParser().Parse(bytes.NewReader(body))
+}
+
+// Document fetches and parses html page at url
+func (f *Fetcher) Document(u string) (*goquery.Document, error) {
+	body, err := f.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	doc.Url, _ = url.Parse(u)
+	return doc, nil
+}
+
+// Fetch returns body of url, ErrNotModified is returned
+// when url didn't change since it was last fetched
+func (f *Fetcher) Fetch(rawurl string) ([]byte, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return nil, err
+	}
+	h := f.host(u.Host)
+	rules := f.robots(h, u)
+	if !rules.allowed(u.RequestURI()) {
+		return nil, ErrDisallowed
+	}
+
+	f.acquire(h, rules.delay)
+	defer f.release(h)
+
+	req, err := http.NewRequest("GET", rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.UserAgent)
+	f.mu.Lock()
+	if v, ok := f.validators[rawurl]; ok {
+		if v.etag != "" {
+			req.Header.Set("If-None-Match", v.etag)
+		}
+		if v.lastModified != "" {
+			req.Header.Set("If-Modified-Since", v.lastModified)
+		}
+	}
+	f.mu.Unlock()
+
+	res, err := f.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode == http.StatusNotModified:
+		return nil, ErrNotModified
+	case res.StatusCode != http.StatusOK:
+		return nil, fmt.Errorf("%s: %s", rawurl, res.Status)
+	case res.ContentLength > f.MaxSize:
+		return nil, ErrTooLarge
+	}
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, f.MaxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(body)) > f.MaxSize {
+		return nil, ErrTooLarge
+	}
+	f.setValidator(rawurl, res.Header)
+	return body, nil
+}
+
+// setValidator remembers validators of url from response headers
+func (f *Fetcher) setValidator(rawurl string, header http.Header) {
+	v := &validator{etag: header.Get("ETag"), lastModified: header.Get("Last-Modified")}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if v.etag == "" && v.lastModified == "" {
+		delete(f.validators, rawurl)
+		return
+	}
+	if len(f.validators) >= maxValidators {
+		f.validators = map[string]*validator{}
+	}
+	f.validators[rawurl] = v
+}
+
+func (f *Fetcher) host(name string) *hostState {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h, ok := f.hosts[name]
+	if !ok {
+		h = &hostState{sem: make(chan bool, f.Concurrency)}
+		f.hosts[name] = h
+	}
+	return h
+}
+
+// acquire waits for a free slot of host and its next request time
+func (f *Fetcher) acquire(h *hostState, crawlDelay time.Duration) {
+	h.sem <- true
+	delay := f.Delay
+	if crawlDelay > delay {
+		delay = crawlDelay
+	}
+
+	h.mu.Lock()
+	now := f.now()
+	wait := h.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	h.next = now.Add(wait + delay)
+	h.mu.Unlock()
+	f.sleep(wait)
+}
+
+func (f *Fetcher) release(h *hostState) {
+	<-h.sem
+}
+
+// robots returns robots.txt rules of host of u, they're fetched again
+// when they expire by one request of the host while others wait
+func (f *Fetcher) robots(h *hostState, u *url.URL) *robotsRules {
+	for {
+		h.mu.Lock()
+		now := f.now()
+		if h.robots != nil && now.Before(h.expires) {
+			rules := h.robots
+			h.mu.Unlock()
+			return rules
+		}
+		if wait := h.fetching; wait != nil {
+			// another request of the host is fetching robots.txt
+			h.mu.Unlock()
+			<-wait
+			continue
+		}
+		done := make(chan bool)
+		h.fetching = done
+		h.mu.Unlock()
+
+		rules, expires := f.fetchRobots(u, now)
+		h.mu.Lock()
+		h.robots, h.expires, h.fetching = rules, expires, nil
+		h.mu.Unlock()
+		close(done)
+		return rules
+	}
+}
+
+// fetchRobots fetches robots.txt of the host of u, it returns
+// its rules and when they expire
+func (f *Fetcher) fetchRobots(u *url.URL, now time.Time) (*robotsRules, time.Time) {
+	robotsURL := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/robots.txt"}
+	req, _ := http.NewRequest("GET", robotsURL.String(), nil)
+	req.Header.Set("User-Agent", f.UserAgent)
+	res, err := f.client.Do(req)
+	if res != nil {
+		defer res.Body.Close()
+	}
+
+	switch {
+	case err != nil || res.StatusCode >= 500:
+		// unreachable robots.txt disallows the host for a while
+		return &robotsRules{disallowAll: true}, now.Add(robotsRetry)
+	case res.StatusCode != http.StatusOK:
+		return &robotsRules{}, now.Add(robotsTTL)
+	default:
+		return parseRobots(io.LimitReader(res.Body, f.MaxSize), f.UserAgent), now.Add(robotsTTL)
+	}
+}
+
+// robotsRules rules of robots.txt for the user agent of the fetcher
+type robotsRules struct {
+	rules       []*robotsRule
+	delay       time.Duration
+	disallowAll bool
+}
+
+type robotsRule struct {
+	allow   bool
+	length  int
+	pattern *regexp.Regexp
+}
+
+// allowed checks if path is allowed, the longest
+// matching rule applies and allow rules win ties
+func (r *robotsRules) allowed(path string) bool {
+	if r.disallowAll {
+		return false
+	}
+	allow, length := true, -1
+	for _, rule := range r.rules {
+		if !rule.pattern.MatchString(path) {
+			continue
+		}
+		if rule.length > length || (rule.length == length && rule.allow) {
+			allow, length = rule.allow, rule.length
+		}
+	}
+	return allow
+}
+
+// parseRobots parses robots.txt, the groups of the product token of
+// userAgent are used or the groups of all user agents when there's none
+func parseRobots(r io.Reader, userAgent string) *robotsRules {
+	token := strings.ToLower(strings.SplitN(userAgent, "/", 2)[0])
+	own, all := &robotsRules{}, &robotsRules{}
+	hasOwn := false
+
+	var group []*robotsRules
+	inAgents := false
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		value := strings.TrimSpace(parts[1])
+
+		if key == "user-agent" {
+			if !inAgents {
+				group = nil
+			}
+			inAgents = true
+			agent := strings.ToLower(value)
+			switch {
+			case agent == "*":
+				group = append(group, all)
+			case agent != "" && strings.Contains(token, agent):
+				group = append(group, own)
+				hasOwn = true
+			}
+			continue
+		}
+		inAgents = false
+		for _, rules := range group {
+			rules.add(key, value)
+		}
+	}
+	if hasOwn {
+		return own
+	}
+	return all
+}
+
+// add adds a rule or crawl delay of a robots.txt line
+func (r *robotsRules) add(key, value string) {
+	switch key {
+	case "allow", "disallow":
+		if value == "" {
+			return
+		}
+		r.rules = append(r.rules, &robotsRule{
+			allow:   key == "allow",
+			length:  len(value),
+			pattern: robotsPattern(value),
+		})
+	case "crawl-delay":
+		if s, err := strconv.ParseFloat(value, 64); err == nil && s > 0 {
+			r.delay = time.Duration(s * float64(time.Second))
+		}
+	}
+}
+
+// robotsPattern compiles path pattern with * wildcards and $ end anchors
+func robotsPattern(p string) *regexp.Regexp {
+	end := strings.HasSuffix(p, "$")
+	p = strings.TrimSuffix(p, "$")
+	expr := "^" + strings.Replace(regexp.QuoteMeta(p), `\*`, ".*", -1)
+	if end {
+		expr += "$"
+	}
+	return regexp.MustCompile(expr)
+}
@@ -0,0 +1,207 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testRobots = `
+User-agent: *
+Disallow: /
+
+# newsbot may crawl news
+User-agent: Googlebot
+User-agent: NewsBot
+Allow: /private/news
+Disallow: /private
+Disallow: /*.pdf$
+Crawl-delay: 0.05
+`
+
+func TestParseRobots(t *testing.T) {
+	assert := assert.New(t)
+
+	rules := parseRobots(strings.NewReader(testRobots), "newsbot/1.0 (+http://example.com)")
+	assert.Equal(50*time.Millisecond, rules.delay)
+	assert.True(rules.allowed("/news/1"))
+	assert.True(rules.allowed("/private/news/1"))
+	assert.False(rules.allowed("/private/1"))
+	assert.False(rules.allowed("/files/report.pdf"))
+	assert.True(rules.allowed("/files/report.pdf?page=1"))
+
+	rules = parseRobots(strings.NewReader(testRobots), "otherbot")
+	assert.False(rules.allowed("/news/1"))
+
+	rules = parseRobots(strings.NewReader("User-agent: *\nDisallow:\n"), "otherbot")
+	assert.True(rules.allowed("/news/1"))
+}
+
+func TestFetcher(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	active, maxActive := 0, 0
+	agents := map[string]bool{}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		agents[r.UserAgent()] = true
+		active++
+		if active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+		defer func() {
+			mu.Lock()
+			active--
+			mu.Unlock()
+		}()
+
+		switch r.URL.Path {
+		case "/robots.txt":
+			fmt.Fprint(w, testRobots)
+		case "/large":
+			fmt.Fprint(w, strings.Repeat("a", 1000))
+		case "/feed":
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, `<rss version="2.0"><channel><title>News</title><item><title>Floods hit Accra</title><link>http://example.com/1</link></item></channel></rss>`)
+		default:
+			time.Sleep(10 * time.Millisecond)
+			fmt.Fprint(w, `<html><body><p class="text">Floods hit Accra</p></body></html>`)
+		}
+	}))
+	defer ts.Close()
+
+	f := NewFetcher()
+	f.UserAgent = "newsbot/1.0"
+	f.Concurrency = 1
+	f.Delay = 0
+	f.MaxSize = 500
+
+	feed, err := f.Feed(ts.URL + "/feed")
+	assert.NoError(err)
+	if assert.NotNil(feed) {
+		assert.Len(feed.Items, 1)
+	}
+	_, err = f.Feed(ts.URL + "/feed")
+	assert.Equal(ErrNotModified, err)
+
+	_, err = f.Fetch(ts.URL + "/private/1")
+	assert.Equal(ErrDisallowed, err)
+	_, err = f.Fetch(ts.URL + "/large")
+	assert.Equal(ErrTooLarge, err)
+	_, err = f.Fetch(ts.URL + "/missing.pdf?x=1")
+	assert.NoError(err)
+
+	// requests to a host are sequential and one every crawl delay
+	var slept time.Duration
+	f.sleep = func(d time.Duration) {
+		mu.Lock()
+		slept += d
+		mu.Unlock()
+		time.Sleep(d)
+	}
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			doc, err := f.Document(fmt.Sprintf("%s/news/%d", ts.URL, i))
+			if assert.NoError(err) {
+				assert.Equal("Floods hit Accra", doc.Find(".text").Text())
+			}
+		}(i)
+	}
+	wg.Wait()
+	assert.Equal(1, maxActive)
+	assert.True(slept >= 100*time.Millisecond, "slept %v", slept)
+	assert.Equal(map[string]bool{"newsbot/1.0": true}, agents)
+}
+
+func TestFetcherRobotsUnreachable(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	defer ts.Close()
+
+	f := NewFetcher()
+	_, err := f.Fetch(ts.URL + "/news")
+	assert.Equal(ErrDisallowed, err)
+
+	// robots.txt is fetched again after a while
+	f.now = func() time.Time { return time.Now().Add(robotsRetry) }
+	ts.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "ok")
+	})
+	body, err := f.Fetch(ts.URL + "/news")
+	assert.NoError(err)
+	assert.Equal("ok", string(body))
+}
+
+func TestFetcherRobotsOnce(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	fetches := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		fetches++
+		mu.Unlock()
+		time.Sleep(50 * time.Millisecond)
+		fmt.Fprint(w, "User-agent: *\nDisallow: /private\n")
+	}))
+	defer ts.Close()
+
+	// requests of a host wait for the robots.txt being fetched
+	f := NewFetcher()
+	u, _ := url.Parse(ts.URL + "/news")
+	h := f.host(u.Host)
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rules := f.robots(h, u)
+			assert.False(rules.allowed("/private/1"))
+		}()
+	}
+	wg.Wait()
+	mu.Lock()
+	assert.Equal(1, fetches)
+	mu.Unlock()
+
+	// the host isn't locked while robots.txt is fetched again
+	f.now = func() time.Time { return time.Now().Add(robotsTTL) }
+	go f.robots(h, u)
+	time.Sleep(10 * time.Millisecond)
+	start := time.Now()
+	h.mu.Lock()
+	h.mu.Unlock()
+	assert.True(time.Since(start) < 25*time.Millisecond)
+
+	f.robots(h, u)
+	mu.Lock()
+	assert.Equal(2, fetches)
+	mu.Unlock()
+}
@@ -0,0 +1,127 @@
+package crawler
+
+import (
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// JSONFields dot separated paths of the article fields of
+// items of JSON endpoints, list items are selected by index
+type JSONFields struct {
+	// Items path of the list of items, the response when empty
+	Items       string `json:"items" yaml:"items"`
+	Link        string `json:"link" yaml:"link"`
+	Title       string `json:"title" yaml:"title"`
+	Description string `json:"description" yaml:"description"`
+	Image       string `json:"image" yaml:"image"`
+	Author      string `json:"author" yaml:"author"`
+	Published   string `json:"published" yaml:"published"`
+	Updated     string `json:"updated" yaml:"updated"`
+	// GUID path of unique ids of items, the link when empty
+	GUID string `json:"guid" yaml:"guid"`
+}
+
+// jsonSpider crawls articles of JSON endpoints
+type jsonSpider struct {
+	*feedSpider
+	Fields *JSONFields
+}
+
+func (s *jsonSpider) makeRequest(l *link) {
+	s.request(s, l, s.items)
+}
+
+// items fetches items of JSON endpoint at url as feed
+func (s *jsonSpider) items(u string) (*gofeed.Feed, error) {
+	body, err := s.crawler.fetcher.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	var data interface{}
+	if err := json.Unmarshal(body, &data); err != nil {
+		return nil, err
+	}
+
+	feed := &gofeed.Feed{Link: u}
+	list, _ := jsonValue(data, s.Fields.Items).([]interface{})
+	for _, v := range list {
+		link := jsonString(v, s.Fields.Link)
+		if link == "" {
+			continue
+		}
+		item := &gofeed.Item{
+			Title:           jsonText(v, s.Fields.Title),
+			Link:            link,
+			GUID:            link,
+			Description:     jsonText(v, s.Fields.Description),
+			PublishedParsed: utils.ParseDate(jsonString(v, s.Fields.Published)),
+			UpdatedParsed:   utils.ParseDate(jsonString(v, s.Fields.Updated)),
+		}
+		if guid := jsonString(v, s.Fields.GUID); guid != "" {
+			item.GUID = guid
+		}
+		if img := jsonString(v, s.Fields.Image); img != "" {
+			item.Image = &gofeed.Image{URL: img}
+		}
+		if author := jsonText(v, s.Fields.Author); author != "" {
+			item.Author = &gofeed.Person{Name: author}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+// jsonValue returns value at dot separated path of data
+func jsonValue(data interface{}, path string) interface{} {
+	if path == "" {
+		return data
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch v := data.(type) {
+		case map[string]interface{}:
+			data = v[key]
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil
+			}
+			data = v[i]
+		default:
+			return nil
+		}
+	}
+	return data
+}
+
+// jsonString returns string or number at path of data
+func jsonString(data interface{}, path string) string {
+	if path == "" {
+		return ""
+	}
+	switch v := jsonValue(data, path).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return ""
+}
+
+// jsonText returns string at path of data without html tags and entities
+func jsonText(data interface{}, path string) string {
+	text := jsonString(data, path)
+	if !strings.ContainsAny(text, "<&") {
+		return text
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(text))
+	if err != nil {
+		return text
+	}
+	return strings.TrimSpace(doc.Text())
+}
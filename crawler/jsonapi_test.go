@@ -0,0 +1,65 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONSpider(t *testing.T) {
+	assert := assert.New(t)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/robots.txt" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"posts": [
+			{"id": 101, "link": "https://example.com/floods", "title": {"rendered": "Floods hit Accra &#8211; NADMO"},
+			 "excerpt": {"rendered": "<p>Heavy rains flooded <b>Accra</b>.</p>"}, "date_gmt": "2018-06-19T08:00:00",
+			 "media": [{"url": "https://example.com/floods.jpg"}], "author": {"name": "Ama Mensah"}},
+			{"id": 102, "title": {"rendered": "No link"}}
+		]}}`)
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.fetcher.Delay = 0
+	sp := &jsonSpider{newFeedSpider("json", "example.com"), &JSONFields{
+		Items:       "data.posts",
+		Link:        "link",
+		Title:       "title.rendered",
+		Description: "excerpt.rendered",
+		Image:       "media.0.url",
+		Author:      "author.name",
+		Published:   "date_gmt",
+		GUID:        "id",
+	}}
+
+	r := request(c, sp, newLink("Top stories", ts.URL+"/wp-json/wp/v2/posts"))
+	if !assert.NotNil(r) {
+		return
+	}
+	items := r.response.(*gofeed.Feed).Items
+	if assert.Len(items, 1) {
+		item := items[0]
+		assert.Equal("101", item.GUID)
+		assert.Equal("https://example.com/floods", item.Link)
+		assert.Equal("Floods hit Accra – NADMO", item.Title)
+		assert.Equal("Heavy rains flooded Accra.", item.Description)
+		assert.Equal("https://example.com/floods.jpg", item.Image.URL)
+		assert.Equal("Ama Mensah", item.Author.Name)
+		assert.Equal("2018-06-19 08:00:00 +0000 UTC", item.PublishedParsed.String())
+	}
+
+	// items are not found at an invalid path
+	sp.Fields.Items = "data.articles"
+	r = request(c, sp, newLink("Top stories", ts.URL+"/wp-json/wp/v2/pages"))
+	if assert.NotNil(r) {
+		assert.Empty(r.response.(*gofeed.Feed).Items)
+	}
+}
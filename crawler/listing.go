@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// defaultListingPages number of listing pages crawled by default
+const defaultListingPages = 1
+
+// Listing selectors of article links and pagination of html listing pages
+type Listing struct {
+	// LinkSelector css selector of article links, the
+	// first link of elements which are not links is used
+	LinkSelector string `json:"link_selector" yaml:"link_selector"`
+	// NextSelector css selector of the link to the next page
+	NextSelector string `json:"next_selector" yaml:"next_selector"`
+	// MaxPages maximum number of pages crawled
+	MaxPages int `json:"max_pages" yaml:"max_pages"`
+}
+
+// listingSpider crawls articles linked from html listing pages
+type listingSpider struct {
+	*feedSpider
+	Listing *Listing
+}
+
+func (s *listingSpider) makeRequest(l *link) {
+	s.request(s, l, s.links)
+}
+
+// links fetches article links of listing page at url and
+// its next pages as feed, links are found once
+func (s *listingSpider) links(u string) (*gofeed.Feed, error) {
+	maxPages := s.Listing.MaxPages
+	if maxPages <= 0 {
+		maxPages = defaultListingPages
+	}
+	feed := &gofeed.Feed{Link: u}
+	seen := map[string]bool{u: true}
+
+	for page := 0; page < maxPages && u != ""; page++ {
+		doc, err := s.crawler.fetcher.Document(u)
+		if err != nil {
+			if page == 0 {
+				return nil, err
+			}
+			s.crawler.Logger.Debugf("%s: %v", u, err)
+			break
+		}
+
+		doc.Find(s.Listing.LinkSelector).Each(func(i int, sel *goquery.Selection) {
+			if !sel.Is("a") {
+				sel = sel.Find("a").First()
+			}
+			link := absURL(doc, sel.AttrOr("href", ""))
+			if link == "" || seen[link] {
+				return
+			}
+			seen[link] = true
+			title := strings.TrimSpace(sel.Text())
+			if title == "" {
+				title = strings.TrimSpace(sel.AttrOr("title", ""))
+			}
+			feed.Items = append(feed.Items, &gofeed.Item{Title: title, Link: link, GUID: link})
+		})
+
+		u = ""
+		if s.Listing.NextSelector != "" {
+			next := absURL(doc, doc.Find(s.Listing.NextSelector).First().AttrOr("href", ""))
+			if next != "" && !seen[next] {
+				seen[next] = true
+				u = next
+			}
+		}
+	}
+	return feed, nil
+}
+
+// absURL resolves href with the url of doc, only http urls are returned
+func absURL(doc *goquery.Document, href string) string {
+	href = strings.TrimSpace(href)
+	if href == "" || doc.Url == nil {
+		return ""
+	}
+	u, err := doc.Url.Parse(href)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	u.Fragment = ""
+	return u.String()
+}
@@ -0,0 +1,67 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/epigos/newsbot/models"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListingSpider(t *testing.T) {
+	assert := assert.New(t)
+
+	pages := map[string]string{
+		"/news": `<ul class="news-list">
+			<li><h2><a href="/news/floods">Floods hit Accra</a></h2></li>
+			<li><h2><a href="http://{host}/news/fuel#comments">Fuel prices go up</a></h2></li>
+			<li><h2><a href="javascript:void(0)">Subscribe</a></h2></li>
+			</ul><a class="next" href="/news?page=2">Next</a>`,
+		"/news?page=2": `<ul class="news-list">
+			<li><h2><a href="/news/fuel">Fuel prices go up</a></h2></li>
+			<li><h2><a href="/news/budget" title="Budget reading"><img src="/budget.jpg"></a></h2></li>
+			</ul><a class="next" href="/news?page=3">Next</a>`,
+		"/news?page=3": `<ul class="news-list"><li><h2><a href="/news/old">Old news</a></h2></li></ul>`,
+	}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		page, ok := pages[r.URL.RequestURI()]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, "<html><body>"+strings.Replace(page, "{host}", r.Host, 1)+"</body></html>")
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.fetcher.Delay = 0
+	sp := &listingSpider{newFeedSpider("listing", "example.com"), &Listing{
+		LinkSelector: ".news-list h2",
+		NextSelector: "a.next",
+		MaxPages:     2,
+	}}
+
+	r := request(c, sp, newLink("Top stories", ts.URL+"/news"))
+	if !assert.NotNil(r) {
+		return
+	}
+	var links, titles []string
+	for _, item := range r.response.(*gofeed.Feed).Items {
+		links = append(links, item.Link)
+		titles = append(titles, item.Title)
+	}
+	assert.Equal([]string{ts.URL + "/news/floods", ts.URL + "/news/fuel", ts.URL + "/news/budget"}, links)
+	assert.Equal([]string{"Floods hit Accra", "Fuel prices go up", "Budget reading"}, titles)
+
+	// failed listing pages are reported as feed errors
+	assert.Nil(request(c, sp, newLink("Top stories", ts.URL+"/missing")))
+	feeds := c.Stats()["listing"].feeds
+	if assert.Len(feeds, 2) {
+		assert.Equal(models.FeedError, feeds[1].Status)
+	}
+}
@@ -0,0 +1,64 @@
+package crawler
+
+import (
+	"expvar"
+	"sort"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+)
+
+// defaultAlertRuns consecutive runs without articles after which a spider is reported
+const defaultAlertRuns = 3
+
+// spiderAlerts counts alerts of failing spiders by name
+var spiderAlerts = expvar.NewMap("crawler.spider_alerts")
+
+// spiderRun returns results of spider name
+func (s *CrawlStats) spiderRun(name string) models.SpiderRun {
+	st := s.copy()
+	return models.SpiderRun{
+		Name:          name,
+		Fetched:       int(st.Fetched),
+		Skipped:       int(st.Skipped),
+		New:           int(st.New),
+		Saved:         int(st.Saved),
+		Errors:        int(st.Errors),
+		NoImage:       int(st.NoImage),
+		NoDescription: int(st.NoDescription),
+		EmptyBody:     int(st.EmptyBody),
+		Feeds:         st.feeds,
+	}
+}
+
+// record saves the current run finished at end, spiders which are
+// unhealthy in alertRuns consecutive runs are reported once
+func (c *Crawler) record(end time.Time) *models.CrawlRun {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	run := &models.CrawlRun{Started: c.started, Duration: end.Sub(c.started).Seconds()}
+	names := make([]string, 0, len(c.stats))
+	for name := range c.stats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		sr := c.stats[name].spiderRun(name)
+		if sr.Healthy() {
+			c.failures[name] = 0
+		} else {
+			c.failures[name]++
+		}
+		sr.Failures = c.failures[name]
+		if sr.Failures == c.alertRuns {
+			c.Logger.Errorf("Spider %s yielded no articles in %d runs: %v", name, sr.Failures, c.stats[name])
+			spiderAlerts.Add(name, 1)
+		}
+		c.Logger.Infof("Done crawling %s: %v", name, c.stats[name])
+		run.Spiders = append(run.Spiders, sr)
+	}
+	run.Save()
+	return run
+}
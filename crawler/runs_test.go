@@ -0,0 +1,56 @@
+package crawler
+
+import (
+	"expvar"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecord(t *testing.T) {
+	assert := assert.New(t)
+
+	c := New()
+	c.alertRuns = 2
+	crawl := func(saved uint64) *models.CrawlRun {
+		c.started = time.Now()
+		c.stats = map[string]*CrawlStats{}
+		ok, down := c.spiderStats("ok"), c.spiderStats("down")
+		atomic.AddUint64(&ok.Saved, saved)
+		atomic.AddUint64(&down.Fetched, 2)
+		atomic.AddUint64(&down.NoImage, 2)
+		down.addFeed(newLink("World", "http://example.com/feed"), models.FeedOK, nil, 2)
+		return c.record(c.started.Add(3 * time.Second))
+	}
+
+	alerts := func() int64 {
+		if v := spiderAlerts.Get("down"); v != nil {
+			return v.(*expvar.Int).Value()
+		}
+		return 0
+	}
+	before := alerts()
+
+	run := crawl(1)
+	assert.NotEmpty(run.ID)
+	assert.Equal(3.0, run.Duration)
+	if assert.Len(run.Spiders, 2) {
+		down := run.Spiders[0]
+		assert.Equal("down", down.Name)
+		assert.Equal(2, down.NoImage)
+		assert.Equal(1, down.Failures)
+		assert.Equal([]models.FeedRun{{URL: "http://example.com/feed", Category: "World", Status: models.FeedOK, Items: 2}}, down.Feeds)
+		assert.Equal(0, run.Spiders[1].Failures)
+	}
+	assert.Equal(before, alerts())
+
+	// spiders are reported once after consecutive failing runs
+	crawl(1)
+	assert.Equal(before+1, alerts())
+	run = crawl(1)
+	assert.Equal(3, run.Spiders[0].Failures)
+	assert.Equal(before+1, alerts())
+}
@@ -0,0 +1,94 @@
+package crawler
+
+import (
+	"encoding/xml"
+	"strings"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// maxSitemaps maximum number of sitemaps of a sitemap index which are crawled
+const maxSitemaps = 5
+
+// sitemapSpider crawls articles of Google News sitemaps
+type sitemapSpider struct {
+	*feedSpider
+}
+
+// sitemap urls of a sitemap or sitemaps of a sitemap index
+type sitemap struct {
+	URLs []struct {
+		Loc     string `xml:"loc"`
+		LastMod string `xml:"lastmod"`
+		News    struct {
+			Title           string `xml:"title"`
+			PublicationDate string `xml:"publication_date"`
+		} `xml:"news"`
+		Image string `xml:"image>loc"`
+	} `xml:"url"`
+	Sitemaps []struct {
+		Loc string `xml:"loc"`
+	} `xml:"sitemap"`
+}
+
+func (s *sitemapSpider) makeRequest(l *link) {
+	s.request(s, l, s.sitemap)
+}
+
+// sitemap fetches sitemap at url as feed, the first
+// sitemaps of sitemap indexes are fetched
+func (s *sitemapSpider) sitemap(u string) (*gofeed.Feed, error) {
+	sm, err := s.fetchSitemap(u)
+	if err != nil {
+		return nil, err
+	}
+	feed := &gofeed.Feed{Link: u}
+	for i, child := range sm.Sitemaps {
+		if i == maxSitemaps {
+			break
+		}
+		loc := strings.TrimSpace(child.Loc)
+		csm, err := s.fetchSitemap(loc)
+		if err != nil {
+			// items of failed sitemaps are reported with their sitemap
+			s.crawler.Logger.Debugf("%s: %v", loc, err)
+			s.crawler.spiderStats(s.Name).addFeed(&link{url: loc}, models.FeedError, err, 0)
+			continue
+		}
+		sm.URLs = append(sm.URLs, csm.URLs...)
+	}
+
+	for _, entry := range sm.URLs {
+		loc := strings.TrimSpace(entry.Loc)
+		if loc == "" {
+			continue
+		}
+		item := &gofeed.Item{
+			Title:           strings.TrimSpace(entry.News.Title),
+			Link:            loc,
+			GUID:            loc,
+			PublishedParsed: utils.ParseDate(entry.News.PublicationDate),
+			UpdatedParsed:   utils.ParseDate(entry.LastMod),
+		}
+		if item.PublishedParsed == nil {
+			item.PublishedParsed = item.UpdatedParsed
+		}
+		if img := strings.TrimSpace(entry.Image); img != "" {
+			item.Image = &gofeed.Image{URL: img}
+		}
+		feed.Items = append(feed.Items, item)
+	}
+	return feed, nil
+}
+
+func (s *sitemapSpider) fetchSitemap(u string) (*sitemap, error) {
+	body, err := s.crawler.fetcher.Fetch(u)
+	if err != nil {
+		return nil, err
+	}
+	sm := &sitemap{}
+	return sm, xml.Unmarshal(body, sm)
+}
@@ -0,0 +1,85 @@
+package crawler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+
+	"github.com/icrowley/fake"
+	"github.com/mmcdole/gofeed"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSitemapSpider(t *testing.T) {
+	assert := assert.New(t)
+
+	path := "/" + fake.Characters()
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/robots.txt":
+			w.WriteHeader(http.StatusNotFound)
+		case "/sitemap_index.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+				<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+				<sitemap><loc>http://%[1]s/news.xml</loc></sitemap>
+				<sitemap><loc>http://%[1]s/broken.xml</loc></sitemap></sitemapindex>`, r.Host)
+		case "/broken.xml":
+			fmt.Fprint(w, `<urlset><url><loc>`)
+		case "/news.xml":
+			fmt.Fprintf(w, `<?xml version="1.0" encoding="UTF-8"?>
+				<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9"
+					xmlns:news="http://www.google.com/schemas/sitemap-news/0.9"
+					xmlns:image="http://www.google.com/schemas/sitemap-image/1.1">
+				<url><loc>http://%[1]s%[2]s</loc>
+					<news:news><news:publication><news:name>Example</news:name></news:publication>
+					<news:publication_date>2018-06-19T08:00:00Z</news:publication_date>
+					<news:title>Floods hit Accra</news:title></news:news>
+					<image:image><image:loc>http://%[1]s/img.jpg</image:loc></image:image></url>
+				<url><loc>http://%[1]s/about</loc><lastmod>2018-06-18</lastmod></url>
+				</urlset>`, r.Host, path)
+		default:
+			fmt.Fprintf(w, `<html><body><div class="story"><p>%s</p><p>%s</p></div></body></html>`, fake.SentencesN(5), fake.SentencesN(5))
+		}
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.fetcher.Delay = 0
+	sp := &sitemapSpider{newFeedSpider("sitemap", "example.com")}
+
+	r := request(c, sp, newLink("Top stories", ts.URL+"/sitemap_index.xml"))
+	if !assert.NotNil(r) {
+		return
+	}
+	items := r.response.(*gofeed.Feed).Items
+	if assert.Len(items, 2) {
+		assert.Equal("Floods hit Accra", items[0].Title)
+		assert.Equal(ts.URL+path, items[0].GUID)
+		assert.True(items[0].PublishedParsed.Equal(time.Date(2018, 6, 19, 8, 0, 0, 0, time.UTC)))
+		assert.Equal(ts.URL+"/img.jpg", items[0].Image.URL)
+		assert.Equal("", items[1].Title)
+		assert.NotNil(items[1].PublishedParsed)
+	}
+	// sitemaps which can't be parsed are failed feeds
+	var failed []string
+	for _, f := range c.spiderStats(sp.Name).copy().feeds {
+		if f.Status == models.FeedError {
+			failed = append(failed, f.URL)
+		}
+	}
+	assert.Equal([]string{ts.URL + "/broken.xml"}, failed)
+
+	// articles are saved with the image of the sitemap
+	c.wg.Add(1)
+	r.spider.process(r)
+	article, err := models.GetArticle(ts.URL + path)
+	if assert.NoError(err) {
+		assert.Equal("Floods hit Accra", article.Title)
+		assert.Equal(ts.URL+"/img.jpg", article.Image)
+		assert.NotNil(article.Published)
+	}
+}
@@ -0,0 +1,226 @@
+package crawler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/andybalholm/cascadia"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// types of sources
+const (
+	FeedSource    = "feed"
+	SitemapSource = "sitemap"
+	JSONSource    = "json"
+	ListingSource = "listing"
+)
+
+const (
+	defaultSourcesFile = "crawler/sources.yml"
+	// defaultReloadInterval how often the sources file is checked for changes
+	defaultReloadInterval = time.Minute
+)
+
+// Sources news sites crawled by the crawler
+type Sources struct {
+	Sources []*Source `json:"sources" yaml:"sources"`
+}
+
+// Source a news site crawled from its feeds
+type Source struct {
+	Name    string `json:"name" yaml:"name"`
+	Domain  string `json:"domain" yaml:"domain"`
+	Enabled bool   `json:"enabled" yaml:"enabled"`
+	// Type of the feeds of the source, RSS or Atom feeds when empty
+	Type string `json:"type" yaml:"type"`
+	// Interval how often feeds are crawled, the crawl interval when empty
+	Interval string `json:"interval" yaml:"interval"`
+	// BodySelector css selector of the article text, the main
+	// content of pages is extracted when it's empty or doesn't match
+	BodySelector string `json:"body_selector" yaml:"body_selector"`
+	// UseMetaDesc uses og:description of articles instead of the feed description
+	UseMetaDesc bool    `json:"use_meta_desc" yaml:"use_meta_desc"`
+	Feeds       []*Feed `json:"feeds" yaml:"feeds"`
+	// Fields of items of json sources
+	Fields *JSONFields `json:"fields" yaml:"fields"`
+	// Listing selectors of listing sources
+	Listing  *Listing `json:"listing" yaml:"listing"`
+	interval time.Duration
+}
+
+// Feed a feed of a source and the category of its articles
+type Feed struct {
+	Category string `json:"category" yaml:"category"`
+	URL      string `json:"url" yaml:"url"`
+}
+
+// sourcesFile returns path of sources file from SOURCES_FILE,
+// the default file of the repository is used otherwise
+func sourcesFile() string {
+	if path := os.Getenv("SOURCES_FILE"); path != "" {
+		return path
+	}
+	return utils.RepoPath(defaultSourcesFile)
+}
+
+// LoadSources reads sources from a YAML or JSON file and validates them
+func LoadSources(path string) (*Sources, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sources := &Sources{}
+	switch filepath.Ext(path) {
+	case ".json":
+		err = json.Unmarshal(data, sources)
+	default:
+		err = yaml.Unmarshal(data, sources)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid sources file %s: %v", path, err)
+	}
+	return sources, sources.validate()
+}
+
+// validate checks sources have unique names and valid feeds and selectors
+func (s *Sources) validate() error {
+	names := map[string]bool{}
+	for i, src := range s.Sources {
+		if src.Name == "" {
+			return fmt.Errorf("source %d without name", i+1)
+		}
+		if names[src.Name] {
+			return fmt.Errorf("duplicate source %s", src.Name)
+		}
+		names[src.Name] = true
+		if err := src.validate(); err != nil {
+			return fmt.Errorf("invalid source %s: %v", src.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *Source) validate() error {
+	if s.Domain == "" {
+		return fmt.Errorf("domain is required")
+	}
+	if _, err := cascadia.Compile(s.BodySelector); s.BodySelector != "" && err != nil {
+		return fmt.Errorf("invalid body selector %q: %v", s.BodySelector, err)
+	}
+	if err := s.validateType(); err != nil {
+		return err
+	}
+	s.interval = 0
+	if s.Interval != "" {
+		d, err := time.ParseDuration(s.Interval)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("invalid interval %q", s.Interval)
+		}
+		s.interval = d
+	}
+	if len(s.Feeds) == 0 {
+		return fmt.Errorf("no feeds")
+	}
+	for _, f := range s.Feeds {
+		if f.Category == "" {
+			return fmt.Errorf("feed %s without category", f.URL)
+		}
+		u, err := url.Parse(f.URL)
+		if err != nil || !u.IsAbs() || u.Host == "" {
+			return fmt.Errorf("invalid feed url %q", f.URL)
+		}
+	}
+	return nil
+}
+
+// validateType checks settings of the type of source
+func (s *Source) validateType() error {
+	switch s.Type {
+	case "", FeedSource, SitemapSource:
+	case JSONSource:
+		if s.Fields == nil || s.Fields.Link == "" {
+			return fmt.Errorf("json source without link field")
+		}
+	case ListingSource:
+		if s.Listing == nil || s.Listing.LinkSelector == "" {
+			return fmt.Errorf("listing source without link selector")
+		}
+		if _, err := cascadia.Compile(s.Listing.LinkSelector); err != nil {
+			return fmt.Errorf("invalid link selector %q: %v", s.Listing.LinkSelector, err)
+		}
+		if _, err := cascadia.Compile(s.Listing.NextSelector); s.Listing.NextSelector != "" && err != nil {
+			return fmt.Errorf("invalid next selector %q: %v", s.Listing.NextSelector, err)
+		}
+	default:
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	return nil
+}
+
+// spiders returns spiders of enabled sources
+func (s *Sources) spiders() []Spider {
+	var spiders []Spider
+	for _, src := range s.Sources {
+		if src.Enabled {
+			spiders = append(spiders, src.spider())
+		}
+	}
+	return spiders
+}
+
+// spider returns spider of the type of source
+func (s *Source) spider() Spider {
+	links := make([]*link, len(s.Feeds))
+	for i, f := range s.Feeds {
+		links[i] = newLink(f.Category, f.URL)
+	}
+	sp := newFeedSpider(s.Name, s.Domain, links...)
+	sp.Interval = s.interval
+	sp.Config.Set("UseMetaDesc", s.UseMetaDesc)
+	sp.Config.Set("BodySelector", s.BodySelector)
+
+	switch s.Type {
+	case SitemapSource:
+		return &sitemapSpider{sp}
+	case JSONSource:
+		return &jsonSpider{sp, s.Fields}
+	case ListingSource:
+		return &listingSpider{sp, s.Listing}
+	}
+	return sp
+}
+
+// loadSources replaces spiders with the enabled sources of
+// the sources file when the file changed since it was loaded
+func (c *Crawler) loadSources() error {
+	info, err := os.Stat(c.sourcesFile)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	changed := !info.ModTime().Equal(c.sourcesModTime)
+	c.mu.Unlock()
+	if !changed {
+		return nil
+	}
+
+	sources, err := LoadSources(c.sourcesFile)
+	if err != nil {
+		return err
+	}
+	spiders := sources.spiders()
+	c.mu.Lock()
+	c.Spiders = spiders
+	c.sourcesModTime = info.ModTime()
+	c.mu.Unlock()
+	c.Logger.Infof("Loaded %d sources from %s", len(spiders), c.sourcesFile)
+	return nil
+}
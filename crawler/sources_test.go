@@ -0,0 +1,161 @@
+package crawler
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testSources = `
+sources:
+  - name: citinewsroom
+    domain: citinewsroom.com
+    enabled: true
+    interval: 30m
+    body_selector: .entry-content p
+    feeds:
+      - category: Politics
+        url: https://citinewsroom.com/ghana-news/politics/feed/
+  - name: pulse
+    domain: pulse.com.gh
+    enabled: false
+    body_selector: .article_text p
+    feeds:
+      - category: Top stories
+        url: http://www.pulse.com.gh/rss
+`
+
+func writeSources(t *testing.T, path, data string, mod time.Time) {
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(path, mod, mod)
+}
+
+func TestLoadSources(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sources")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sources.yml")
+	writeSources(t, path, testSources, time.Now())
+	sources, err := LoadSources(path)
+	assert.NoError(err)
+	if assert.Len(sources.spiders(), 1) {
+		sp := sources.spiders()[0].(*feedSpider)
+		assert.Equal("citinewsroom", sp.Name)
+		assert.Equal(30*time.Minute, sp.getInterval())
+		assert.Equal(false, sp.Config.Get("UseMetaDesc", nil))
+	}
+
+	jsonPath := filepath.Join(dir, "sources.json")
+	writeSources(t, jsonPath, `{"sources": [{"name": "bbc", "domain": "bbc.com", "enabled": true,
+		"body_selector": "p", "feeds": [{"category": "World", "url": "http://feeds.bbci.co.uk/news/world/rss.xml"}]}]}`, time.Now())
+	sources, err = LoadSources(jsonPath)
+	assert.NoError(err)
+	assert.Len(sources.spiders(), 1)
+
+	writeSources(t, path, `
+sources:
+  - name: graphic
+    domain: graphic.com.gh
+    enabled: true
+    type: listing
+    listing:
+      link_selector: .news-list h2 a
+      max_pages: 2
+    feeds:
+      - category: Politics
+        url: https://www.graphic.com.gh/news/politics.html
+  - name: wordpress
+    domain: example.com
+    enabled: true
+    type: json
+    fields: {link: link, title: title.rendered}
+    feeds:
+      - category: World
+        url: https://example.com/wp-json/wp/v2/posts
+`, time.Now())
+	sources, err = LoadSources(path)
+	assert.NoError(err)
+	if assert.Len(sources.spiders(), 2) {
+		assert.Equal(2, sources.spiders()[0].(*listingSpider).Listing.MaxPages)
+		assert.Equal("title.rendered", sources.spiders()[1].(*jsonSpider).Fields.Title)
+	}
+
+	invalid := map[string]string{
+		"duplicate source":      testSources + "  - name: pulse\n",
+		"domain is required":    "sources:\n  - name: bbc\n",
+		"body selector":         "sources:\n  - name: bbc\n    domain: bbc.com\n    body_selector: \"p[\"\n",
+		"invalid interval":      "sources:\n  - name: bbc\n    domain: bbc.com\n    body_selector: p\n    interval: often\n",
+		"no feeds":              "sources:\n  - name: bbc\n    domain: bbc.com\n    body_selector: p\n",
+		"invalid feed url":      "sources:\n  - name: bbc\n    domain: bbc.com\n    body_selector: p\n    feeds:\n      - category: World\n        url: /rss\n",
+		"invalid sources":       "sources: [",
+		"unknown type":          "sources:\n  - name: bbc\n    domain: bbc.com\n    type: atom\n",
+		"without link field":    "sources:\n  - name: bbc\n    domain: bbc.com\n    type: json\n",
+		"without link selector": "sources:\n  - name: bbc\n    domain: bbc.com\n    type: listing\n",
+		"invalid next selector": "sources:\n  - name: bbc\n    domain: bbc.com\n    type: listing\n    listing: {link_selector: a, next_selector: \"a[\"}\n",
+	}
+	for msg, data := range invalid {
+		writeSources(t, path, data, time.Now())
+		_, err := LoadSources(path)
+		if assert.Error(err, msg) {
+			assert.Contains(err.Error(), msg)
+		}
+	}
+}
+
+func TestReloadSources(t *testing.T) {
+	assert := assert.New(t)
+
+	dir, err := ioutil.TempDir("", "sources")
+	assert.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "sources.yml")
+	mod := time.Now().Add(-time.Hour)
+	writeSources(t, path, testSources, mod)
+	os.Setenv("SOURCES_FILE", path)
+	defer os.Setenv("SOURCES_FILE", "sources.yml")
+
+	c := New()
+	assert.Len(c.Spiders, 1)
+	assert.Equal(30*time.Minute, c.nextRun())
+
+	now := time.Now()
+	assert.Len(c.due(now), 1)
+	assert.Len(c.due(now.Add(10*time.Minute)), 0)
+	assert.Len(c.due(now.Add(30*time.Minute)), 1)
+
+	// sources are reloaded when they change
+	mod = mod.Add(time.Minute)
+	writeSources(t, path, strings.Replace(testSources, "enabled: false", "enabled: true", 1), mod)
+	assert.NoError(c.loadSources())
+	assert.Len(c.Spiders, 2)
+	assert.Len(c.due(now.Add(40*time.Minute)), 1)
+
+	// invalid sources are not loaded
+	mod = mod.Add(time.Minute)
+	writeSources(t, path, "sources: [", mod)
+	assert.Error(c.loadSources())
+	assert.Len(c.Spiders, 2)
+}
+
+func TestDefaultSources(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Unsetenv("SOURCES_FILE")
+	defer os.Setenv("SOURCES_FILE", "sources.yml")
+
+	// the default sources file is found outside the repository root
+	c := New()
+	assert.True(filepath.IsAbs(c.sourcesFile))
+	assert.Len(c.Spiders, 6)
+}
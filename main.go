@@ -3,6 +3,7 @@ package main
 import (
 	"flag"
 	"os"
+	"time"
 
 	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/crawler"
@@ -13,23 +14,27 @@ import (
 	"github.com/epigos/newsbot/web"
 
 	"github.com/joho/godotenv"
+	_ "github.com/lib/pq"
+	_ "github.com/mattn/go-sqlite3"
 	rollbar "github.com/rollbar/rollbar-go"
 )
 
+// shutdownTimeout time to wait for queued messages on shutdown
+const shutdownTimeout = 20 * time.Second
+
 func main() {
 
 	var host = flag.String("host", "0.0.0.0:5050", "host and port to run")
 	var setupFbPage = flag.Bool("setup-fb-page", false, "setup facebook get started and greetings screen")
 	var crawlerMode = flag.Bool("crawler", false, "start background crawler")
+	var reindex = flag.Bool("reindex", false, "add existing articles to the search index and exit")
 	flag.Parse()
 
 	setupRollbar()
 	models.Connect()
-	// worker
-	if *crawlerMode == true {
-		cr := crawler.New()
-		go cr.Run()
-		cr.Listen()
+	if *reindex == true {
+		reindexArticles()
+		return
 	}
 	// chatbot
 	ch := chatbot.New("Newsbot")
@@ -37,6 +42,16 @@ func main() {
 	messenger := messenger.New(ch)
 	// listens messenger channel events
 	go messenger.Listen()
+	// worker
+	if *crawlerMode == true {
+		cr := crawler.New()
+		// push new articles to subscribers after every crawl
+		cr.DoneCh = messenger.PushCh
+		// breaking news are detected by the crawler process only
+		go messenger.Breaking.Run()
+		go cr.Run()
+		cr.Listen()
+	}
 	// setup facebook screen page
 	if *setupFbPage == true {
 		go messenger.SetupPage()
@@ -44,11 +59,23 @@ func main() {
 	// web server
 	s := web.New(*host)
 	s.Handle("/facebook", messenger.ServeHTTP, "GET", "POST")
+	s.OnShutdown(func() {
+		messenger.Shutdown(shutdownTimeout)
+	})
 	s.Post("/_test/bot", ch.TestHandler)
 	// start server
 	s.Run()
 }
 
+func reindexArticles() {
+	logger := utils.NewLogger("main")
+	n, err := models.ReindexArticles()
+	if err != nil {
+		logger.Criticalf("Failed to reindex articles after %d articles: %v", n, err)
+	}
+	logger.Infof("Reindexed %d articles", n)
+}
+
 func setupRollbar() {
 	rollbar.SetToken(os.Getenv("ROLLBAR_TOKEN"))
 	rollbar.SetEnvironment(utils.GetEnvironment()) // defaults to "development"
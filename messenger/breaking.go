@@ -0,0 +1,341 @@
+package messenger
+
+import (
+	"expvar"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	defaultBreakingInterval = 10 * time.Minute
+	defaultBreakingWindow   = 2 * time.Hour
+	defaultBreakingSources  = 3
+	defaultBreakingSpike    = 0.2
+	defaultBreakingCap      = 3
+	// spikeWindow how long articles are watched for score spikes
+	spikeWindow = 24 * time.Hour
+	// recentArticles maximum number of articles checked at once
+	recentArticles = 500
+	// breakingAction user action recorded for every alert
+	breakingAction = "breaking"
+	// claimExpiry how long claims of stories and alerts are kept
+	claimExpiry = 48 * time.Hour
+)
+
+var breakingAlerts = expvar.NewMap("messenger.breaking_alerts")
+
+// breakingStory fresh articles of a story, lead is the article sent to users
+type breakingStory struct {
+	id       string
+	lead     *models.Article
+	articles []*models.Article
+}
+
+// topics returns topics of articles of story
+func (s *breakingStory) topics() []*datastore.Key {
+	var topics []*datastore.Key
+	seen := map[string]bool{}
+	for _, a := range s.articles {
+		if a.TopicKey != nil && !seen[a.TopicKey.Name] {
+			seen[a.TopicKey.Name] = true
+			topics = append(topics, a.TopicKey)
+		}
+	}
+	return topics
+}
+
+// sources returns number of domains which published story
+func (s *breakingStory) sources() int {
+	domains := map[string]bool{}
+	for _, a := range s.articles {
+		domains[a.Domain] = true
+	}
+	return len(domains)
+}
+
+// isBreaking reports whether an article of story is already flagged
+func (s *breakingStory) isBreaking() bool {
+	for _, a := range s.articles {
+		if a.IsBreaking() {
+			return true
+		}
+	}
+	return false
+}
+
+// BreakingNews flags freshly crawled articles as breaking when several
+// sources publish articles of a story within Window or when their
+// score spikes, breaking stories are sent to subscribers of their topics.
+// Stories and alerts are claimed in the store so they're sent once
+// when several processes check for breaking news
+type BreakingNews struct {
+	mg *Messenger
+	// Interval how often articles are checked
+	Interval time.Duration
+	Window   time.Duration
+	// Sources minimum number of sources of a breaking story
+	Sources int
+	// ScoreSpike minimum score gain between checks of a breaking story
+	ScoreSpike float64
+	// DailyCap maximum number of alerts sent to a user a day
+	DailyCap int
+	now      func() time.Time
+	stop     chan bool
+	once     sync.Once
+}
+
+// NewBreakingNews returns breaking news detector configured with
+// BREAKING_WINDOW, BREAKING_SOURCES, BREAKING_SCORE_SPIKE and BREAKING_DAILY_CAP
+func NewBreakingNews(mg *Messenger) *BreakingNews {
+	b := &BreakingNews{
+		mg:         mg,
+		Interval:   defaultBreakingInterval,
+		Window:     defaultBreakingWindow,
+		Sources:    envInt("BREAKING_SOURCES", defaultBreakingSources),
+		ScoreSpike: defaultBreakingSpike,
+		DailyCap:   envInt("BREAKING_DAILY_CAP", defaultBreakingCap),
+		now:        time.Now,
+		stop:       make(chan bool),
+	}
+	if d, err := time.ParseDuration(os.Getenv("BREAKING_WINDOW")); err == nil && d > 0 {
+		b.Window = d
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("BREAKING_SCORE_SPIKE"), 64); err == nil && f > 0 {
+		b.ScoreSpike = f
+	}
+	return b
+}
+
+// Run checks for breaking news every interval until stopped
+func (b *BreakingNews) Run() {
+	t := time.NewTicker(b.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			b.Check()
+		case <-b.stop:
+			return
+		}
+	}
+}
+
+// Stop stops checking for breaking news
+func (b *BreakingNews) Stop() {
+	b.once.Do(func() {
+		close(b.stop)
+	})
+}
+
+// Check detects breaking stories and alerts their subscribers
+func (b *BreakingNews) Check() {
+	stories, err := b.detect()
+	if err != nil {
+		logger.Error("Breaking news:", err)
+		return
+	}
+	for _, s := range stories {
+		logger.Infof("Breaking news from %d sources: %s", s.sources(), s.lead)
+		b.alert(s)
+	}
+}
+
+// detect flags and returns new breaking stories
+func (b *BreakingNews) detect() ([]*breakingStory, error) {
+	now := b.now()
+	articles, err := models.GetRecentArticles(now.Add(-spikeWindow), recentArticles)
+	if err != nil {
+		return nil, err
+	}
+
+	var fresh []*models.Article
+	for _, a := range articles {
+		if now.Sub(published(a)) <= b.Window {
+			fresh = append(fresh, a)
+		}
+	}
+	var candidates []*breakingStory
+	for _, s := range byStory(fresh) {
+		if s.sources() >= b.Sources && !s.isBreaking() {
+			candidates = append(candidates, s)
+		}
+	}
+
+	// scores are compared with the previous check
+	for _, a := range articles {
+		gain, err := models.CheckScore(a, now)
+		if err != nil {
+			logger.Errorf("Breaking news score of %s: %v", a.ID, err)
+			continue
+		}
+		if !a.IsBreaking() && gain >= b.ScoreSpike {
+			candidates = append(candidates, &breakingStory{id: a.ID, articles: []*models.Article{a}})
+		}
+	}
+
+	var stories []*breakingStory
+	for _, s := range candidates {
+		ok, err := models.ClaimEvent("breaking:"+s.id, now.Add(claimExpiry))
+		if err != nil {
+			return stories, err
+		}
+		if ok {
+			stories = append(stories, b.flag(s, now))
+		}
+	}
+	return stories, nil
+}
+
+// flag flags articles of story as breaking and picks its lead,
+// the best scored article published first
+func (b *BreakingNews) flag(s *breakingStory, now time.Time) *breakingStory {
+	for _, a := range s.articles {
+		t := now
+		a.Breaking = &t
+		a.Save()
+	}
+	sort.SliceStable(s.articles, func(i, j int) bool {
+		x, y := s.articles[i], s.articles[j]
+		if x.Score != y.Score {
+			return x.Score > y.Score
+		}
+		return published(x).Before(published(y))
+	})
+	s.lead = s.articles[0]
+	return s
+}
+
+// alert sends lead of story to subscribers of its topics
+func (b *BreakingNews) alert(s *breakingStory) {
+	users := map[string]bool{}
+	for _, topic := range s.topics() {
+		subs, err := models.GetTopicSubscriptions(topic)
+		if err != nil {
+			logger.Errorf("Breaking news subscriptions of %s: %v", topic.Name, err)
+			continue
+		}
+		for _, sub := range subs {
+			userID := sub.User.Name
+			if users[userID] {
+				continue
+			}
+			users[userID] = true
+			result := b.alertUser(sub, s)
+			breakingAlerts.Add(result, 1)
+		}
+	}
+}
+
+// alertUser sends story to subscriber of sub unless the user got
+// the story or reached the daily cap, it returns the outcome
+func (b *BreakingNews) alertUser(sub *models.Subscription, s *breakingStory) string {
+	userID := sub.User.Name
+	for _, a := range s.articles {
+		if models.IsItemSent(userID, a.Key()) {
+			return "sent_before"
+		}
+	}
+
+	user, err := models.GetUser(userID)
+	if err != nil {
+		logger.Warnf("Breaking news to unknown user %s: %v", userID, err)
+	}
+	el := s.lead.ToMessengerElement(userID)
+	el.Title = fmt.Sprintf(utils.BreakingTitle, el.Title)
+	gm := utils.NewGenericMessage(userID)
+	gm.AddElement(el)
+	reason, token := b.mg.Policy.ApplySubscription(user, sub, gm)
+	if reason != "" {
+		b.mg.Policy.skip(userID, reason)
+		return "skipped"
+	}
+	if ok, err := models.ClaimSentItem(userID, s.lead.Key()); err != nil || !ok {
+		if err != nil {
+			logger.Error("Breaking news claim:", err)
+			return "error"
+		}
+		return "sent_before"
+	}
+	if ok, err := b.claimAlert(userID); err != nil || !ok {
+		if err != nil {
+			logger.Error("Breaking news cap:", err)
+			return "error"
+		}
+		return "capped"
+	}
+
+	msg := models.NewMessage(userID, s.lead.Title, "", "", nil)
+	if token {
+		// notifications are sent right away to record them once they're sent
+		res, err := b.mg.SendMessage(gm)
+		if err != nil {
+			// the story is sent again on the next alert
+			models.NewSentItem(userID, s.lead.Key()).Delete()
+			return "error"
+		}
+		b.mg.Policy.notified(sub)
+		msg.MID = []string{res.MessageID}
+		msg.Save()
+	} else {
+		msg.Save()
+		if err := b.mg.Outbox.Enqueue(userID, msg.Key(), gm); err != nil {
+			logger.Errorf("Breaking news to %s: %v", userID, err)
+			return "error"
+		}
+	}
+	models.NewUserAction(userID, s.lead.Key(), breakingAction).Save()
+	return "sent"
+}
+
+// claimAlert claims one of the daily alerts of user, it
+// returns false when the user reached the daily cap
+func (b *BreakingNews) claimAlert(userID string) (bool, error) {
+	now := b.now()
+	day := now.UTC().Format("2006-01-02")
+	for i := 0; i < b.DailyCap; i++ {
+		id := fmt.Sprintf("breaking:%s:%s:%d", userID, day, i)
+		ok, err := models.ClaimEvent(id, now.Add(claimExpiry))
+		if err != nil || ok {
+			return ok, err
+		}
+	}
+	return false, nil
+}
+
+// byStory groups articles by their story, articles
+// without a story are a story of their own
+func byStory(articles []*models.Article) []*breakingStory {
+	var stories []*breakingStory
+	index := map[string]*breakingStory{}
+	for _, a := range articles {
+		id := a.ID
+		if a.StoryKey != nil {
+			id = a.StoryKey.Name
+		}
+		s, ok := index[id]
+		if !ok {
+			s = &breakingStory{id: id}
+			index[id] = s
+			stories = append(stories, s)
+		}
+		s.articles = append(s.articles, a)
+	}
+	return stories
+}
+
+// published returns publish time of article, its crawl time when unknown
+func published(a *models.Article) time.Time {
+	if a.Published != nil {
+		return *a.Published
+	}
+	return a.Created
+}
@@ -0,0 +1,129 @@
+package messenger
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/chatbot"
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestArticle(title, domain, topic string, score float64) *models.Article {
+	pub := time.Now().Add(-time.Minute)
+	link := "https://" + domain + "/" + fake.Characters()
+	article := models.NewArticle(title, link, fake.SentencesN(2), link, domain, link, &pub, []string{})
+	ta := utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10))
+	article.AddAssessment(ta)
+	article.SetTopic(topic, []string{})
+	article.Score = score
+	article.Save()
+	article.AssignStory(ta)
+	return article
+}
+
+func TestByStory(t *testing.T) {
+	assert := assert.New(t)
+
+	articles := []*models.Article{
+		{ID: "1", Title: "President Akufo-Addo sacks Finance Minister", StoryKey: models.GetStoryKey("1")},
+		{ID: "2", Title: "Ghana's weather forecast for the weekend"},
+		{ID: "3", Title: "Akufo-Addo sacks finance minister", StoryKey: models.GetStoryKey("1")},
+		{ID: "4", Title: "Budget reading postponed", StoryKey: models.GetStoryKey("budget")},
+		{ID: "5", Title: "Minister of finance relieved of his post", StoryKey: models.GetStoryKey("budget")},
+		{ID: "6", Title: "Akufo-Addo sacks finance minister Ofori-Atta", StoryKey: models.GetStoryKey("1")},
+	}
+	stories := byStory(articles)
+	if assert.Len(stories, 3) {
+		assert.Len(stories[0].articles, 3)
+		assert.Len(stories[1].articles, 1)
+		assert.Len(stories[2].articles, 2)
+	}
+}
+
+func TestBreakingNews(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	received := map[string]int{}
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var m struct {
+			Recipient utils.Recipient `json:"recipient"`
+		}
+		json.NewDecoder(r.Body).Decode(&m)
+		mu.Lock()
+		received[m.Recipient.ID]++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(FacebookResponse{MessageID: fake.Characters(), RecipientID: rid})
+	})
+	defer fs.Close()
+
+	bmg := New(chatbot.New("Test"))
+	bmg.Outbox = NewOutbox(c)
+	b := bmg.Breaking
+	b.DailyCap = 1
+
+	topic := fake.Word()
+	now := time.Now()
+	var users []*models.User
+	for i := 0; i < 2; i++ {
+		bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+		bu.LastInteraction = &now
+		bu.Save()
+		models.NewSubscription(bu.ID, topic).Save()
+		users = append(users, bu)
+	}
+	// the second user got an alert today
+	ok, err := b.claimAlert(users[1].ID)
+	assert.NoError(err)
+	assert.True(ok)
+
+	// a story of a single source is not breaking
+	word := fake.Characters()
+	first := newTestArticle("Floods hit Accra "+word, "citinewsroom.com", topic, 0)
+	b.Check()
+	assert.False(first.IsBreaking())
+
+	newTestArticle("Accra floods: heavy rains hit "+word, "myjoyonline.com", topic, 0.1)
+	newTestArticle("Floods hit Accra "+word+" again", "ghanaweb.com", topic, 0)
+	b.Check()
+	bmg.Outbox.Wait()
+
+	a, err := models.GetArticle(first.ID)
+	assert.NoError(err)
+	assert.True(a.IsBreaking())
+	assert.Equal(1, received[users[0].ID])
+	assert.Equal(0, received[users[1].ID])
+
+	// flagged stories are sent once
+	b.Check()
+	bmg.Outbox.Wait()
+	assert.Equal(1, received[users[0].ID])
+
+	// stories are claimed once by detectors of all processes
+	a.Breaking = nil
+	a.Save()
+	NewBreakingNews(bmg).Check()
+	bmg.Outbox.Wait()
+	assert.Equal(1, received[users[0].ID])
+
+	// stories are breaking when their score spikes
+	b.DailyCap = 5
+	spike := newTestArticle(fake.Sentence()+word, "bbc.com", topic, 0)
+	b.Check()
+	spike, _ = models.GetArticle(spike.ID)
+	spike.Score += b.ScoreSpike
+	spike.Save()
+	b.Check()
+	bmg.Outbox.Wait()
+	a, _ = models.GetArticle(spike.ID)
+	assert.True(a.IsBreaking())
+	assert.Equal(2, received[users[0].ID])
+	assert.Equal(1, received[users[1].ID])
+}
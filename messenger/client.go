@@ -0,0 +1,248 @@
+package messenger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	clientTimeout   = 10 * time.Second
+	maxIdleConns    = 32
+	idleConnTimeout = 90 * time.Second
+	// defaultSendRate requests per second allowed for a page
+	defaultSendRate = 20
+	defaultRetries  = 3
+	defaultBackoff  = 500 * time.Millisecond
+	maxBackoff      = 30 * time.Second
+)
+
+// Send API error kinds, use ErrorKind to get the kind of an error
+var (
+	ErrUserBlocked     = errors.New("user can't receive messages")
+	ErrRateLimited     = errors.New("rate limited")
+	ErrBadToken        = errors.New("invalid access token")
+	ErrPolicyViolation = errors.New("policy violation")
+	ErrServer          = errors.New("graph server error")
+)
+
+var (
+	// transport shared by clients to reuse connections
+	transport = &http.Transport{
+		Proxy:               http.ProxyFromEnvironment,
+		MaxIdleConns:        maxIdleConns,
+		MaxIdleConnsPerHost: maxIdleConns,
+		IdleConnTimeout:     idleConnTimeout,
+	}
+	// limiters rate limiters of pages
+	limiters   = map[string]*tokenBucket{}
+	limitersMu sync.Mutex
+)
+
+// Client Send API client shared by replies and push messages,
+// requests are rate limited per page and retried on temporary errors
+type Client struct {
+	AccessToken string
+	PageID      string
+	// MaxRetries maximum number of retries of a request
+	MaxRetries int
+	// Backoff delay before the first retry, doubled on every retry
+	Backoff time.Duration
+	http    *http.Client
+	limiter *tokenBucket
+	sleep   func(time.Duration)
+}
+
+// NewClient returns new Send API client of page, FACEBOOK_SEND_RATE
+// overrides the number of requests per second
+func NewClient(accessToken, pageID string) *Client {
+	rate := float64(defaultSendRate)
+	if r, err := strconv.ParseFloat(os.Getenv("FACEBOOK_SEND_RATE"), 64); err == nil && r > 0 {
+		rate = r
+	}
+	return &Client{
+		AccessToken: accessToken,
+		PageID:      pageID,
+		MaxRetries:  defaultRetries,
+		Backoff:     defaultBackoff,
+		http:        &http.Client{Transport: transport, Timeout: clientTimeout},
+		limiter:     pageLimiter(pageID, rate),
+		sleep:       time.Sleep,
+	}
+}
+
+// pageLimiter returns the rate limiter of page, clients of the same page share it
+func pageLimiter(pageID string, rate float64) *tokenBucket {
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+
+	b, ok := limiters[pageID]
+	if !ok {
+		b = newTokenBucket(rate, rate)
+		limiters[pageID] = b
+	}
+	return b
+}
+
+func (c *Client) url(path string) string {
+	p, ok := endPoints[path]
+	if !ok {
+		p = path
+	}
+	base := apiURL
+	if TestURL != "" {
+		base = TestURL
+	}
+	return fmt.Sprintf("%s/%s?access_token=%s", base, p, c.AccessToken)
+}
+
+// Send sends message to the messages endpoint
+func (c *Client) Send(m interface{}) (*FacebookResponse, error) {
+	var res FacebookResponse
+	if err := c.Do("POST", messagesPath, m, &res); err != nil {
+		return &FacebookResponse{}, err
+	}
+	logger.Debugf("%s", &res)
+	return &res, nil
+}
+
+// Do makes request to the graph api and decodes the response into out,
+// rate limit and server errors are retried with exponential backoff
+func (c *Client) Do(method, path string, body, out interface{}) error {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return err
+		}
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		err := c.do(method, path, data, out)
+		fe, ok := err.(*FacebookError)
+		if !ok || !fe.Temporary() || attempt >= c.MaxRetries {
+			return err
+		}
+		logger.Warnf("FB request to %s failed, retrying in %s: %v", path, backoff, err)
+		c.sleep(backoff)
+		if backoff *= 2; backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}
+
+// do makes a single request
+func (c *Client) do(method, path string, data []byte, out interface{}) error {
+	req, err := http.NewRequest(method, c.url(path), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if d := c.limiter.reserve(); d > 0 {
+		c.sleep(d)
+	}
+	logger.Debugf("Making FB request to %s; method: %s; params: %s", path, method, string(data))
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	return decodeGraphResponse(resp, out)
+}
+
+// decodeGraphResponse decodes response into out or returns its FacebookError
+func decodeGraphResponse(r *http.Response, out interface{}) error {
+	defer r.Body.Close()
+	b, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+
+	var raw struct {
+		Error *FacebookError `json:"error"`
+	}
+	if err := json.Unmarshal(b, &raw); err != nil {
+		if r.StatusCode >= http.StatusBadRequest {
+			raw.Error = &FacebookError{Message: http.StatusText(r.StatusCode)}
+		} else {
+			return err
+		}
+	}
+	if raw.Error != nil {
+		raw.Error.Status = r.StatusCode
+		raw.Error.Kind = classifyError(raw.Error)
+		return raw.Error
+	}
+	if out == nil {
+		return nil
+	}
+	return json.Unmarshal(b, out)
+}
+
+// classifyError returns kind of graph api error
+// https://developers.facebook.com/docs/messenger-platform/reference/send-api/error-codes
+func classifyError(e *FacebookError) error {
+	switch {
+	case e.Code == 4, e.Code == 17, e.Code == 32, e.Code == 613, e.Code == 80006:
+		return ErrRateLimited
+	case e.Code == 102, e.Code == 190:
+		return ErrBadToken
+	case e.Code == 551, e.Subcode == 1545041, e.Subcode == 2018108, e.Subcode == 2018001:
+		return ErrUserBlocked
+	case e.Code == 10, e.Code == 200, e.Code == 230, e.Code == 368:
+		return ErrPolicyViolation
+	case e.Code == 1, e.Code == 2, e.Status >= http.StatusInternalServerError:
+		return ErrServer
+	}
+	return nil
+}
+
+// ErrorKind returns the kind of Send API error, nil for other errors
+func ErrorKind(err error) error {
+	if fe, ok := err.(*FacebookError); ok {
+		return fe.Kind
+	}
+	return nil
+}
+
+// tokenBucket rate limiter allowing rate requests per second with bursts
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+func newTokenBucket(rate, burst float64) *tokenBucket {
+	return &tokenBucket{rate: rate, burst: burst, tokens: burst, now: time.Now}
+}
+
+// reserve takes a token and returns how long to wait before using it
+func (b *tokenBucket) reserve() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := b.now()
+	if !b.last.IsZero() {
+		b.tokens += now.Sub(b.last).Seconds() * b.rate
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+	b.last = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / b.rate * float64(time.Second))
+}
@@ -0,0 +1,118 @@
+package messenger
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestClient(handler http.HandlerFunc) (*Client, *httptest.Server, *[]time.Duration) {
+	fs := httptest.NewServer(handler)
+	TestURL = fs.URL
+
+	var sleeps []time.Duration
+	c := NewClient("access-token", "test-page")
+	c.limiter = newTokenBucket(1000, 1000)
+	c.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+	return c, fs, &sleeps
+}
+
+func writeGraphError(w http.ResponseWriter, status, code, subcode int) {
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error": &FacebookError{Code: code, Subcode: subcode, Message: "error", Type: "OAuthException"},
+	})
+}
+
+func TestClientRetries(t *testing.T) {
+	assert := assert.New(t)
+
+	calls := 0
+	c, fs, sleeps := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch calls {
+		case 1:
+			writeGraphError(w, http.StatusBadRequest, 613, 0)
+		case 2:
+			w.WriteHeader(http.StatusBadGateway)
+			w.Write([]byte("<html>Bad gateway</html>"))
+		default:
+			json.NewEncoder(w).Encode(FacebookResponse{MessageID: mid, RecipientID: rid})
+		}
+	})
+	defer fs.Close()
+
+	res, err := c.Send(utils.NewTextMessage(rid, "hi"))
+	assert.NoError(err)
+	assert.Equal(mid, res.MessageID)
+	assert.Equal(3, calls)
+	assert.Equal([]time.Duration{defaultBackoff, 2 * defaultBackoff}, *sleeps)
+
+	// requests fail after max retries
+	calls = 0
+	c.MaxRetries = 0
+	_, err = c.Send(utils.NewTextMessage(rid, "hi"))
+	assert.Equal(ErrRateLimited, ErrorKind(err))
+	assert.Equal(1, calls)
+}
+
+func TestClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	cases := []struct {
+		status, code, subcode int
+		kind                  error
+	}{
+		{http.StatusBadRequest, 190, 0, ErrBadToken},
+		{http.StatusBadRequest, 551, 0, ErrUserBlocked},
+		{http.StatusBadRequest, 200, 1545041, ErrUserBlocked},
+		{http.StatusBadRequest, 10, 2018278, ErrPolicyViolation},
+		{http.StatusBadRequest, 100, 0, nil},
+	}
+	for _, cs := range cases {
+		calls := 0
+		c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			writeGraphError(w, cs.status, cs.code, cs.subcode)
+		})
+		_, err := c.Send(utils.NewTextMessage(rid, "hi"))
+		fs.Close()
+
+		assert.Error(err)
+		assert.Equal(cs.kind, ErrorKind(err), "code %d", cs.code)
+		// only temporary errors are retried
+		assert.Equal(1, calls, "code %d", cs.code)
+	}
+	assert.Nil(ErrorKind(nil))
+}
+
+func TestTokenBucket(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC)
+	b := newTokenBucket(2, 2)
+	b.now = func() time.Time { return now }
+
+	assert.Equal(time.Duration(0), b.reserve())
+	assert.Equal(time.Duration(0), b.reserve())
+	assert.Equal(500*time.Millisecond, b.reserve())
+	assert.Equal(time.Second, b.reserve())
+
+	// tokens are refilled up to burst
+	now = now.Add(time.Minute)
+	assert.Equal(time.Duration(0), b.reserve())
+	assert.Equal(time.Duration(0), b.reserve())
+	assert.Equal(500*time.Millisecond, b.reserve())
+
+	// clients of the same page share a limiter
+	assert.True(pageLimiter("page", 1) == pageLimiter("page", 5))
+	assert.False(pageLimiter("page", 1) == pageLimiter("other", 1))
+}
@@ -0,0 +1,154 @@
+package messenger
+
+import (
+	"container/list"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+)
+
+const (
+	defaultDedupTTL  = 24 * time.Hour
+	defaultDedupSize = 10000
+	dbDedup          = "db"
+)
+
+// Deduplicator remembers processed webhook events so events
+// redelivered by facebook are processed only once
+type Deduplicator interface {
+	// Claim reports whether event id is seen for the first time,
+	// events that can't be checked are treated as new
+	Claim(id string) (bool, error)
+}
+
+// NewDeduplicator returns deduplicator configured with DEDUP_STORE,
+// DEDUP_TTL and DEDUP_SIZE, events are kept in memory by default
+// and also in the database when DEDUP_STORE is db
+func NewDeduplicator() Deduplicator {
+	ttl := defaultDedupTTL
+	if d, err := time.ParseDuration(os.Getenv("DEDUP_TTL")); err == nil {
+		ttl = d
+	}
+	cache := NewMemoryDeduplicator(ttl, envInt("DEDUP_SIZE", defaultDedupSize))
+	if os.Getenv("DEDUP_STORE") == dbDedup {
+		return NewDBDeduplicator(cache)
+	}
+	return cache
+}
+
+// eventID returns id of event used for deduplication, deliveries
+// and reads are idempotent and have no id
+func eventID(e *event) string {
+	switch {
+	case e.kind == eventDelivery, e.kind == eventRead:
+		return ""
+	case e.kind == eventMessage && e.m.Message.Mid != "":
+		return "mid." + e.m.Message.Mid
+	}
+	return fmt.Sprintf("%s.%s.%d", e.kind, e.m.Sender.ID, e.m.Timestamp)
+}
+
+type dedupEntry struct {
+	id      string
+	expires time.Time
+}
+
+// MemoryDeduplicator bounded cache of recent event ids,
+// the least recently claimed ids are evicted when the cache is full
+type MemoryDeduplicator struct {
+	TTL     time.Duration
+	Size    int
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	// order ids from the least recently claimed
+	order *list.List
+	now   func() time.Time
+}
+
+// NewMemoryDeduplicator returns new in-memory deduplicator
+func NewMemoryDeduplicator(ttl time.Duration, size int) *MemoryDeduplicator {
+	return &MemoryDeduplicator{
+		TTL:     ttl,
+		Size:    size,
+		entries: map[string]*list.Element{},
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// Claim reports whether event id is seen for the first time,
+// expired ids are evicted when they're claimed again or reach
+// the front of the list so a claim never sweeps the whole cache
+func (d *MemoryDeduplicator) Claim(id string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := d.now()
+	expires := now.Add(d.TTL)
+	if e, ok := d.entries[id]; ok {
+		entry := e.Value.(*dedupEntry)
+		if entry.expires.After(now) {
+			return false, nil
+		}
+		entry.expires = expires
+		d.order.MoveToBack(e)
+	} else {
+		d.entries[id] = d.order.PushBack(&dedupEntry{id, expires})
+	}
+	if d.order.Len() > d.Size || d.expired(d.order.Front(), now) {
+		d.remove(d.order.Front())
+	}
+	return true, nil
+}
+
+func (d *MemoryDeduplicator) expired(e *list.Element, now time.Time) bool {
+	return !e.Value.(*dedupEntry).expires.After(now)
+}
+
+func (d *MemoryDeduplicator) remove(e *list.Element) {
+	d.order.Remove(e)
+	delete(d.entries, e.Value.(*dedupEntry).id)
+}
+
+// DBDeduplicator deduplicator persisted in the database, events
+// are claimed atomically so they're processed once across replicas
+type DBDeduplicator struct {
+	cache     *MemoryDeduplicator
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewDBDeduplicator returns database deduplicator, cache
+// avoids database lookups for events seen by this replica
+func NewDBDeduplicator(cache *MemoryDeduplicator) *DBDeduplicator {
+	return &DBDeduplicator{cache: cache}
+}
+
+// Claim reports whether event id is seen for the first time
+func (d *DBDeduplicator) Claim(id string) (bool, error) {
+	if ok, _ := d.cache.Claim(id); !ok {
+		return false, nil
+	}
+	now := d.cache.now()
+	ok, err := models.ClaimEvent(id, now.Add(d.cache.TTL))
+	if err != nil {
+		return true, err
+	}
+
+	// delete expired events once per ttl
+	d.mu.Lock()
+	sweep := now.Sub(d.lastSweep) >= d.cache.TTL
+	if sweep {
+		d.lastSweep = now
+	}
+	d.mu.Unlock()
+	if sweep {
+		if _, err := models.DeleteExpiredEvents(now); err != nil {
+			logger.Error("Delete expired events:", err)
+		}
+	}
+	return ok, nil
+}
@@ -0,0 +1,82 @@
+package messenger
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDeduplicator(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Date(2018, 6, 15, 12, 0, 0, 0, time.UTC)
+	d := NewMemoryDeduplicator(time.Hour, 2)
+	d.now = func() time.Time { return now }
+
+	ok, err := d.Claim("a")
+	assert.NoError(err)
+	assert.True(ok)
+	ok, _ = d.Claim("a")
+	assert.False(ok)
+
+	// the oldest ids are evicted when full
+	d.Claim("b")
+	d.Claim("c")
+	assert.Equal(2, d.order.Len())
+	ok, _ = d.Claim("a")
+	assert.True(ok)
+
+	// ids expire after ttl
+	now = now.Add(time.Hour)
+	ok, _ = d.Claim("c")
+	assert.True(ok)
+	assert.Equal(1, d.order.Len())
+	ok, _ = d.Claim("c")
+	assert.False(ok)
+
+	// one expired id is evicted per claim
+	d = NewMemoryDeduplicator(time.Hour, 10)
+	d.now = func() time.Time { return now }
+	d.Claim("a")
+	d.Claim("b")
+	now = now.Add(time.Hour)
+	d.Claim("c")
+	assert.Equal(2, d.order.Len())
+	d.Claim("d")
+	assert.Equal(2, d.order.Len())
+	ok, _ = d.Claim("d")
+	assert.False(ok)
+}
+
+func TestDBDeduplicator(t *testing.T) {
+	assert := assert.New(t)
+	id := "mid." + fake.Characters()
+
+	// replicas share claimed events
+	d1 := NewDBDeduplicator(NewMemoryDeduplicator(time.Hour, 10))
+	d2 := NewDBDeduplicator(NewMemoryDeduplicator(time.Hour, 10))
+	ok, err := d1.Claim(id)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = d2.Claim(id)
+	assert.NoError(err)
+	assert.False(ok)
+	ok, _ = d1.Claim(id)
+	assert.False(ok)
+}
+
+func TestEventID(t *testing.T) {
+	assert := assert.New(t)
+
+	m := &messaging{Sender: Recipient{ID: "1"}, Timestamp: 1527904863157, Message: &FacebookMessage{Mid: "mid.1"}}
+	assert.Equal("mid.mid.1", eventID(&event{kind: eventMessage, m: m}))
+	m.Message.Mid = ""
+	assert.Equal("message.1.1527904863157", eventID(&event{kind: eventMessage, m: m}))
+
+	p := &messaging{Sender: Recipient{ID: "1"}, Timestamp: 1527904863157, Postback: &FacebookPostback{Payload: "x"}}
+	assert.Equal("postback.1.1527904863157", eventID(&event{kind: eventPostback, m: p}))
+	assert.Empty(eventID(&event{kind: eventDelivery, m: p}))
+	assert.Empty(eventID(&event{kind: eventRead, m: p}))
+}
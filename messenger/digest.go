@@ -0,0 +1,209 @@
+package messenger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+const (
+	// defaultDigestInterval how often due digests are checked
+	defaultDigestInterval = 5 * time.Minute
+	// digestWindow how far back daily digests look for articles
+	digestWindow = 24 * time.Hour
+)
+
+// DigestScheduler sends ranked digests of subscriptions with a digest
+// schedule at the digest time in the time zone of their users
+type DigestScheduler struct {
+	mg *Messenger
+	// Interval how often due digests are checked
+	Interval time.Duration
+	now      func() time.Time
+	stop     chan bool
+	once     sync.Once
+}
+
+// NewDigestScheduler returns digest scheduler checking
+// due digests every DIGEST_INTERVAL
+func NewDigestScheduler(mg *Messenger) *DigestScheduler {
+	interval := defaultDigestInterval
+	if d, err := time.ParseDuration(os.Getenv("DIGEST_INTERVAL")); err == nil && d > 0 {
+		interval = d
+	}
+	return &DigestScheduler{mg: mg, Interval: interval, now: time.Now, stop: make(chan bool)}
+}
+
+// Run sends due digests every interval until stopped
+func (s *DigestScheduler) Run() {
+	t := time.NewTicker(s.Interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if _, err := s.SendDue(); err != nil {
+				logger.Error("Digest:", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Stop stops the scheduler
+func (s *DigestScheduler) Stop() {
+	s.once.Do(func() {
+		close(s.stop)
+	})
+}
+
+// SendDue sends digests which are due, subscriptions of a user
+// with the same schedule are sent in one digest
+func (s *DigestScheduler) SendDue() (int, error) {
+	subs, err := models.GetAllSubscriptions()
+	if err != nil {
+		return 0, err
+	}
+
+	now := s.now()
+	users := map[string]*models.User{}
+	due := map[string][]*models.Subscription{}
+	var order []string
+	for _, sub := range subs {
+		if sub.IsRealtime() {
+			continue
+		}
+		userID := sub.User.Name
+		user, ok := users[userID]
+		if !ok {
+			user, err = models.GetUser(userID)
+			if err != nil {
+				logger.Warnf("Digest of %s in UTC: %v", userID, err)
+			}
+			users[userID] = user
+		}
+		if !sub.DigestDue(now, user.Location()) {
+			continue
+		}
+		key := userID + "/" + sub.Schedule
+		if _, ok := due[key]; !ok {
+			order = append(order, key)
+		}
+		due[key] = append(due[key], sub)
+	}
+
+	sent := 0
+	for _, key := range order {
+		subs := due[key]
+		n, err := s.sendDigest(users[subs[0].User.Name], subs, now)
+		if err != nil {
+			logger.Errorf("Digest %s: %v", key, err)
+			continue
+		}
+		if n > 0 {
+			sent++
+		}
+	}
+	if sent > 0 {
+		logger.Infof("Sent %d digests", sent)
+	}
+	return sent, nil
+}
+
+// sendDigest claims the digest of subscriptions of user, so it's sent
+// by one process, queues their best unsent articles and returns the
+// number of articles sent. Claimed digests which fail aren't retried
+func (s *DigestScheduler) sendDigest(user *models.User, subs []*models.Subscription, now time.Time) (int, error) {
+	var claimed []*models.Subscription
+	for _, sub := range subs {
+		ok, err := models.ClaimDigest(sub, now, user.Location())
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			claimed = append(claimed, sub)
+		}
+	}
+	if len(claimed) == 0 {
+		return 0, nil
+	}
+	return s.queueDigest(user, claimed, now)
+}
+
+// queueDigest queues digest unless it's refused by the policy,
+// stories of several sources are sent once
+func (s *DigestScheduler) queueDigest(user *models.User, subs []*models.Subscription, now time.Time) (int, error) {
+	userID := user.ID
+	schedule := subs[0].Schedule
+	window := digestWindow
+	if schedule == models.ScheduleWeekly {
+		window *= 7
+	}
+
+	var articles []*models.Article
+	seen := map[string]bool{}
+	for _, sub := range subs {
+		as, err := models.GetNewTopicArticles(sub.Topic, now.Add(-window), pushSize*4)
+		if err != nil {
+			return 0, err
+		}
+		for _, article := range as {
+			if seen[article.ID] || models.IsItemSent(userID, article.Key()) {
+				continue
+			}
+			seen[article.ID] = true
+			articles = append(articles, article)
+		}
+	}
+	if len(articles) == 0 {
+		return 0, nil
+	}
+	rankArticles(articles)
+	articles = models.GroupStories(articles)
+	if len(articles) > pushSize {
+		articles = articles[:pushSize]
+	}
+
+	text := fmt.Sprintf(utils.DigestText, schedule)
+	intro := utils.NewTextMessage(userID, text)
+	gm := utils.NewGenericMessage(userID)
+	for _, article := range articles {
+		gm.AddElement(article.ToStoryElement(userID))
+	}
+	if reason := s.mg.Policy.Apply(user, intro, gm); reason != "" {
+		s.mg.Policy.skip(userID, reason)
+		return 0, nil
+	}
+
+	responses := []interface{}{intro, gm}
+	bs, _ := json.Marshal(responses)
+	msg := models.NewMessage(userID, text, string(bs), "", nil)
+	msg.Save()
+	if err := s.mg.Outbox.Enqueue(userID, msg.Key(), responses...); err != nil {
+		return 0, err
+	}
+	for _, article := range articles {
+		models.NewSentItem(userID, article.Key()).Save()
+	}
+	return len(articles), nil
+}
+
+// rankArticles sorts articles by score, newer articles first
+func rankArticles(articles []*models.Article) {
+	sort.SliceStable(articles, func(i, j int) bool {
+		a, b := articles[i], articles[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Published == nil || b.Published == nil {
+			return b.Published == nil && a.Published != nil
+		}
+		return a.Published.After(*b.Published)
+	})
+}
@@ -0,0 +1,135 @@
+package messenger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/chatbot"
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDigestScheduler(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var received []string
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var m struct {
+			Message json.RawMessage `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&m)
+		mu.Lock()
+		received = append(received, string(m.Message))
+		mu.Unlock()
+		json.NewEncoder(w).Encode(FacebookResponse{MessageID: fake.Characters(), RecipientID: rid})
+	})
+	defer fs.Close()
+
+	dmg := New(chatbot.New("Test"))
+	dmg.Outbox = NewOutbox(c)
+	s := dmg.Digests
+
+	// the user is 2 hours ahead of utc
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 2)
+	interaction := time.Date(2018, 6, 13, 0, 0, 0, 0, time.UTC)
+	bu.LastInteraction = &interaction
+	bu.Save()
+	topic := fake.Word()
+	sub := models.NewSubscription(bu.ID, topic)
+	sub.Schedule = models.ScheduleMorning
+	sub.Save()
+	realtime := models.NewSubscription(bu.ID, fake.Word())
+	realtime.Save()
+
+	// 04:30 utc is 06:30 for the user, the first digest is due at 07:00
+	now := time.Date(2018, 6, 13, 4, 30, 0, 0, time.UTC)
+	sub.Created = now.Add(-time.Hour)
+	sub.Save()
+	s.now = func() time.Time { return now }
+	dmg.Policy.now = s.now
+
+	var articles []*models.Article
+	for i := 0; i < pushSize+2; i++ {
+		pub := now.Add(-time.Duration(i+1) * time.Hour)
+		link := fake.DomainName() + "/" + fake.Characters()
+		article := models.NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+		article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10)))
+		article.SetTopic(topic, []string{})
+		article.Score = float64(i)
+		article.Save()
+		articles = append(articles, article)
+	}
+
+	n, err := s.SendDue()
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	now = now.Add(time.Hour)
+	n, err = s.SendDue()
+	assert.NoError(err)
+	assert.Equal(1, n)
+	dmg.Outbox.Wait()
+
+	// the best articles are sent after the intro
+	if assert.Len(received, 2) {
+		assert.Contains(received[0], fmt.Sprintf(utils.DigestText, models.ScheduleMorning))
+		assert.Contains(received[1], articles[len(articles)-1].ID)
+		assert.NotContains(received[1], articles[0].ID)
+	}
+	assert.True(models.IsItemSent(bu.ID, articles[len(articles)-1].Key()))
+	assert.False(models.IsItemSent(bu.ID, articles[0].Key()))
+
+	// digests are sent once per schedule
+	now = now.Add(12 * time.Hour)
+	n, err = s.SendDue()
+	assert.NoError(err)
+	assert.Equal(0, n)
+
+	// the next digest has articles of the last day
+	now = now.Add(12 * time.Hour)
+	interaction = now.Add(-time.Hour)
+	bu.Save()
+	pub := now.Add(-time.Hour)
+	link := fake.DomainName() + "/" + fake.Characters()
+	article := models.NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+	article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10)))
+	article.SetTopic(topic, []string{})
+	article.Save()
+
+	n, err = s.SendDue()
+	assert.NoError(err)
+	assert.Equal(1, n)
+	dmg.Outbox.Wait()
+	if assert.Len(received, 4) {
+		assert.Contains(received[3], article.ID)
+		assert.NotContains(received[3], articles[0].ID)
+	}
+}
+
+func TestRankArticles(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	older := now.Add(-time.Hour)
+	articles := []*models.Article{
+		{ID: "unpublished", Score: 1},
+		{ID: "older", Score: 1, Published: &older},
+		{ID: "best", Score: 2, Published: &older},
+		{ID: "newer", Score: 1, Published: &now},
+	}
+	rankArticles(articles)
+
+	var ids []string
+	for _, a := range articles {
+		ids = append(ids, a.ID)
+	}
+	assert.Equal([]string{"best", "newer", "older", "unpublished"}, ids)
+}
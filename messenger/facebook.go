@@ -1,12 +1,13 @@
 package messenger
 
 import (
-	"encoding/json"
 	"fmt"
-	"net/http"
 	"strings"
+	"time"
 
+	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
 	"github.com/epigos/newsbot/web"
 
 	"github.com/mitchellh/mapstructure"
@@ -26,12 +27,16 @@ func (r Recipient) String() string {
 }
 
 type messaging struct {
-	Recipient Recipient         `json:"recipient"`
-	Sender    Recipient         `json:"sender"`
-	Timestamp int               `json:"timestamp"`
-	Message   *FacebookMessage  `json:"message,omitempty"`
-	Delivery  *FacebookDelivery `json:"delivery"`
-	Postback  *FacebookPostback `json:"postback"`
+	Recipient      Recipient               `json:"recipient"`
+	Sender         Recipient               `json:"sender"`
+	Timestamp      int                     `json:"timestamp"`
+	Message        *FacebookMessage        `json:"message,omitempty"`
+	Delivery       *FacebookDelivery       `json:"delivery"`
+	Postback       *FacebookPostback       `json:"postback"`
+	Read           *FacebookRead           `json:"read"`
+	Optin          *FacebookOptin          `json:"optin"`
+	Referral       *FacebookReferral       `json:"referral"`
+	AccountLinking *FacebookAccountLinking `json:"account_linking" mapstructure:"account_linking"`
 }
 
 func (m *messaging) String() string {
@@ -41,6 +46,8 @@ func (m *messaging) String() string {
 		return fmt.Sprintf("From: %s, Title: %s", m.Sender, m.Postback.Title)
 	} else if m.Delivery != nil {
 		return strings.Join(m.Delivery.Mids, ", ")
+	} else if m.Referral != nil {
+		return fmt.Sprintf("From: %s, Ref: %s", m.Sender, m.Referral.Ref)
 	}
 	return ""
 }
@@ -70,14 +77,48 @@ type FacebookMessage struct {
 	Mid        string               `json:"mid"`
 	Seq        int                  `json:"seq"`
 	Text       string               `json:"text"`
-	Attachment []facebookAttachment `json:"attachments"`
+	QuickReply *facebookQuickReply  `json:"quick_reply,omitempty" mapstructure:"quick_reply"`
+	Attachment []facebookAttachment `json:"attachments" mapstructure:"attachments"`
+}
+
+type facebookQuickReply struct {
+	Payload string `json:"payload"`
 }
 
 type facebookAttachment struct {
 	Type    string                 `json:"type"`
+	Title   string                 `json:"title,omitempty"`
+	URL     string                 `json:"url,omitempty"`
 	Payload map[string]interface{} `json:"payload"`
 }
 
+// toAttachment converts messenger attachment to chatbot attachment,
+// shared links are received as fallback attachments
+func (a *facebookAttachment) toAttachment() *chatbot.Attachment {
+	p := utils.Map(a.Payload)
+	if p == nil {
+		p = utils.Map{}
+	}
+	at := &chatbot.Attachment{Type: a.Type, Title: a.Title, URL: a.URL}
+	if url, ok := p.Get("url", "").(string); ok && url != "" {
+		at.URL = url
+	}
+
+	switch a.Type {
+	case "fallback":
+		at.Type = chatbot.AttachmentLink
+		if title, ok := p.Get("title", "").(string); ok && title != "" {
+			at.Title = title
+		}
+	case "location":
+		if c, ok := p.Get("coordinates", nil).(map[string]interface{}); ok {
+			at.Lat, _ = c["lat"].(float64)
+			at.Long, _ = c["long"].(float64)
+		}
+	}
+	return at
+}
+
 // FacebookDelivery struct for delivery reports received from Facebook server as part of FacebookRequest struct
 type FacebookDelivery struct {
 	Mids      []string `json:"mids"`
@@ -89,14 +130,55 @@ type FacebookDelivery struct {
 type FacebookPostback struct {
 	Title   string `json:"title"`
 	Payload string `json:"payload"`
+	// Referral set when user starts a conversation from a referral
+	Referral *FacebookReferral `json:"referral"`
+}
+
+// FacebookRead read receipt, messages sent before watermark were read
+type FacebookRead struct {
+	Watermark int64 `json:"watermark"`
+	Seq       int   `json:"seq"`
+}
+
+// Time returns the watermark time
+func (r *FacebookRead) Time() time.Time {
+	return time.Unix(0, r.Watermark*int64(time.Millisecond))
+}
+
+// FacebookOptin received when user opts in with a messenger plugin
+// or to notifications of a notification request
+type FacebookOptin struct {
+	Ref               string `json:"ref"`
+	UserRef           string `json:"user_ref" mapstructure:"user_ref"`
+	Type              string `json:"type"`
+	Payload           string `json:"payload"`
+	NotificationToken string `json:"notification_messages_token" mapstructure:"notification_messages_token"`
+	TokenExpiry       int64  `json:"token_expiry_timestamp" mapstructure:"token_expiry_timestamp"`
+	Status            string `json:"notification_messages_status" mapstructure:"notification_messages_status"`
 }
 
-// rawFBResponse received from Facebook server after sending the message
-// if Error is null we copy this into FacebookResponse object
-type rawFBResponse struct {
-	MessageID   string         `json:"message_id"`
-	RecipientID string         `json:"recipient_id"`
-	Error       *FacebookError `json:"error"`
+// Expiry returns expiry time of notification token
+func (o *FacebookOptin) Expiry() *time.Time {
+	if o.TokenExpiry == 0 {
+		return nil
+	}
+	t := time.Unix(0, o.TokenExpiry*int64(time.Millisecond))
+	return &t
+}
+
+// FacebookReferral received when user follows m.me links with ref
+// params, ads and QR codes
+type FacebookReferral struct {
+	Ref    string `json:"ref"`
+	Source string `json:"source"`
+	Type   string `json:"type"`
+	AdID   string `json:"ad_id" mapstructure:"ad_id"`
+}
+
+// FacebookAccountLinking received when user links or unlinks an account
+type FacebookAccountLinking struct {
+	Status            string `json:"status"`
+	AuthorizationCode string `json:"authorization_code" mapstructure:"authorization_code"`
 }
 
 // FacebookResponse received from Facebook server after sending the message
@@ -112,14 +194,24 @@ func (r *FacebookResponse) String() string {
 // FacebookError received form Facebook server if sending messages failed
 type FacebookError struct {
 	Code      int    `json:"code"`
+	Subcode   int    `json:"error_subcode"`
 	FbtraceID string `json:"fbtrace_id"`
 	Message   string `json:"message"`
 	Type      string `json:"type"`
+	// Status http status code of the response
+	Status int `json:"-"`
+	// Kind one of the Send API error kinds, nil when unknown
+	Kind error `json:"-"`
+}
+
+// Error returns error message constructed from FacebookError data
+func (err *FacebookError) Error() string {
+	return fmt.Sprintf("FB Error: Type %s: %s; FB trace ID: %s", err.Type, err.Message, err.FbtraceID)
 }
 
-// Error returns Go error object constructed from FacebookError data
-func (err *FacebookError) Error() error {
-	return fmt.Errorf("FB Error: Type %s: %s; FB trace ID: %s", err.Type, err.Message, err.FbtraceID)
+// Temporary reports whether the request can be retried
+func (err *FacebookError) Temporary() bool {
+	return err.Kind == ErrRateLimited || err.Kind == ErrServer
 }
 
 // DecodeRequest decodes http request from FB messagner to FacebookRequest struct
@@ -130,27 +222,3 @@ func (mg *Messenger) DecodeRequest(ctx *web.Context) (*FacebookRequest, error) {
 	err := mapstructure.Decode(ctx.PostValues(), &fbRq)
 	return &fbRq, err
 }
-
-// decodeResponse decodes Facebook response after sending message, usually contains MessageID or Error
-func (mg *Messenger) decodeResponse(r *http.Response) (*FacebookResponse, error) {
-	defer r.Body.Close()
-	var fbResp rawFBResponse
-	err := json.NewDecoder(r.Body).Decode(&fbResp)
-	if err != nil {
-		logger.Error(err)
-		return &FacebookResponse{}, err
-	}
-
-	if fbResp.Error != nil {
-		logger.Error(fbResp.Error.Error())
-		return &FacebookResponse{}, fbResp.Error.Error()
-	}
-
-	re := &FacebookResponse{
-		MessageID:   fbResp.MessageID,
-		RecipientID: fbResp.RecipientID,
-	}
-	logger.Debugf("%s", re)
-
-	return re, nil
-}
@@ -7,9 +7,12 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/web"
 
+	"github.com/mitchellh/mapstructure"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,7 +50,8 @@ func TestFacebookResponse(t *testing.T) {
 	defer fs.Close()
 	resp, err := http.Get(fs.URL)
 	assert.NoError(err)
-	fbRes, err := mg.decodeResponse(resp)
+	var fbRes FacebookResponse
+	err = decodeGraphResponse(resp, &fbRes)
 	assert.NoError(err)
 	assert.Equal(fbRes.MessageID, mid)
 	assert.Equal(fbRes.RecipientID, rid)
@@ -55,19 +59,93 @@ func TestFacebookResponse(t *testing.T) {
 	assert.Equal(fbRes.String(), s)
 
 	fs = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		rec := rawFBResponse{
-			RecipientID: rid,
-			MessageID:   mid,
-			Error:       &FacebookError{400, "1", "Invalid message format", "error"},
+		rec := map[string]interface{}{
+			"recipient_id": rid,
+			"message_id":   mid,
+			"error":        &FacebookError{Code: 100, FbtraceID: "1", Message: "Invalid message format", Type: "error"},
 		}
 		b, _ := json.Marshal(rec)
+		w.WriteHeader(http.StatusBadRequest)
 		w.Write(b)
 	}))
 	defer fs.Close()
 	resp, err = http.Get(fs.URL)
 	assert.NoError(err)
-	fbRes, err = mg.decodeResponse(resp)
+	fbRes = FacebookResponse{}
+	err = decodeGraphResponse(resp, &fbRes)
 	assert.NotEqual(fbRes.MessageID, mid)
-	assert.Error(err)
-	assert.Equal(err, fmt.Errorf("FB Error: Type error: Invalid message format; FB trace ID: 1"))
+	assert.EqualError(err, "FB Error: Type error: Invalid message format; FB trace ID: 1")
+	assert.Equal(http.StatusBadRequest, err.(*FacebookError).Status)
+	assert.Nil(ErrorKind(err))
+}
+
+func TestMessageStatement(t *testing.T) {
+	assert := assert.New(t)
+	data := `{"entry": [{"id": "1251562161607", "time": 1527904873637, "messaging": [
+		{"sender": {"id": "1403078893046"}, "message": {"mid": "1", "text": "Other Topics", "quick_reply": {"payload": "Topics"}}},
+		{"sender": {"id": "1403078893046"}, "message": {"mid": "2", "attachments": [{"type": "location", "payload": {"coordinates": {"lat": 5.6, "long": -0.2}}}]}},
+		{"sender": {"id": "1403078893046"}, "message": {"mid": "3", "attachments": [{"type": "fallback", "title": "Black Stars win", "url": "https://example.com/news/1", "payload": null}]}},
+		{"sender": {"id": "1403078893046"}, "message": {"mid": "4", "attachments": [{"type": "image", "payload": {"url": "https://example.com/cat.png"}}]}},
+		{"sender": {"id": "1403078893046"}, "message": {"mid": "5", "text": "https://example.com/news/2"}}
+	]}], "object": "page"}`
+	var values map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(data), &values))
+
+	var fb FacebookRequest
+	assert.NoError(mapstructure.Decode(values, &fb))
+	ms := fb.Entry[0].Messaging
+
+	st := newMessageStatement(&ms[0])
+	assert.Equal("Other Topics", st.Text)
+	assert.Equal("Topics", st.Payload)
+	assert.Empty(st.Attachments)
+
+	st = newMessageStatement(&ms[1])
+	assert.Equal(&chatbot.Attachment{Type: chatbot.AttachmentLocation, Lat: 5.6, Long: -0.2}, st.Attachments[0])
+
+	st = newMessageStatement(&ms[2])
+	assert.Equal(&chatbot.Attachment{Type: chatbot.AttachmentLink, Title: "Black Stars win", URL: "https://example.com/news/1"}, st.Attachments[0])
+
+	st = newMessageStatement(&ms[3])
+	assert.Equal(&chatbot.Attachment{Type: chatbot.AttachmentImage, URL: "https://example.com/cat.png"}, st.Attachments[0])
+
+	st = newMessageStatement(&ms[4])
+	assert.Equal(&chatbot.Attachment{Type: chatbot.AttachmentLink, URL: "https://example.com/news/2"}, st.Attachments[0])
+}
+
+func TestMessagingEvents(t *testing.T) {
+	assert := assert.New(t)
+	data := `{"entry": [{"id": "1251562161607", "time": 1527904873637, "messaging": [
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873000, "read": {"watermark": 1527904863157}},
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873001, "optin": {"ref": "subscribe:sports"}},
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873002, "referral": {"ref": "news.search:ghana", "source": "SHORTLINK", "type": "OPEN_THREAD"}},
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873003, "account_linking": {"status": "linked", "authorization_code": "code"}},
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873004, "postback": {"payload": "GET_STARTED", "referral": {"ref": "topics", "source": "ADS", "ad_id": "1"}}},
+		{"sender": {"id": "1403078893046"}, "timestamp": 1527904873005, "optin": {"type": "notification_messages", "payload": "notify:sports", "notification_messages_token": "token", "token_expiry_timestamp": 1527991273000}}
+	]}], "object": "page"}`
+	var values map[string]interface{}
+	assert.NoError(json.Unmarshal([]byte(data), &values))
+
+	var fb FacebookRequest
+	assert.NoError(mapstructure.Decode(values, &fb))
+	ms := fb.Entry[0].Messaging
+
+	assert.Equal(int64(1527904863157), ms[0].Read.Watermark)
+	assert.Equal(int64(1527904863), ms[0].Read.Time().Unix())
+	assert.Equal("subscribe:sports", ms[1].Optin.Ref)
+	assert.Equal(&FacebookReferral{Ref: "news.search:ghana", Source: "SHORTLINK", Type: "OPEN_THREAD"}, ms[2].Referral)
+	assert.Equal(&FacebookAccountLinking{Status: "linked", AuthorizationCode: "code"}, ms[3].AccountLinking)
+	assert.Equal("1", ms[4].Postback.Referral.AdID)
+	assert.Equal("token", ms[5].Optin.NotificationToken)
+	assert.Equal(int64(1527991273), ms[5].Optin.Expiry().Unix())
+
+	fs := getFbServer()
+	defer fs.Close()
+	mg := New(chatbot.New("Test"))
+	h := &recordHandler{events: map[string][]string{}}
+	mg.Handler = h
+	mg.workers.start()
+	assert.NoError(mg.processEntry(fb.Entry))
+	mg.Shutdown(time.Second)
+	assert.Equal([]string{"read:", "optin:", "referral:", "account_linking:", "postback:", "optin:"}, h.events["1403078893046"])
 }
@@ -1,16 +1,35 @@
 package messenger
 
 import (
+	"fmt"
+	"regexp"
+	"strings"
+
 	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/models"
 	"github.com/epigos/newsbot/utils"
 )
 
+const (
+	// account linking statuses
+	accountLinked   = "linked"
+	accountUnlinked = "unlinked"
+	// optins of notification requests
+	notificationOptin = "notification_messages"
+	stopNotifications = "STOP_NOTIFICATIONS"
+)
+
+var linkRegex = regexp.MustCompile(`^https?://\S+$`)
+
 // MessageHandler an interface for messenger message handlers
 type MessageHandler interface {
 	ProcessMessage(m *messaging)
 	ProcessDelivery(m *messaging)
 	ProcessPostback(m *messaging)
+	ProcessRead(m *messaging)
+	ProcessOptin(m *messaging)
+	ProcessReferral(m *messaging)
+	ProcessAccountLinking(m *messaging)
 }
 
 // DefaultHandler handles messenger messages
@@ -24,19 +43,37 @@ func (h *DefaultHandler) ProcessMessage(m *messaging) {
 	h.mg.MarkSeen(&m.Sender)
 	h.mg.SendTypingOn(&m.Sender)
 
-	st := chatbot.NewStatement(m.Message.Text, m.Sender.ID)
+	st := newMessageStatement(m)
 	output := h.mg.Bot.GetResponse(st)
+	h.reply(m.Sender.ID, output)
+}
 
-	var mids []string
-	for _, msg := range output.Responses {
-		if res, err := h.mg.SendMessage(msg.(utils.Message)); err == nil {
-			mids = append(mids, res.MessageID)
-		}
+// reply logs output and queues its responses, ids of
+// sent responses are recorded on the logged message
+func (h *DefaultHandler) reply(userID string, output *chatbot.Statement) {
+	msg := models.NewMessage(userID, output.Text, output.SerializeResponse(), output.Meta.String(), nil)
+	msg.Save()
+
+	if err := h.mg.Outbox.Enqueue(userID, msg.Key(), output.Responses...); err != nil {
+		logger.Errorf("Enqueue responses to %s: %v", userID, err)
 	}
+}
 
-	// log outgoing message
-	msg := models.NewMessage(m.Sender.ID, output.Text, output.SerializeResponse(), output.Meta.String(), mids)
-	msg.Save()
+// newMessageStatement returns statement of message with
+// its quick reply payload and attachments
+func newMessageStatement(m *messaging) *chatbot.Statement {
+	st := chatbot.NewStatement(m.Message.Text, m.Sender.ID)
+	if m.Message.QuickReply != nil {
+		st.SetPayload(m.Message.QuickReply.Payload)
+	}
+	for _, a := range m.Message.Attachment {
+		st.AddAttachment(a.toAttachment())
+	}
+	// links pasted as text are shared links too
+	if len(st.Attachments) == 0 && linkRegex.MatchString(st.Text) {
+		st.AddAttachment(&chatbot.Attachment{Type: chatbot.AttachmentLink, URL: st.Text})
+	}
+	return st
 }
 
 // ProcessPostback postback from messenger
@@ -47,19 +84,13 @@ func (h *DefaultHandler) ProcessPostback(p *messaging) {
 
 	st := chatbot.NewStatement(p.Postback.Title, p.Sender.ID)
 	st.SetPayload(p.Postback.Payload)
-
-	output := h.mg.Bot.GetResponse(st)
-
-	var mids []string
-	for _, msg := range output.Responses {
-		if res, err := h.mg.SendMessage(msg.(utils.Message)); err == nil {
-			mids = append(mids, res.MessageID)
-		}
+	// get started from a referral
+	if p.Postback.Referral != nil {
+		st.SetReferral(p.Postback.Referral.Ref)
 	}
 
-	// log outgoing message
-	msg := models.NewMessage(p.Sender.ID, output.Text, output.SerializeResponse(), output.Meta.String(), mids)
-	msg.Save()
+	output := h.mg.Bot.GetResponse(st)
+	h.reply(p.Sender.ID, output)
 }
 
 // ProcessDelivery delivery response from messenger
@@ -68,3 +99,87 @@ func (h *DefaultHandler) ProcessDelivery(d *messaging) {
 
 	models.MarkMessageDelivered(d.Delivery.Mids)
 }
+
+// ProcessRead read receipt from messenger
+func (h *DefaultHandler) ProcessRead(r *messaging) {
+	logger.Debugf("Messages read by %s at %d", r.Sender.ID, r.Read.Watermark)
+
+	if err := models.MarkMessagesRead(r.Sender.ID, r.Read.Time()); err != nil {
+		logger.Error("Mark messages read:", err)
+	}
+}
+
+// ProcessOptin optin from a messenger plugin
+func (h *DefaultHandler) ProcessOptin(o *messaging) {
+	logger.Debugf("Optin of %s: %s", o.Sender.ID, o.Optin.Ref)
+
+	// checkbox plugin optins only have a user ref
+	if o.Sender.ID == "" {
+		logger.Infof("Ignoring optin of user ref %s", o.Optin.UserRef)
+		return
+	}
+	if o.Optin.Type == notificationOptin {
+		h.processNotificationOptin(o)
+		return
+	}
+	h.processRef(o.Sender.ID, o.Optin.Ref)
+}
+
+// processNotificationOptin stores notification token of the subscription
+// to the topic of the request, the token is removed when notifications stop
+func (h *DefaultHandler) processNotificationOptin(o *messaging) {
+	if !strings.HasPrefix(o.Optin.Payload, utils.NotifyPayload) {
+		logger.Warnf("Unknown notification request %s", o.Optin.Payload)
+		return
+	}
+	topic := strings.TrimPrefix(o.Optin.Payload, utils.NotifyPayload)
+	sub, err := models.GetUserSubscription(o.Sender.ID, topic)
+	if err != nil {
+		logger.Warnf("Notification optin of %s to %s: %v", o.Sender.ID, topic, err)
+		return
+	}
+
+	if o.Optin.Status == stopNotifications {
+		sub.SetNotificationToken("", nil)
+		sub.Save()
+		return
+	}
+	sub.SetNotificationToken(o.Optin.NotificationToken, o.Optin.Expiry())
+	sub.Save()
+
+	output := chatbot.NewStatement("", o.Sender.ID)
+	output.AddTextResponse(fmt.Sprintf(utils.NotifyOptinText, topic))
+	h.reply(o.Sender.ID, output)
+}
+
+// ProcessReferral referral from m.me links, ads and QR codes
+func (h *DefaultHandler) ProcessReferral(r *messaging) {
+	logger.Debugf("Referral of %s from %s: %s", r.Sender.ID, r.Referral.Source, r.Referral.Ref)
+
+	h.processRef(r.Sender.ID, r.Referral.Ref)
+}
+
+// processRef starts the flow deep-linked by ref
+func (h *DefaultHandler) processRef(userID, ref string) {
+	st := chatbot.NewStatement("", userID)
+	st.SetReferral(ref)
+
+	output := h.mg.Bot.GetResponse(st)
+	h.reply(userID, output)
+}
+
+// ProcessAccountLinking account linking from messenger
+func (h *DefaultHandler) ProcessAccountLinking(a *messaging) {
+	logger.Debugf("Account linking of %s: %s", a.Sender.ID, a.AccountLinking.Status)
+
+	output := chatbot.NewStatement("", a.Sender.ID)
+	switch a.AccountLinking.Status {
+	case accountLinked:
+		output.AddTextResponse(utils.AccountLinkedText)
+	case accountUnlinked:
+		output.AddTextResponse(utils.AccountUnlinkedText)
+	default:
+		return
+	}
+	h.reply(a.Sender.ID, output)
+}
@@ -1,10 +1,13 @@
 package messenger
 
 import (
-	"bytes"
-	"encoding/json"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"expvar"
 	"fmt"
 	"net/http"
+	"strings"
 	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/models"
 	"github.com/epigos/newsbot/utils"
@@ -18,6 +21,9 @@ const (
 	apiURL       = "https://graph.facebook.com/v2.6"
 	messagesPath = "messages"
 	profilePath  = "profile"
+	// signatureHeader header with the HMAC of webhook requests
+	signatureHeader = "X-Hub-Signature-256"
+	signaturePrefix = "sha256="
 )
 
 // TestURL to mock FB server, used for testing
@@ -28,64 +34,67 @@ var (
 		profilePath:  "me/messenger_profile",
 	}
 	logger = utils.NewLogger("messenger")
+	// invalidSignatures counts rejected webhook requests
+	invalidSignatures = expvar.NewInt("messenger.invalid_signatures")
 )
 
 // Messenger struct
 type Messenger struct {
 	AccessToken string
 	VerifyToken string
+	AppSecret   string
 	PageID      string
-	messageCh   chan *messaging
-	deliveryCh  chan *messaging
-	postbackCh  chan *messaging
+	workers     *workerPool
 	Logger      *utils.Logger
 	Bot         *chatbot.Chatbot
 	// message handler
 	Handler MessageHandler
 	PushCh  chan bool
+	// Client Send API client
+	Client *Client
+	// Outbox queue of replies to users
+	Outbox *Outbox
+	// Dedup ignores events redelivered by facebook
+	Dedup Deduplicator
+	// Digests sends scheduled digests of subscriptions
+	Digests *DigestScheduler
+	// Policy decides how proactive messages are sent
+	Policy *Policy
+	// Breaking alerts subscribers of breaking news
+	Breaking *BreakingNews
 }
 
 // New creates new messenger instance
 func New(b *chatbot.Chatbot) *Messenger {
-	messageCh := make(chan *messaging, bufSize)
-	deliveryCh := make(chan *messaging, bufSize)
-	postbackCh := make(chan *messaging, bufSize)
+	accessToken := os.Getenv("FACEBOOK_ACCESS_TOKEN")
+	pageID := os.Getenv("FACEBOOK_PAGE_ID")
 
 	m := &Messenger{
-		AccessToken: os.Getenv("FACEBOOK_ACCESS_TOKEN"),
+		AccessToken: accessToken,
 		VerifyToken: os.Getenv("FACEBOOK_SECRET_TOKEN"),
-		PageID:      os.Getenv("FACEBOOK_PAGE_ID"),
-		// messageCh channel for events when message from Facebook is received
-		messageCh: messageCh,
-		// deliveryCh channel for events when delivery report from Facebook received
-		deliveryCh: deliveryCh,
-		// postbackCh channel for events when postback received from Facebook
-		postbackCh: postbackCh,
-		Logger:     logger,
-		Bot:        b,
-		PushCh:     make(chan bool),
+		AppSecret:   os.Getenv("FACEBOOK_APP_SECRET"),
+		PageID:      pageID,
+		Logger:      logger,
+		Bot:         b,
+		PushCh:      make(chan bool),
+		Client:      NewClient(accessToken, pageID),
+		Dedup:       NewDeduplicator(),
+		Policy:      NewPolicy(),
 	}
+	m.workers = newWorkerPool(m)
+	m.Outbox = NewOutbox(m.Client)
+	m.Digests = NewDigestScheduler(m)
+	m.Breaking = NewBreakingNews(m)
 	m.Handler = &DefaultHandler{m}
 	return m
 }
 
 func (mg *Messenger) buildURL(path string) string {
-	p, ok := endPoints[path]
-	if !ok {
-		p = path
-	}
-	var url = "%s/%s?access_token=%s"
-
-	if TestURL != "" {
-		url = fmt.Sprintf(url, TestURL, p, mg.AccessToken)
-	} else {
-		url = fmt.Sprintf(url, apiURL, p, mg.AccessToken)
-	}
-
-	return url
+	return mg.Client.url(path)
 }
 
-// VerifyWebhook verifies your webhook by checking VerifyToken and sending challange back to Facebook
+// VerifyWebhook verifies your webhook by checking VerifyToken and sending challange back to Facebook,
+// other requests are forbidden
 func (mg *Messenger) VerifyWebhook(ctx *web.Context) *web.HTTPError {
 	// Facebook sends this query for verifying webhooks
 	// hub.mode=subscribe&hub.challenge=1085525140&hub.verify_token=moj_token
@@ -94,32 +103,78 @@ func (mg *Messenger) VerifyWebhook(ctx *web.Context) *web.HTTPError {
 			return ctx.WriteString(ctx.FormValue("hub.challenge"))
 		}
 	}
+	ctx.Forbidden()
 	return nil
 }
 
-func (mg *Messenger) processEntry(fs []facebookEntry) {
+// VerifySignature checks X-Hub-Signature-256 of request, it's the
+// HMAC SHA256 of the raw body signed with the app secret
+func (mg *Messenger) VerifySignature(ctx *web.Context) bool {
+	if mg.AppSecret == "" {
+		logger.Error("FACEBOOK_APP_SECRET is not configured")
+		return false
+	}
+	sig := ctx.Request().Header.Get(signatureHeader)
+	if !strings.HasPrefix(sig, signaturePrefix) {
+		return false
+	}
+	expected, err := hex.DecodeString(strings.TrimPrefix(sig, signaturePrefix))
+	if err != nil {
+		return false
+	}
+	body, err := ctx.RawBody()
+	if err != nil {
+		logger.Error(err)
+		return false
+	}
+	mac := hmac.New(sha256.New, []byte(mg.AppSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
 
+// processEntry queues events of entries for the workers
+func (mg *Messenger) processEntry(fs []facebookEntry) error {
+	var events []*event
+	now := time.Now()
 	for _, entry := range fs {
-		for _, msg := range entry.Messaging {
-			// get sender profile
-			msg.Sender.Profile = mg.GetSenderProfile(msg.Sender.ID)
+		for i := range entry.Messaging {
+			msg := &entry.Messaging[i]
+			e := &event{m: msg, received: now}
 			switch {
 			case msg.Message != nil:
-				mg.messageCh <- &msg
+				e.kind = eventMessage
 			case msg.Delivery != nil:
-				mg.deliveryCh <- &msg
+				e.kind = eventDelivery
 			case msg.Postback != nil:
-				mg.postbackCh <- &msg
+				e.kind = eventPostback
+			case msg.Read != nil:
+				e.kind = eventRead
+			case msg.Optin != nil:
+				e.kind = eventOptin
+			case msg.Referral != nil:
+				e.kind = eventReferral
+			case msg.AccountLinking != nil:
+				e.kind = eventAccountLinking
+			default:
+				continue
 			}
+			events = append(events, e)
 		}
 	}
+	return mg.workers.add(events)
 }
 
 // ServeHTTP is HTTP handler for Messenger so it could be directly used as http.Handler
 func (mg *Messenger) ServeHTTP(ctx *web.Context) *web.HTTPError {
-	er := mg.VerifyWebhook(ctx) // verify webhook if needed
-	if er != nil {
-		return er
+	// webhooks are verified with GET requests, events are signed POST requests
+	if ctx.Request().Method == http.MethodGet {
+		return mg.VerifyWebhook(ctx)
+	}
+	if !mg.VerifySignature(ctx) {
+		invalidSignatures.Add(1)
+		logger.Warnf("Invalid signature from %s", ctx.Request().RemoteAddr)
+		ctx.Forbidden()
+		return nil
 	}
 	fbRq, err := mg.DecodeRequest(ctx) // get FacebookRequest object
 
@@ -128,42 +183,49 @@ func (mg *Messenger) ServeHTTP(ctx *web.Context) *web.HTTPError {
 		logger.Info(e)
 		return ctx.BadRequest(e)
 	}
-	mg.processEntry(fbRq.Entry)
+	if err := mg.processEntry(fbRq.Entry); err != nil {
+		// facebook retries the request later
+		logger.Warn("Facebook request rejected:", err)
+		ctx.WriteError(http.StatusServiceUnavailable, err.Error())
+		return nil
+	}
 
 	return ctx.WriteString("Message received")
 }
 
-// Listen messenger channel events
+// Listen starts the workers processing messenger events, breaking
+// news are checked after crawls sent to PushCh by the crawler process
 func (mg *Messenger) Listen() {
-	logger.Info("Messenger channels opened")
-	for {
-		select {
-		case m := <-mg.messageCh:
-			go mg.Handler.ProcessMessage(m)
-		case d := <-mg.deliveryCh:
-			go mg.Handler.ProcessDelivery(d)
-		case p := <-mg.postbackCh:
-			go mg.Handler.ProcessPostback(p)
-		case <-mg.PushCh:
-			go mg.PushMessages()
-		}
+	if err := mg.Outbox.Start(); err != nil {
+		logger.Error("Outbox:", err)
+	}
+	mg.workers.start()
+	logger.Infof("Messenger workers started: %d", len(mg.workers.queues))
+	go mg.Digests.Run()
+	for range mg.PushCh {
+		go func() {
+			// breaking news are sent before other pushes
+			mg.Breaking.Check()
+			mg.PushMessages()
+		}()
 	}
 }
 
-// makeFbRequest makes request to facebook
-func (mg *Messenger) makeFbRequest(path, method string, body interface{}) (*http.Response, error) {
-	url := mg.buildURL(path)
-	var s []byte
-	if body != nil {
-		s, _ = json.Marshal(body)
+// Shutdown stops accepting events and waits until queued
+// events are processed and replies are sent
+func (mg *Messenger) Shutdown(timeout time.Duration) {
+	deadline := time.Now().Add(timeout)
+	mg.Digests.Stop()
+	mg.Breaking.Stop()
+	if !mg.workers.stop(timeout) {
+		logger.Warn("Messenger shutdown timed out, events were not processed")
+		return
 	}
-	req, err := http.NewRequest(method, url, bytes.NewBuffer(s))
-	req.Header.Set("Content-Type", "application/json")
-
-	logger.Debugf("Making FB request to %s; method: %s; params: %s", url, method, string(s))
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	return resp, err
+	if !mg.Outbox.WaitTimeout(time.Until(deadline)) {
+		logger.Warn("Messenger shutdown timed out, pending replies are sent on restart")
+		return
+	}
+	logger.Info("Messenger stopped")
 }
 
 // GetSenderProfile returns facebook profile
@@ -175,32 +237,17 @@ func (mg *Messenger) GetSenderProfile(senderID string) *models.User {
 	}
 	logger.Info(err)
 
-	r, err := mg.makeFbRequest(senderID, "GET", nil)
-
+	err = mg.Client.Do("GET", senderID, nil, user)
 	if err != nil {
 		logger.Error(err)
 		return user
 	}
-	defer r.Body.Close()
-
-	err = json.NewDecoder(r.Body).Decode(user)
-	if err != nil {
-		logger.Error(err)
-	}
 	// save user
 	user.Created = time.Now()
 	user.Save()
-	if err != nil {
-		logger.Error(err)
-	}
 	return user
 }
 
-// PushMessages push new crawled items to users
-func (mg *Messenger) PushMessages() {
-	logger.Info("Crawl done,", "push messages")
-}
-
 // SendTextMessage sends text messate to receiverID
 // it is shorthand instead of crating new text message and then sending it
 func (mg *Messenger) SendTextMessage(receiverID string, text string) (*FacebookResponse, error) {
@@ -210,10 +257,9 @@ func (mg *Messenger) SendTextMessage(receiverID string, text string) (*FacebookR
 
 // SendMessage sends chat message
 func (mg *Messenger) SendMessage(m utils.Message) (*FacebookResponse, error) {
-	resp, err := mg.makeFbRequest(messagesPath, "POST", m)
-
+	res, err := mg.Client.Send(m)
 	if err != nil {
-		return &FacebookResponse{}, err
+		logger.Error(err)
 	}
-	return mg.decodeResponse(resp)
+	return res, err
 }
@@ -1,6 +1,9 @@
 package messenger
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
@@ -9,6 +12,7 @@ import (
 	"net/http/httptest"
 	"github.com/epigos/newsbot/chatbot"
 	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
 	"github.com/epigos/newsbot/web"
 	"strings"
 	"testing"
@@ -63,11 +67,20 @@ func TestVerify(t *testing.T) {
 	defer ts.Close()
 	challenge := "1122334455"
 	verifyReq := ts.URL + "/?test=1&hub.mode=subscribe&hub.challenge=" + challenge + "&hub.verify_token=" + mg.VerifyToken
+	before := invalidSignatures.Value()
 	resp, err := http.Get(verifyReq)
 	assert.NoError(err)
 	defer resp.Body.Close()
 	s, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(http.StatusOK, resp.StatusCode)
 	assert.Equal(string(s), challenge)
+	// verification requests aren't signed
+	assert.Equal(before, invalidSignatures.Value())
+
+	resp, err = http.Get(ts.URL + "/?hub.mode=subscribe&hub.challenge=" + challenge + "&hub.verify_token=wrong")
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
 }
 
 func TestBuildUrl(t *testing.T) {
@@ -95,9 +108,7 @@ func TestFbRequest(t *testing.T) {
 	assert := assert.New(t)
 	fs := getFbServer()
 	defer fs.Close()
-	resp, err := mg.makeFbRequest(messagesPath, "POST", strings.NewReader(entry))
-	assert.NoError(err)
-	fbRes, err := mg.decodeResponse(resp)
+	fbRes, err := mg.Client.Send(utils.NewTextMessage(rid, "hi"))
 	assert.NoError(err)
 	assert.Equal(fbRes.MessageID, mid)
 	assert.Equal(fbRes.RecipientID, rid)
@@ -108,8 +119,10 @@ func TestHandleMessage(t *testing.T) {
 	ts, mg := getChatServer()
 	defer ts.Close()
 	mg.Handler = &messageTestHandler{mg.Handler}
+	mg.AppSecret = "app-secret"
 	req := httptest.NewRequest("POST", "/facebook", strings.NewReader(entry))
 	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(signatureHeader, sign(mg.AppSecret, entry))
 	rec := httptest.NewRecorder()
 	srv.Handle("/facebook", mg.ServeHTTP)
 	srv.Mux.ServeHTTP(rec, req)
@@ -119,6 +132,41 @@ func TestHandleMessage(t *testing.T) {
 	assert.Equal("Message received", rec.Body.String())
 }
 
+func TestInvalidSignature(t *testing.T) {
+	assert := assert.New(t)
+	ts, mg := getChatServer()
+	defer ts.Close()
+	mg.AppSecret = "app-secret"
+
+	for _, sig := range []string{"", "sha256=zz", sign("wrong-secret", entry), sign(mg.AppSecret, entry+" ")} {
+		before := invalidSignatures.Value()
+		req, _ := http.NewRequest("POST", ts.URL, strings.NewReader(entry))
+		if sig != "" {
+			req.Header.Set(signatureHeader, sig)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		assert.NoError(err)
+		resp.Body.Close()
+		assert.Equal(http.StatusForbidden, resp.StatusCode, sig)
+		assert.Equal(before+1, invalidSignatures.Value(), sig)
+	}
+
+	// requests are rejected when app secret is not configured
+	mg.AppSecret = ""
+	req, _ := http.NewRequest("POST", ts.URL, strings.NewReader(entry))
+	req.Header.Set(signatureHeader, sign("", entry))
+	resp, err := http.DefaultClient.Do(req)
+	assert.NoError(err)
+	resp.Body.Close()
+	assert.Equal(http.StatusForbidden, resp.StatusCode)
+}
+
+func sign(secret, body string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(body))
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
 type messageTestHandler struct {
 	MessageHandler
 }
@@ -165,10 +213,40 @@ func TestMain(m *testing.M) {
 	mg = New(ch)
 
 	srv = web.New("0.0.0.0:5050")
-	models.Connect()
+	models.UseStore(models.NewMemoryStore())
 
 	user = models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
 
 	m.Run()
 	models.Close()
 }
+
+func TestPushMessages(t *testing.T) {
+	assert := assert.New(t)
+	fs := getFbServer()
+	defer fs.Close()
+
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	pub := time.Now()
+	bu.LastInteraction = &pub
+	bu.Save()
+	topic := fake.Word()
+	sub := models.NewSubscription(bu.ID, topic)
+	sub.Save()
+
+	link := fake.DomainName()
+	article := models.NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+	article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10)))
+	article.SetTopic(topic, []string{})
+	article.Save()
+
+	n, err := mg.pushSubscription(sub, pub.Add(-time.Hour))
+	assert.NoError(err)
+	assert.Equal(1, n)
+	assert.True(models.IsItemSent(bu.ID, article.Key()))
+
+	// already sent articles are not pushed again
+	n, err = mg.pushSubscription(sub, pub.Add(-time.Hour))
+	assert.NoError(err)
+	assert.Equal(0, n)
+}
@@ -0,0 +1,231 @@
+package messenger
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	defaultOutboxAttempts = 5
+	defaultOutboxDelay    = 5 * time.Second
+	// outboxBatch number of pending messages loaded at once
+	outboxBatch = 20
+	// outboxLease how long a claimed message is held by its sender
+	outboxLease = time.Minute
+)
+
+// Outbox durable queue of outgoing messages, messages are persisted
+// before sending and delivered in order by one goroutine per recipient.
+// Messages are claimed before they're sent so outboxes of several
+// processes don't send them twice
+type Outbox struct {
+	client *Client
+	// id owner of messages claimed by the outbox
+	id string
+	// MaxAttempts attempts before a message is moved to dead letters
+	MaxAttempts int
+	// RetryDelay delay before retrying a failed message, doubled on every attempt
+	RetryDelay time.Duration
+	mu         sync.Mutex
+	// active recipients with a running sender
+	active map[string]bool
+	// dirty recipients with messages enqueued since their last fetch
+	dirty   map[string]bool
+	lastSeq int64
+	wg      sync.WaitGroup
+	now     func() time.Time
+	sleep   func(time.Duration)
+}
+
+// NewOutbox returns new outbox sending messages with client
+func NewOutbox(client *Client) *Outbox {
+	host, _ := os.Hostname()
+	return &Outbox{
+		client:      client,
+		id:          fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano()),
+		MaxAttempts: defaultOutboxAttempts,
+		RetryDelay:  defaultOutboxDelay,
+		active:      map[string]bool{},
+		dirty:       map[string]bool{},
+		now:         time.Now,
+		sleep:       time.Sleep,
+	}
+}
+
+// Start resumes sending messages left pending by a previous run,
+// messages held by outboxes of other processes are left to them
+func (o *Outbox) Start() error {
+	pending, err := models.GetPendingMessages("", 0)
+	if err != nil {
+		return err
+	}
+	recipients := map[string]bool{}
+	for _, m := range pending {
+		if !recipients[m.Recipient] {
+			recipients[m.Recipient] = true
+			o.notify(m.Recipient)
+		}
+	}
+	logger.Infof("Outbox resumed %d messages of %d users", len(pending), len(recipients))
+	return nil
+}
+
+// Enqueue persists messages of recipient and schedules them for sending,
+// ids of sent messages are recorded on msg when it's not nil
+func (o *Outbox) Enqueue(recipient string, msg *datastore.Key, messages ...interface{}) error {
+	for _, m := range messages {
+		b, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		models.NewOutboundMessage(recipient, string(b), msg, o.nextSeq()).Save()
+	}
+	o.notify(recipient)
+	return nil
+}
+
+// Wait blocks until all senders are idle
+func (o *Outbox) Wait() {
+	o.wg.Wait()
+}
+
+// WaitTimeout waits until all senders are idle, it returns false on timeout
+func (o *Outbox) WaitTimeout(timeout time.Duration) bool {
+	done := make(chan bool)
+	go func() {
+		o.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
+
+// nextSeq returns increasing sequence numbers
+func (o *Outbox) nextSeq() int64 {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	seq := o.now().UnixNano()
+	if seq <= o.lastSeq {
+		seq = o.lastSeq + 1
+	}
+	o.lastSeq = seq
+	return seq
+}
+
+// notify starts a sender of recipient unless one is running
+func (o *Outbox) notify(recipient string) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.dirty[recipient] = true
+	if o.active[recipient] {
+		return
+	}
+	o.active[recipient] = true
+	o.wg.Add(1)
+	go o.run(recipient)
+}
+
+// run sends pending messages of recipient while it's notified
+func (o *Outbox) run(recipient string) {
+	defer o.wg.Done()
+	for {
+		o.mu.Lock()
+		if !o.dirty[recipient] {
+			delete(o.active, recipient)
+			o.mu.Unlock()
+			return
+		}
+		delete(o.dirty, recipient)
+		o.mu.Unlock()
+
+		o.send(recipient)
+	}
+}
+
+// send delivers pending messages of recipient in order until there are
+// none, it stops when another sender holds one of them which then
+// sends the rest
+func (o *Outbox) send(recipient string) {
+	for {
+		pending, err := models.GetPendingMessages(recipient, outboxBatch)
+		if err != nil {
+			logger.Error("Outbox:", err)
+			return
+		}
+		if len(pending) == 0 {
+			return
+		}
+		for _, m := range pending {
+			if !o.deliver(m) {
+				return
+			}
+		}
+	}
+}
+
+// deliver claims and sends message, retrying until it's sent or dead,
+// it returns false when the message is held by another sender
+// or its outcome could not be recorded
+func (o *Outbox) deliver(m *models.OutboundMessage) bool {
+	for {
+		if d := m.NextAttempt.Sub(o.now()); d > 0 {
+			o.sleep(d)
+		}
+		claimed, err := models.ClaimOutboundMessage(m, o.id, o.now(), outboxLease)
+		if err != nil {
+			logger.Error("Outbox:", err)
+			return false
+		}
+		if !claimed {
+			return false
+		}
+		res, err := o.client.Send(json.RawMessage(m.Payload))
+		if err == nil {
+			if m.Message != nil && res.MessageID != "" {
+				if err := models.AddMessageID(m.Message, res.MessageID); err != nil {
+					logger.Error("Outbox:", err)
+				}
+			}
+			if err := m.Delete(); err != nil {
+				logger.Error("Outbox:", err)
+				return false
+			}
+			return true
+		}
+
+		m.Attempts++
+		m.LastError = err.Error()
+		if !retryable(err) || m.Attempts >= o.MaxAttempts {
+			logger.Errorf("Outbox message to %s failed after %d attempts: %v", m.Recipient, m.Attempts, err)
+			m.Status = models.OutboundDead
+			m.Save()
+			return true
+		}
+		// the message is held until its next attempt
+		m.NextAttempt = o.now().Add(o.RetryDelay << uint(m.Attempts-1))
+		m.Lease = m.NextAttempt.Add(outboxLease)
+		m.Save()
+	}
+}
+
+// retryable reports whether a failed send may succeed later,
+// errors returned by the graph api are permanent unless temporary
+func retryable(err error) bool {
+	if fe, ok := err.(*FacebookError); ok {
+		return fe.Temporary()
+	}
+	return true
+}
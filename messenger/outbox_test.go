@@ -0,0 +1,162 @@
+package messenger
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutbox(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var received []string
+	failures := map[string]int{"2": 2}
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var m struct {
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&m)
+		text := m.Message.Text
+
+		mu.Lock()
+		defer mu.Unlock()
+		switch {
+		case text == "blocked":
+			writeGraphError(w, http.StatusBadRequest, 551, 0)
+		case failures[text] > 0:
+			failures[text]--
+			writeGraphError(w, http.StatusInternalServerError, 2, 0)
+		default:
+			received = append(received, text)
+			json.NewEncoder(w).Encode(FacebookResponse{MessageID: "mid." + text, RecipientID: rid})
+		}
+	})
+	defer fs.Close()
+	c.MaxRetries = 0
+
+	o := NewOutbox(c)
+	var sleeps []time.Duration
+	o.sleep = func(d time.Duration) {
+		sleeps = append(sleeps, d)
+	}
+
+	userID := fake.Characters()
+	msg := models.NewMessage(userID, "hi", "[]", "{}", nil)
+	msg.Save()
+
+	var messages []interface{}
+	for _, text := range []string{"1", "2", "blocked", "3"} {
+		messages = append(messages, utils.NewTextMessage(userID, text))
+	}
+	assert.NoError(o.Enqueue(userID, msg.Key(), messages...))
+	o.Wait()
+
+	// messages are sent in order, failed ones are retried
+	assert.Equal([]string{"1", "2", "3"}, received)
+	assert.Len(sleeps, 2)
+
+	m, err := models.GetMessage(msg.ID)
+	assert.NoError(err)
+	assert.Equal([]string{"mid.1", "mid.2", "mid.3"}, m.MID)
+
+	pending, err := models.GetPendingMessages(userID, 0)
+	assert.NoError(err)
+	assert.Empty(pending)
+
+	// permanent failures are moved to dead letters
+	dead, err := models.GetDeadMessages(0, 0)
+	assert.NoError(err)
+	var userDead []*models.OutboundMessage
+	for _, m := range dead {
+		if m.Recipient == userID {
+			userDead = append(userDead, m)
+		}
+	}
+	if assert.Len(userDead, 1) {
+		assert.Equal(1, userDead[0].Attempts)
+		assert.Contains(userDead[0].LastError, "FB Error")
+	}
+}
+
+func TestOutboxStart(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	count := 0
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		mu.Unlock()
+		json.NewEncoder(w).Encode(FacebookResponse{MessageID: fake.Characters(), RecipientID: rid})
+	})
+	defer fs.Close()
+
+	// messages left by a previous run
+	for i := 0; i < 3; i++ {
+		userID := fmt.Sprintf("user-%d", i)
+		models.NewOutboundMessage(userID, `{"message":{"text":"hi"}}`, nil, int64(i)).Save()
+	}
+
+	o := NewOutbox(c)
+	assert.NoError(o.Start())
+	o.Wait()
+
+	assert.Equal(3, count)
+	pending, err := models.GetPendingMessages("", 0)
+	assert.NoError(err)
+	assert.Empty(pending)
+}
+
+func TestOutboxProcesses(t *testing.T) {
+	assert := assert.New(t)
+
+	var mu sync.Mutex
+	var received []string
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		var m struct {
+			Message struct {
+				Text string `json:"text"`
+			} `json:"message"`
+		}
+		json.NewDecoder(r.Body).Decode(&m)
+		mu.Lock()
+		received = append(received, m.Message.Text)
+		mu.Unlock()
+		json.NewEncoder(w).Encode(FacebookResponse{MessageID: fake.Characters(), RecipientID: rid})
+	})
+	defer fs.Close()
+
+	userID := fake.Characters()
+	var expected []string
+	for i := 0; i < 5; i++ {
+		text := fmt.Sprint(i)
+		expected = append(expected, text)
+		payload := fmt.Sprintf(`{"message":{"text":%q}}`, text)
+		models.NewOutboundMessage(userID, payload, nil, int64(i)).Save()
+	}
+
+	// outboxes of several processes send each message once and in order
+	first, second := NewOutbox(c), NewOutbox(c)
+	assert.NotEqual(first.id, second.id)
+	assert.NoError(first.Start())
+	assert.NoError(second.Start())
+	first.Wait()
+	second.Wait()
+
+	assert.Equal(expected, received)
+	pending, err := models.GetPendingMessages(userID, 0)
+	assert.NoError(err)
+	assert.Empty(pending)
+}
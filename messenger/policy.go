@@ -0,0 +1,77 @@
+package messenger
+
+import (
+	"expvar"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+const (
+	// standardWindow time after the last interaction of a user
+	// in which pages may message the user without a tag
+	standardWindow = 24 * time.Hour
+	// skip reasons
+	skipOutsideWindow = "outside_window"
+	skipNoInteraction = "no_interaction"
+)
+
+var skippedMessages = expvar.NewMap("messenger.skipped_messages")
+
+// Policy decides how proactive messages are sent to users according
+// to the standard messaging window, messages outside the window are
+// refused. None of the message tags covers news, so they aren't used
+type Policy struct {
+	Window time.Duration
+	now    func() time.Time
+}
+
+// NewPolicy returns policy of the standard messaging window
+func NewPolicy() *Policy {
+	return &Policy{Window: standardWindow, now: time.Now}
+}
+
+// Apply sets messaging type of proactive messages to user,
+// it returns the reason when they may not be sent
+func (p *Policy) Apply(user *models.User, messages ...utils.Message) string {
+	if !user.InWindow(p.now(), p.Window) {
+		if user.LastInteraction == nil {
+			return skipNoInteraction
+		}
+		return skipOutsideWindow
+	}
+	for _, m := range messages {
+		m.SetMessagingType(utils.MessagingTypeUpdate, "")
+	}
+	return ""
+}
+
+// ApplySubscription is Apply for messages of subscription, messages
+// which may not be sent otherwise use the notification token of sub.
+// It also reports whether the token is used, callers record the
+// notification with notified once the messages are sent
+func (p *Policy) ApplySubscription(user *models.User, sub *models.Subscription, messages ...utils.Message) (string, bool) {
+	reason := p.Apply(user, messages...)
+	if reason == "" || !sub.CanNotify(p.now()) {
+		return reason, false
+	}
+	for _, m := range messages {
+		m.SetNotificationToken(sub.NotificationToken)
+		m.SetMessagingType(utils.MessagingTypeUpdate, "")
+	}
+	return "", true
+}
+
+// notified records a notification sent with the token of sub
+func (p *Policy) notified(sub *models.Subscription) {
+	now := p.now()
+	sub.LastNotified = &now
+	sub.Save()
+}
+
+// skip counts message to user which was not sent
+func (p *Policy) skip(userID, reason string) {
+	logger.Infof("Message to %s skipped: %s", userID, reason)
+	skippedMessages.Add(reason, 1)
+}
@@ -0,0 +1,180 @@
+package messenger
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/chatbot"
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicy(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	p := &Policy{Window: standardWindow, now: func() time.Time { return now }}
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	tm := utils.NewTextMessage(bu.ID, "hi")
+	gm := utils.NewGenericMessage(bu.ID)
+	assert.Equal(utils.MessagingTypeResponse, tm.MessagingType)
+
+	assert.Equal(skipNoInteraction, p.Apply(bu, tm, gm))
+
+	last := now.Add(-time.Hour)
+	bu.LastInteraction = &last
+	assert.Empty(p.Apply(bu, tm, gm))
+	assert.Equal(utils.MessagingTypeUpdate, tm.MessagingType)
+	assert.Equal(utils.MessagingTypeUpdate, gm.MessagingType)
+
+	last = now.Add(-standardWindow)
+	assert.Equal(skipOutsideWindow, p.Apply(bu, tm))
+}
+
+func TestPushOutsideWindow(t *testing.T) {
+	assert := assert.New(t)
+	fs := getFbServer()
+	defer fs.Close()
+
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	last := time.Now().Add(-2 * standardWindow)
+	bu.LastInteraction = &last
+	bu.Save()
+	topic := fake.Word()
+	sub := models.NewSubscription(bu.ID, topic)
+	sub.Save()
+
+	pub := time.Now()
+	link := fake.DomainName()
+	article := models.NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+	article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10)))
+	article.SetTopic(topic, []string{})
+	article.Save()
+
+	skipped := skippedMessages.String()
+	n, err := mg.pushSubscription(sub, pub.Add(-time.Hour))
+	assert.NoError(err)
+	assert.Equal(0, n)
+	assert.False(models.IsItemSent(bu.ID, article.Key()))
+	assert.NotEqual(skipped, skippedMessages.String())
+}
+
+func TestApplySubscription(t *testing.T) {
+	assert := assert.New(t)
+
+	now := time.Now()
+	p := &Policy{Window: standardWindow, now: func() time.Time { return now }}
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	sub := models.NewSubscription(bu.ID, fake.Word())
+	sub.Save()
+	gm := utils.NewGenericMessage(bu.ID)
+
+	reason, token := p.ApplySubscription(bu, sub, gm)
+	assert.Equal(skipNoInteraction, reason)
+	assert.False(token)
+
+	// subscriptions with a token are notified once a day
+	sub.SetNotificationToken("token", nil)
+	reason, token = p.ApplySubscription(bu, sub, gm)
+	assert.Empty(reason)
+	assert.True(token)
+	assert.Equal(utils.Recipient{NotificationToken: "token"}, gm.Recipient)
+	assert.Equal(utils.MessagingTypeUpdate, gm.MessagingType)
+	// notifications are recorded once they're sent
+	assert.Nil(sub.LastNotified)
+	p.notified(sub)
+	reason, _ = p.ApplySubscription(bu, sub, utils.NewGenericMessage(bu.ID))
+	assert.Equal(skipNoInteraction, reason)
+
+	// tokens are not used within the window
+	last := now
+	bu.LastInteraction = &last
+	gm = utils.NewGenericMessage(bu.ID)
+	reason, token = p.ApplySubscription(bu, sub, gm)
+	assert.Empty(reason)
+	assert.False(token)
+	assert.Equal(bu.ID, gm.Recipient.ID)
+}
+
+func TestBreakingNotificationSent(t *testing.T) {
+	assert := assert.New(t)
+
+	fail := true
+	calls := 0
+	c, fs, _ := newTestClient(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if fail {
+			writeGraphError(w, http.StatusBadRequest, 100, 0)
+			return
+		}
+		json.NewEncoder(w).Encode(FacebookResponse{MessageID: fake.Characters(), RecipientID: rid})
+	})
+	defer fs.Close()
+	pmg := New(chatbot.New("Test"))
+	pmg.Client = c
+
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	bu.Save()
+	topic := fake.Word()
+	sub := models.NewSubscription(bu.ID, topic)
+	sub.SetNotificationToken("token", nil)
+	sub.Save()
+
+	pub := time.Now()
+	link := fake.DomainName()
+	article := models.NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+	article.AddAssessment(utils.NewTextAnalysis(fake.SentencesN(10), fake.SentencesN(10)))
+	article.SetTopic(topic, []string{})
+	article.Save()
+
+	// pushes don't use the token kept for breaking news
+	n, err := pmg.pushSubscription(sub, pub.Add(-time.Hour))
+	assert.NoError(err)
+	assert.Equal(0, n)
+	assert.Equal(0, calls)
+	assert.Nil(sub.LastNotified)
+
+	// failed notifications don't use up the token
+	s := &breakingStory{lead: article, articles: []*models.Article{article}}
+	assert.Equal("error", pmg.Breaking.alertUser(sub, s))
+	assert.Nil(sub.LastNotified)
+
+	fail = false
+	assert.Equal("sent", pmg.Breaking.alertUser(sub, s))
+	assert.NotNil(sub.LastNotified)
+}
+
+func TestNotificationOptin(t *testing.T) {
+	assert := assert.New(t)
+	fs := getFbServer()
+	defer fs.Close()
+
+	bu := models.NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	topic := fake.Word()
+	models.NewSubscription(bu.ID, topic).Save()
+
+	h := &DefaultHandler{mg}
+	optin := &messaging{Sender: Recipient{ID: bu.ID}, Optin: &FacebookOptin{
+		Type:              notificationOptin,
+		Payload:           utils.NotifyPayload + topic,
+		NotificationToken: "token",
+		TokenExpiry:       time.Now().Add(time.Hour).UnixNano() / int64(time.Millisecond),
+	}}
+	h.ProcessOptin(optin)
+	mg.Outbox.Wait()
+
+	sub, err := models.GetUserSubscription(bu.ID, topic)
+	assert.NoError(err)
+	assert.Equal("token", sub.NotificationToken)
+	assert.True(sub.CanNotify(time.Now()))
+
+	optin.Optin.Status = stopNotifications
+	h.ProcessOptin(optin)
+	sub, _ = models.GetUserSubscription(bu.ID, topic)
+	assert.Empty(sub.NotificationToken)
+}
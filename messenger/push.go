@@ -0,0 +1,110 @@
+package messenger
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/epigos/newsbot/utils"
+)
+
+const (
+	// pushSize maximum number of articles in a pushed carousel
+	pushSize = 5
+	// pushWindow how far back to look for new articles
+	pushWindow = time.Hour * 24
+)
+
+var pushMu sync.Mutex
+
+// PushMessages push new crawled items to users
+func (mg *Messenger) PushMessages() {
+	pushMu.Lock()
+	defer pushMu.Unlock()
+
+	logger.Info("Crawl done,", "push messages")
+
+	subs, err := models.GetAllSubscriptions()
+	if err != nil {
+		logger.Error("Push subscriptions:", err)
+		return
+	}
+
+	since := time.Now().Add(-pushWindow)
+	sent := 0
+	for _, sub := range subs {
+		// digests are sent by the digest scheduler
+		if !sub.IsRealtime() {
+			continue
+		}
+		n, err := mg.pushSubscription(sub, since)
+		if err != nil {
+			switch ErrorKind(err) {
+			case ErrBadToken:
+				// every other message would fail too
+				logger.Errorf("Push aborted: %v", err)
+				return
+			case ErrUserBlocked:
+				logger.Infof("Push %s skipped, %s can't receive messages", sub, sub.User.Name)
+			default:
+				logger.Errorf("Push %s to %s: %v", sub, sub.User.Name, err)
+			}
+			continue
+		}
+		sent += n
+	}
+	logger.Infof("Pushed %d articles to %d subscriptions", sent, len(subs))
+}
+
+// pushSubscription sends unsent articles of subscribed topic to the user
+func (mg *Messenger) pushSubscription(sub *models.Subscription, since time.Time) (int, error) {
+	userID := sub.User.Name
+
+	articles, err := models.GetNewTopicArticles(sub.Topic, since, pushSize*2)
+	if err != nil {
+		return 0, err
+	}
+
+	var items []*models.Article
+	for _, article := range articles {
+		if len(items) == pushSize {
+			break
+		}
+		if models.IsItemSent(userID, article.Key()) {
+			continue
+		}
+		items = append(items, article)
+	}
+	if len(items) < 1 {
+		return 0, nil
+	}
+
+	gm := utils.NewGenericMessage(userID)
+	for _, article := range items {
+		gm.AddElement(article.ToMessengerElement(userID))
+	}
+	user, err := models.GetUser(userID)
+	if err != nil {
+		logger.Warnf("Push to unknown user %s: %v", userID, err)
+	}
+	// notification tokens are kept for breaking news
+	if reason := mg.Policy.Apply(user, gm); reason != "" {
+		mg.Policy.skip(userID, reason)
+		return 0, nil
+	}
+	res, err := mg.SendMessage(gm)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, article := range items {
+		models.NewSentItem(userID, article.Key()).Save()
+	}
+	// log outgoing message
+	bs, _ := json.Marshal([]interface{}{gm})
+	msg := models.NewMessage(userID, sub.String(), string(bs), "", []string{res.MessageID})
+	msg.Save()
+
+	return len(items), nil
+}
@@ -22,12 +22,7 @@ func NewSenderAction(userID string, s string) *SenderAction {
 
 func (mg *Messenger) sendAction(s *Recipient, action string) {
 	a := NewSenderAction(s.ID, action)
-	resp, err := mg.makeFbRequest(messagesPath, "POST", a)
-	if err != nil {
-		logger.Error(err)
-	}
-	_, err = mg.decodeResponse(resp)
-	if err != nil {
+	if _, err := mg.Client.Send(a); err != nil {
 		logger.Error(err)
 	}
 }
@@ -118,19 +118,19 @@ func GetDefaultMenu() *PersistentMenu {
 func (mg *Messenger) SetupPage() {
 	// setup get started button
 	getStarted := NewGetStarted()
-	_, err := mg.makeFbRequest(profilePath, "POST", getStarted)
+	err := mg.Client.Do("POST", profilePath, getStarted, nil)
 	if err != nil {
 		logger.Info("FB get started error:", err)
 	}
 
 	greetingText := NewGreetingText()
-	_, err = mg.makeFbRequest(profilePath, "POST", greetingText)
+	err = mg.Client.Do("POST", profilePath, greetingText, nil)
 	if err != nil {
 		logger.Info("FB greeting setup error:", err)
 	}
 
 	menu := GetDefaultMenu()
-	_, err = mg.makeFbRequest(profilePath, "POST", menu)
+	err = mg.Client.Do("POST", profilePath, menu, nil)
 	if err != nil {
 		logger.Info("FB menu setup error:", err)
 	}
@@ -0,0 +1,228 @@
+package messenger
+
+import (
+	"errors"
+	"expvar"
+	"hash/fnv"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkers = 8
+	// event types
+	eventMessage        = "message"
+	eventDelivery       = "delivery"
+	eventPostback       = "postback"
+	eventRead           = "read"
+	eventOptin          = "optin"
+	eventReferral       = "referral"
+	eventAccountLinking = "account_linking"
+)
+
+var (
+	// errQueueFull returned when workers can't keep up with events
+	errQueueFull = errors.New("messenger queue is full")
+	errStopped   = errors.New("messenger is stopped")
+
+	queueDepth    = expvar.NewInt("messenger.queue_depth")
+	rejected      = expvar.NewInt("messenger.rejected_events")
+	duplicates    = expvar.NewInt("messenger.duplicate_events")
+	eventCount    = expvar.NewMap("messenger.events")
+	eventLatency  = expvar.NewMap("messenger.latency_seconds")
+	eventQueueing = expvar.NewMap("messenger.queue_seconds")
+)
+
+// event messenger event waiting for a worker
+type event struct {
+	kind     string
+	m        *messaging
+	received time.Time
+}
+
+// workerPool bounded pool of workers processing messenger events,
+// events of a user are always processed by the same worker in order
+type workerPool struct {
+	mg      *Messenger
+	mu      sync.RWMutex
+	queues  []chan *event
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// newWorkerPool returns pool configured with MESSENGER_WORKERS
+// and MESSENGER_QUEUE_SIZE, the queue size is shared by workers
+func newWorkerPool(mg *Messenger) *workerPool {
+	workers := envInt("MESSENGER_WORKERS", defaultWorkers)
+	size := envInt("MESSENGER_QUEUE_SIZE", bufSize) / workers
+	if size < 1 {
+		size = 1
+	}
+	p := &workerPool{mg: mg, queues: make([]chan *event, workers)}
+	for i := range p.queues {
+		p.queues[i] = make(chan *event, size)
+	}
+	return p
+}
+
+func envInt(name string, def int) int {
+	if n, err := strconv.Atoi(os.Getenv(name)); err == nil && n > 0 {
+		return n
+	}
+	return def
+}
+
+// start starts the workers
+func (p *workerPool) start() {
+	for _, q := range p.queues {
+		p.wg.Add(1)
+		go p.work(q)
+	}
+}
+
+// queue returns queue of user
+func (p *workerPool) queue(userID string) chan *event {
+	h := fnv.New32a()
+	h.Write([]byte(userID))
+	return p.queues[h.Sum32()%uint32(len(p.queues))]
+}
+
+// add queues events without blocking, no event is queued unless
+// there's room for all of them. Events of concurrent adds which
+// don't fit anymore are rejected, facebook redelivers them and
+// events queued before are ignored as duplicates
+func (p *workerPool) add(events []*event) error {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if p.stopped {
+		return errStopped
+	}
+	needed := map[chan *event]int{}
+	for _, e := range events {
+		needed[p.queue(e.m.Sender.ID)]++
+	}
+	for q, n := range needed {
+		if len(q)+n > cap(q) {
+			rejected.Add(int64(len(events)))
+			return errQueueFull
+		}
+	}
+	for i, e := range events {
+		queueDepth.Add(1)
+		select {
+		case p.queue(e.m.Sender.ID) <- e:
+		default:
+			queueDepth.Add(-1)
+			rejected.Add(int64(len(events) - i))
+			return errQueueFull
+		}
+	}
+	return nil
+}
+
+// work processes events of queue until it's closed
+func (p *workerPool) work(q chan *event) {
+	defer p.wg.Done()
+	for e := range q {
+		queueDepth.Add(-1)
+		start := time.Now()
+		eventQueueing.AddFloat(e.kind, start.Sub(e.received).Seconds())
+
+		p.process(e)
+
+		eventCount.Add(e.kind, 1)
+		eventLatency.AddFloat(e.kind, time.Since(start).Seconds())
+	}
+}
+
+func (p *workerPool) process(e *event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("Processing %s event of %s: %v", e.kind, e.m.Sender.ID, r)
+		}
+	}()
+
+	if id := eventID(e); id != "" {
+		first, err := p.mg.Dedup.Claim(id)
+		if err != nil {
+			logger.Error("Dedup:", err)
+		}
+		if !first {
+			duplicates.Add(1)
+			logger.Debugf("Ignoring duplicate event %s", id)
+			return
+		}
+	}
+
+	switch e.kind {
+	case eventMessage, eventPostback, eventReferral, eventOptin:
+		// plugin optins of unknown users have no sender
+		if e.m.Sender.ID != "" {
+			e.m.Sender.Profile = p.mg.GetSenderProfile(e.m.Sender.ID)
+			touch(e)
+		}
+	}
+
+	h := p.mg.Handler
+	switch e.kind {
+	case eventMessage:
+		h.ProcessMessage(e.m)
+	case eventDelivery:
+		h.ProcessDelivery(e.m)
+	case eventPostback:
+		h.ProcessPostback(e.m)
+	case eventRead:
+		h.ProcessRead(e.m)
+	case eventOptin:
+		h.ProcessOptin(e.m)
+	case eventReferral:
+		h.ProcessReferral(e.m)
+	case eventAccountLinking:
+		h.ProcessAccountLinking(e.m)
+	}
+}
+
+// touch records the interaction of the sender of event which opens
+// the messaging window, profiles which couldn't be fetched aren't saved
+func touch(e *event) {
+	user := e.m.Sender.Profile
+	if user == nil || user.Created.IsZero() {
+		return
+	}
+	t := e.received
+	if e.m.Timestamp > 0 {
+		t = time.Unix(0, int64(e.m.Timestamp)*int64(time.Millisecond))
+	}
+	if user.LastInteraction == nil || t.After(*user.LastInteraction) {
+		user.LastInteraction = &t
+		user.Save()
+	}
+}
+
+// stop stops accepting events and waits until queued
+// events are processed, it returns false on timeout
+func (p *workerPool) stop(timeout time.Duration) bool {
+	p.mu.Lock()
+	if !p.stopped {
+		p.stopped = true
+		for _, q := range p.queues {
+			close(q)
+		}
+	}
+	p.mu.Unlock()
+
+	done := make(chan bool)
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
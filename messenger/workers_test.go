@@ -0,0 +1,132 @@
+package messenger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/chatbot"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordHandler struct {
+	mu     sync.Mutex
+	events map[string][]string
+	block  chan bool
+}
+
+func (h *recordHandler) record(kind string, m *messaging) {
+	if h.block != nil {
+		<-h.block
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.events[m.Sender.ID] = append(h.events[m.Sender.ID], fmt.Sprintf("%s:%s", kind, m.Mid()))
+}
+
+func (h *recordHandler) ProcessMessage(m *messaging)  { h.record(eventMessage, m) }
+func (h *recordHandler) ProcessDelivery(m *messaging) { h.record(eventDelivery, m) }
+func (h *recordHandler) ProcessPostback(m *messaging) { h.record(eventPostback, m) }
+func (h *recordHandler) ProcessRead(m *messaging)     { h.record(eventRead, m) }
+func (h *recordHandler) ProcessOptin(m *messaging)    { h.record(eventOptin, m) }
+func (h *recordHandler) ProcessReferral(m *messaging) { h.record(eventReferral, m) }
+func (h *recordHandler) ProcessAccountLinking(m *messaging) {
+	h.record(eventAccountLinking, m)
+}
+
+func newEntry(userID string, mids ...string) facebookEntry {
+	var entry facebookEntry
+	for _, mid := range mids {
+		msg := messaging{Sender: Recipient{ID: userID}}
+		switch mid[0] {
+		case 'd':
+			msg.Delivery = &FacebookDelivery{Mids: []string{mid}}
+		case 'p':
+			msg.Postback = &FacebookPostback{Payload: mid}
+		default:
+			msg.Message = &FacebookMessage{Mid: mid}
+		}
+		entry.Messaging = append(entry.Messaging, msg)
+	}
+	return entry
+}
+
+func TestWorkerPool(t *testing.T) {
+	assert := assert.New(t)
+	fs := getFbServer()
+	defer fs.Close()
+
+	os.Setenv("MESSENGER_WORKERS", "2")
+	os.Setenv("MESSENGER_QUEUE_SIZE", "8")
+	defer os.Unsetenv("MESSENGER_WORKERS")
+	defer os.Unsetenv("MESSENGER_QUEUE_SIZE")
+
+	mg := New(chatbot.New("Test"))
+	h := &recordHandler{events: map[string][]string{}, block: make(chan bool)}
+	mg.Handler = h
+	mg.workers.start()
+
+	// events are rejected when the queue of a worker is full
+	assert.NoError(mg.processEntry([]facebookEntry{newEntry("u1", "m1", "d2", "m3")}))
+	assert.Equal(errQueueFull, mg.processEntry([]facebookEntry{newEntry("u1", "m4", "m5")}))
+
+	close(h.block)
+	assert.NoError(mg.processEntry([]facebookEntry{newEntry("u2", "p1", "m2")}))
+
+	// redelivered events are ignored
+	assert.NoError(mg.processEntry([]facebookEntry{newEntry("u2", "m2")}))
+
+	// queued events are processed before shutdown
+	mg.Shutdown(time.Second)
+	assert.Equal([]string{"message:m1", "delivery:", "message:m3"}, h.events["u1"])
+	assert.Equal([]string{"postback:", "message:m2"}, h.events["u2"])
+	assert.Equal(int64(0), queueDepth.Value())
+
+	assert.Equal(errStopped, mg.processEntry([]facebookEntry{newEntry("u1", "m6")}))
+}
+
+func TestWorkerPoolConcurrentAdds(t *testing.T) {
+	assert := assert.New(t)
+
+	os.Setenv("MESSENGER_WORKERS", "1")
+	os.Setenv("MESSENGER_QUEUE_SIZE", "4")
+	defer os.Unsetenv("MESSENGER_WORKERS")
+	defer os.Unsetenv("MESSENGER_QUEUE_SIZE")
+
+	// events aren't consumed, adds never block on a full queue
+	mg := New(chatbot.New("Test"))
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			errs <- mg.processEntry([]facebookEntry{newEntry("u1", fmt.Sprintf("m%d", i))})
+		}(i)
+	}
+	done := make(chan bool)
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("adding events blocked")
+	}
+	close(errs)
+
+	queued := 0
+	for err := range errs {
+		if err == nil {
+			queued++
+		} else {
+			assert.Equal(errQueueFull, err)
+		}
+	}
+	assert.Equal(4, queued)
+	queueDepth.Add(-int64(queued))
+}
@@ -13,7 +13,7 @@ import (
 	"cloud.google.com/go/datastore"
 )
 
-//ArticleKind kind name for articles
+// ArticleKind kind name for articles
 const (
 	ArticleKind = "Articles"
 	pageSize    = 6
@@ -35,23 +35,28 @@ type Assessment struct {
 	ReadingTime          string  `json:"reading_time,omitempty" datastore:",noindex"`
 }
 
-// Article structs for crawled article
+// Article structs for crawled article, CheckedScore is
+// its score at the last breaking news check
 type Article struct {
-	ID          string         `json:"id" datastore:"-"`
-	Title       string         `json:"title"`
-	Description string         `json:"description,omitempty" datastore:",noindex"`
-	Summary     []string       `json:"summary,omitempty" datastore:",noindex"`
-	Link        string         `json:"link"`
-	Domain      string         `json:"domain"`
-	TopicKey    *datastore.Key `json:"topic"`
-	Author      string         `json:"author,omitempty" datastore:",noindex"`
-	Image       string         `json:"image" datastore:",noindex"`
-	Tags        []string       `json:"tags,omitempty"`
-	Assessment  *Assessment    `json:"assessment,omitempty" datastore:",noindex"`
-	Score       float64        `json:"score"`
-	Published   *time.Time     `json:"published,omitempty"`
-	Created     time.Time      `json:"created"`
-	Updated     time.Time      `json:"updated"`
+	ID           string         `json:"id" datastore:"-"`
+	Title        string         `json:"title"`
+	Description  string         `json:"description,omitempty" datastore:",noindex"`
+	Summary      []string       `json:"summary,omitempty" datastore:",noindex"`
+	Link         string         `json:"link"`
+	Domain       string         `json:"domain"`
+	TopicKey     *datastore.Key `json:"topic"`
+	StoryKey     *datastore.Key `json:"story,omitempty"`
+	Author       string         `json:"author,omitempty" datastore:",noindex"`
+	Image        string         `json:"image" datastore:",noindex"`
+	Tags         []string       `json:"tags,omitempty"`
+	Assessment   *Assessment    `json:"assessment,omitempty" datastore:",noindex"`
+	Score        float64        `json:"score"`
+	Published    *time.Time     `json:"published,omitempty"`
+	Breaking     *time.Time     `json:"breaking,omitempty"`
+	CheckedScore float64        `json:"-" datastore:",noindex"`
+	ScoreChecked *time.Time     `json:"-" datastore:",noindex"`
+	Created      time.Time      `json:"created"`
+	Updated      time.Time      `json:"updated"`
 }
 
 // NewArticle returns article
@@ -104,7 +109,7 @@ func (m *Article) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(ArticleKind)
+		return NewKey(ArticleKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
@@ -130,34 +135,54 @@ func GetArticle(id string) (*Article, error) {
 	return &entity, err
 }
 
+// GetArticleByLink returns article with link, articles whose
+// guid is the link are found too
+func GetArticleByLink(link string) (*Article, error) {
+	var articles []*Article
+	query := NewBaseQuery(ArticleKind, []*Filter{NewFilter("Link =", link)})
+	query.Limit = 1
+
+	keys, err := DS.GetAll(query, &articles)
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) > 0 {
+		articles[0].SetID(keys[0])
+		return articles[0], nil
+	}
+	return GetArticle(link)
+}
+
 // Save article
 func (m *Article) Save() {
-	DS.Logger.Info("Saving article:", m)
+	logger.Info("Saving article:", m)
 	DS.Save(m)
 }
 
-// Delete article
+// Delete deletes article and removes it from the search index
 func (m *Article) Delete() error {
+	if err := m.unindex(); err != nil {
+		return err
+	}
 	return DS.Delete(m.Key())
 }
 
-// SearchArticle search article based on params from dialogflow
+// SearchArticle search article based on params from dialogflow,
+// keywords are matched with the full-text search index, stories
+// of several sources are found once
 func SearchArticle(params utils.Map, page int) ([]*Article, error) {
 	var filters []*Filter
 	var query *Query
 	var articles []*Article
 
+	if kwd := params.Get("keyword", ""); kwd != "" {
+		return searchArticleText(kwd.(string), params, page)
+	}
 	if c := params.Get("category", ""); c != "" {
 		name := strings.Title(c.(string))
 		topic := GetTopicKey(name)
 		filters = append(filters, NewFilter("TopicKey =", topic))
 	}
-	if kwd := params.Get("keyword", ""); kwd != "" {
-		for _, word := range strings.Fields(kwd.(string)) {
-			q := strings.ToLower(strings.TrimSpace(word))
-			filters = append(filters, NewFilter("Tags =", q))
-		}
-	}
 	if dt := params.Get("date-time", ""); dt != "" {
 		filters = append(filters, NewFilter("Published >=", dt))
 	}
@@ -173,6 +198,23 @@ func SearchArticle(params utils.Map, page int) ([]*Article, error) {
 
 	query = NewQuery(ArticleKind, filters, pageSize, page, "-Published")
 
+	keys, err := DS.GetAll(query, &articles)
+	for i, key := range keys {
+		articles[i].SetID(key)
+	}
+	return GroupStories(articles), err
+}
+
+// GetNewTopicArticles returns latest articles for topic published after since
+func GetNewTopicArticles(topic *datastore.Key, since time.Time, limit int) ([]*Article, error) {
+	var articles []*Article
+
+	filters := []*Filter{
+		NewFilter("TopicKey =", topic),
+		NewFilter("Published >=", since),
+	}
+	query := NewQuery(ArticleKind, filters, limit, 1, "-Published")
+
 	keys, err := DS.GetAll(query, &articles)
 	for i, key := range keys {
 		articles[i].SetID(key)
@@ -180,6 +222,45 @@ func SearchArticle(params utils.Map, page int) ([]*Article, error) {
 	return articles, err
 }
 
+// GetRecentArticles returns articles crawled after since, newest first
+func GetRecentArticles(since time.Time, limit int) ([]*Article, error) {
+	var articles []*Article
+
+	filters := []*Filter{NewFilter("Created >=", since)}
+	query := NewQuery(ArticleKind, filters, limit, 1, "-Created")
+
+	keys, err := DS.GetAll(query, &articles)
+	for i, key := range keys {
+		articles[i].SetID(key)
+	}
+	return articles, err
+}
+
+// IsBreaking reports whether article is flagged as breaking news
+func (m *Article) IsBreaking() bool {
+	return m.Breaking != nil
+}
+
+// CheckScore records the score of article m as checked in a transaction,
+// the article is reloaded. It returns the score gain since the last check,
+// articles checked for the first time have no gain
+func CheckScore(m *Article, now time.Time) (float64, error) {
+	var gain float64
+	err := DS.Update(m, func(exists bool) error {
+		if !exists {
+			return datastore.ErrNoSuchEntity
+		}
+		gain = 0
+		if m.ScoreChecked != nil {
+			gain = m.Score - m.CheckedScore
+		}
+		t := now
+		m.CheckedScore, m.ScoreChecked = m.Score, &t
+		return nil
+	})
+	return gain, err
+}
+
 // ToMessengerElement converts news article to messenger template
 func (m *Article) ToMessengerElement(userID string) *utils.Element {
 	bs := []*utils.Button{
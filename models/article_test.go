@@ -65,9 +65,10 @@ func TestSearchArticle(t *testing.T) {
 	nw.AddAssessment(ta)
 	nw.SetTopic(topic, ts)
 	nw.Save()
+	assert.NoError(nw.Index(fake.SentencesN(5)))
 
 	m := utils.Map{
-		"keyword":   ts[0],
+		"keyword":   title,
 		"date-time": pub,
 		"category":  topic,
 		"source":    link,
@@ -82,3 +83,49 @@ func TestSearchArticle(t *testing.T) {
 	assert.NoError(err)
 	assert.NotEmpty(articles)
 }
+
+func TestGetArticleByLink(t *testing.T) {
+	assert := assert.New(t)
+
+	pub := time.Now()
+	link := fake.DomainName()
+	nw := NewArticle(fake.SentencesN(1), fake.Characters(), fake.SentencesN(2), link, link, link, &pub, []string{})
+	nw.Save()
+
+	article, err := GetArticleByLink(link)
+	assert.NoError(err)
+	assert.Equal(nw.ID, article.ID)
+
+	article, err = GetArticleByLink(nw.ID)
+	assert.NoError(err)
+	assert.Equal(nw.Link, article.Link)
+
+	_, err = GetArticleByLink(fake.DomainName())
+	assert.Error(err)
+}
+
+func TestCheckScore(t *testing.T) {
+	assert := assert.New(t)
+
+	pub := time.Now()
+	link := fake.DomainName()
+	nw := NewArticle(fake.SentencesN(1), fake.Characters(), fake.SentencesN(2), link, link, link, &pub, []string{})
+	nw.Score = 0.5
+	nw.Save()
+
+	// the first check is the baseline
+	gain, err := CheckScore(nw, pub)
+	assert.NoError(err)
+	assert.Equal(0.0, gain)
+
+	a, _ := GetArticle(nw.ID)
+	a.Score = 0.75
+	a.Save()
+	gain, err = CheckScore(nw, pub)
+	assert.NoError(err)
+	assert.Equal(0.25, gain)
+	assert.Equal(0.75, nw.Score)
+
+	_, err = CheckScore(&Article{ID: fake.Characters()}, pub)
+	assert.Error(err)
+}
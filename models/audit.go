@@ -34,12 +34,12 @@ func (m *AuditRequest) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(AuditRequestKind)
+		return NewKey(AuditRequestKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
 	// on the one provided.
-	return DS.Key(AuditRequestKind, m.ID)
+	return NameKey(AuditRequestKind, m.ID)
 }
 
 // SetID set id
@@ -0,0 +1,118 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// CrawlRunKind kind name for crawl runs
+const CrawlRunKind = "CrawlRuns"
+
+// feed statuses of crawl runs
+const (
+	FeedOK          = "ok"
+	FeedNotModified = "not_modified"
+	FeedError       = "error"
+)
+
+// CrawlRun a run of the crawler and the results of its spiders
+type CrawlRun struct {
+	ID      string    `datastore:"-" json:"id"`
+	Started time.Time `json:"started"`
+	// Duration of the run in seconds
+	Duration float64     `json:"duration" datastore:",noindex"`
+	Spiders  []SpiderRun `json:"spiders" datastore:",noindex"`
+	Created  time.Time   `json:"created"`
+	Updated  time.Time   `json:"updated"`
+}
+
+// SpiderRun results of a spider in a crawl run, fetched pages
+// which are not saved are counted by their skip reason
+type SpiderRun struct {
+	Name          string    `json:"name"`
+	Fetched       int       `json:"fetched"`
+	Skipped       int       `json:"skipped"`
+	New           int       `json:"new"`
+	Saved         int       `json:"saved"`
+	Errors        int       `json:"errors"`
+	NoImage       int       `json:"no_image"`
+	NoDescription int       `json:"no_description"`
+	EmptyBody     int       `json:"empty_body"`
+	Failures      int       `json:"failures"`
+	Feeds         []FeedRun `json:"feeds"`
+}
+
+// FeedRun result of a feed request of a spider
+type FeedRun struct {
+	URL      string `json:"url"`
+	Category string `json:"category"`
+	Status   string `json:"status"`
+	Error    string `json:"error,omitempty"`
+	Items    int    `json:"items"`
+}
+
+// Key get key for crawl run
+func (m *CrawlRun) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(CrawlRunKind)
+	}
+	key, err := datastore.DecodeKey(m.ID)
+	if err != nil {
+		logger.Error("Key not found:", err)
+	}
+	return key
+}
+
+// SetID set id
+func (m *CrawlRun) SetID(key *datastore.Key) {
+	m.ID = key.Encode()
+}
+
+// Save crawl run
+func (m *CrawlRun) Save() {
+	DS.Save(m)
+}
+
+// GetCrawlRuns returns crawl runs, the latest first
+func GetCrawlRuns(limit, page int) ([]*CrawlRun, error) {
+	var runs []*CrawlRun
+	query := NewQuery(CrawlRunKind, nil, limit, page, "-Started")
+
+	keys, err := DS.GetAll(query, &runs)
+	for i, key := range keys {
+		runs[i].SetID(key)
+	}
+	return runs, err
+}
+
+// Healthy checks if spider saved articles or had nothing new to crawl,
+// spiders whose feeds or pages failed or whose pages had
+// nothing to save are unhealthy
+func (s SpiderRun) Healthy() bool {
+	if s.Saved > 0 {
+		return true
+	}
+	if s.Fetched > 0 || s.Errors > 0 {
+		return false
+	}
+	for _, f := range s.Feeds {
+		if f.Status == FeedError {
+			return false
+		}
+	}
+	return true
+}
+
+// LatestSpiderRuns returns the latest result of each spider of runs
+func LatestSpiderRuns(runs []*CrawlRun) map[string]SpiderRun {
+	latest := map[string]SpiderRun{}
+	for _, run := range runs {
+		for _, s := range run.Spiders {
+			if _, ok := latest[s.Name]; !ok {
+				latest[s.Name] = s
+			}
+		}
+	}
+	return latest
+}
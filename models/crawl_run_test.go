@@ -0,0 +1,44 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSpiderRunHealthy(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(SpiderRun{Fetched: 3, Saved: 1, EmptyBody: 2}.Healthy())
+	// nothing new to crawl
+	assert.True(SpiderRun{Skipped: 5, Feeds: []FeedRun{{Status: FeedNotModified}}}.Healthy())
+	assert.False(SpiderRun{Fetched: 3, NoImage: 3}.Healthy())
+	assert.False(SpiderRun{Errors: 1}.Healthy())
+	assert.False(SpiderRun{Feeds: []FeedRun{{Status: FeedOK}, {Status: FeedError}}}.Healthy())
+}
+
+func TestCrawlRuns(t *testing.T) {
+	assert := assert.New(t)
+
+	started := time.Now().Add(time.Hour)
+	older := &CrawlRun{Started: started, Spiders: []SpiderRun{{Name: "a", Saved: 1}, {Name: "b", Saved: 2}}}
+	older.Save()
+	latest := &CrawlRun{Started: started.Add(time.Minute), Duration: 12.5, Spiders: []SpiderRun{
+		{Name: "a", Errors: 1, Failures: 1, Feeds: []FeedRun{{URL: "http://example.com/feed", Status: FeedError, Error: "503"}}},
+	}}
+	latest.Save()
+
+	runs, err := GetCrawlRuns(2, 1)
+	assert.NoError(err)
+	if assert.Len(runs, 2) {
+		assert.Equal(latest.ID, runs[0].ID)
+		assert.Equal(12.5, runs[0].Duration)
+		assert.Equal(latest.Spiders, runs[0].Spiders)
+		assert.Equal(older.ID, runs[1].ID)
+	}
+
+	spiders := LatestSpiderRuns(runs)
+	assert.Equal(1, spiders["a"].Failures)
+	assert.Equal(2, spiders["b"].Saved)
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// CrawledItemKind kind name for crawled feed items
+const CrawledItemKind = "CrawledItems"
+
+// CrawledItem a feed item whose page was crawled, items are keyed
+// by guid and link so the crawler skips them before fetching,
+// Updated is the time of the last crawl
+type CrawledItem struct {
+	ID      string    `datastore:"-" json:"id"`
+	Link    string    `json:"link" datastore:",noindex"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Key get key for crawled item
+func (m *CrawledItem) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(CrawledItemKind)
+	}
+	return datastore.NameKey(CrawledItemKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *CrawledItem) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// GetCrawledItem returns item crawled with any of guid and link
+func GetCrawledItem(guid, link string) (*CrawledItem, error) {
+	err := datastore.ErrNoSuchEntity
+	for _, id := range []string{guid, link} {
+		if id == "" {
+			continue
+		}
+		entity := CrawledItem{ID: id}
+		if err = DS.GetByKey(&entity); err == nil {
+			return &entity, nil
+		}
+	}
+	return nil, err
+}
+
+// SetCrawled records page of feed item with guid and link was crawled
+func SetCrawled(guid, link string) {
+	now := time.Now()
+	for _, id := range []string{guid, link} {
+		if id == "" {
+			continue
+		}
+		item := CrawledItem{ID: id, Link: link, Created: now}
+		if old, err := GetCrawledItem(id, ""); err == nil {
+			item.Created = old.Created
+		}
+		DS.Save(&item)
+		if guid == link {
+			return
+		}
+	}
+}
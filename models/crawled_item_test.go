@@ -0,0 +1,31 @@
+package models
+
+import (
+	"testing"
+
+	"cloud.google.com/go/datastore"
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCrawledItem(t *testing.T) {
+	assert := assert.New(t)
+
+	guid, link := fake.Characters(), "https://"+fake.DomainName()+"/"+fake.Characters()
+	_, err := GetCrawledItem(guid, link)
+	assert.Equal(datastore.ErrNoSuchEntity, err)
+
+	SetCrawled(guid, link)
+	item, err := GetCrawledItem("", link)
+	assert.NoError(err)
+	assert.Equal(link, item.Link)
+	created := item.Created
+
+	// items are found by guid when their link changes
+	SetCrawled(guid, link+"?amp")
+	item, err = GetCrawledItem(guid, "")
+	assert.NoError(err)
+	assert.Equal(link+"?amp", item.Link)
+	assert.Equal(created, item.Created)
+	assert.True(item.Updated.After(created))
+}
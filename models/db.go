@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"github.com/epigos/newsbot/utils"
@@ -13,20 +14,47 @@ import (
 )
 
 var (
-	// DS database session
-	DS *DataStore
+	// DS storage backend used by all models
+	DS Store
 	// Kinds datastore kinds
 	Kinds = map[string]string{
-		"User":         "Users",
-		"Article":      "Articles",
-		"Audit":        "AuditRequest",
-		"Message":      "Messages",
-		"Topic":        "Topics",
-		"Subscription": "Subscriptions",
-		"SentItem":     "SentItem",
+		"User":            "Users",
+		"Article":         "Articles",
+		"Audit":           "AuditRequest",
+		"Message":         "Messages",
+		"Topic":           "Topics",
+		"Subscription":    "Subscriptions",
+		"SentItem":        "SentItem",
+		"SearchTerm":      "SearchTerms",
+		"SearchStats":     "SearchStats",
+		"Session":         "Sessions",
+		"OutboundMessage": "OutboundMessages",
+		"ProcessedEvent":  "ProcessedEvents",
 	}
+	logger = utils.NewLogger("models")
+	// ErrEntityExists returned when creating an entity with an existing key
+	ErrEntityExists = errors.New("models: entity already exists")
 )
 
+// Store an interface for storage backends of all entities
+type Store interface {
+	GetByKey(entity EntitySpec) error
+	GetAll(opts *Query, entities interface{}) ([]*datastore.Key, error)
+	Save(doc EntitySpec) *datastore.Key
+	// Create saves entity unless its key exists, ErrEntityExists is returned otherwise
+	Create(doc EntitySpec) error
+	Delete(key *datastore.Key) error
+	// DeleteMulti deletes multiple entities at once
+	DeleteMulti(keys []*datastore.Key) error
+	// Update loads entity, calls update and saves entity in a transaction,
+	// exists reports whether entity was found. update is called again when
+	// the transaction is retried and must not use the store, its errors
+	// abort the transaction
+	Update(entity EntitySpec, update func(exists bool) error) error
+	PutMulti(keys []*datastore.Key, entities interface{}) ([]*datastore.Key, error)
+	Close() error
+}
+
 // DataStore a struct for database collection
 type DataStore struct {
 	Client    *datastore.Client
@@ -48,6 +76,9 @@ type Query struct {
 	Limit   int
 	Offset  int
 	Order   []string
+	// Ancestor limits results to descendants of key, ancestor
+	// queries are strongly consistent
+	Ancestor *datastore.Key
 }
 
 // EntitySpec an interface for all entities
@@ -89,8 +120,24 @@ func NewBaseQuery(k string, fs []*Filter) *Query {
 	}
 }
 
-// Connect to database server
+// UseStore sets storage backend for all models
+func UseStore(s Store) {
+	DS = s
+}
+
+// Connect to database server, a sql database is used
+// when DB_DRIVER is set otherwise gcloud datastore
 func Connect() {
+	if driver := os.Getenv("DB_DRIVER"); driver != "" {
+		st, err := NewSQLStore(driver, os.Getenv("DB_SOURCE"))
+		if err != nil {
+			log.Fatalf("Failed to open %s database: %v", driver, err)
+		}
+		UseStore(st)
+		logger.Infof("Successfully connected to %s database", driver)
+		return
+	}
+
 	// Creates a client.
 	projectID := os.Getenv("PROJECT_ID")
 	ctx := context.Background()
@@ -100,26 +147,26 @@ func Connect() {
 		log.Fatalf("Failed to create client: %v", err)
 	}
 
-	DS = &DataStore{client, ctx, projectID, utils.NewLogger("models")}
+	UseStore(&DataStore{client, ctx, projectID, logger})
 
-	DS.Logger.Info("Successfully connected to datastore")
+	logger.Info("Successfully connected to datastore")
 }
 
-// NewKey creates a new datastore key
-func (d *DataStore) NewKey(kind string) *datastore.Key {
+// NewKey creates a new incomplete key
+func NewKey(kind string) *datastore.Key {
 	return datastore.IncompleteKey(kind, nil)
 }
 
-// Key builds a new datastore key based on provided id
-func (d *DataStore) Key(kind string, id string) *datastore.Key {
+// NameKey builds a new key based on provided id
+func NameKey(kind string, id string) *datastore.Key {
 	return datastore.NameKey(kind, id, nil)
 }
 
-// DecodeKey decode datastore key based on provided id
-func (d *DataStore) DecodeKey(id string) *datastore.Key {
+// DecodeKey decode key based on provided id
+func DecodeKey(id string) *datastore.Key {
 	key, err := datastore.DecodeKey(id)
 	if err != nil {
-		DS.Logger.Error(err)
+		logger.Error(err)
 	}
 	return key
 }
@@ -134,6 +181,9 @@ func (d *DataStore) GetByKey(entity EntitySpec) error {
 func (d *DataStore) GetAll(opts *Query, entities interface{}) ([]*datastore.Key, error) {
 
 	query := datastore.NewQuery(opts.Kind).Offset(opts.Offset)
+	if opts.Ancestor != nil {
+		query = query.Ancestor(opts.Ancestor)
+	}
 	if opts.Limit != 0 {
 		query = query.Limit(opts.Limit)
 	}
@@ -144,7 +194,7 @@ func (d *DataStore) GetAll(opts *Query, entities interface{}) ([]*datastore.Key,
 		query = query.Order(order)
 	}
 
-	DS.Logger.Debugf("%+v", query)
+	logger.Debugf("%+v", query)
 
 	keys, err := d.Client.GetAll(d.Context, query, entities)
 
@@ -155,17 +205,11 @@ func (d *DataStore) GetAll(opts *Query, entities interface{}) ([]*datastore.Key,
 func (d *DataStore) Save(doc EntitySpec) *datastore.Key {
 
 	key := doc.Key()
-	val := reflect.ValueOf(doc).Elem()
-	now := reflect.ValueOf(time.Now())
-
-	if key.Incomplete() {
-		val.FieldByName("Created").Set(now)
-	}
-	val.FieldByName("Updated").Set(now)
+	setTimestamps(doc, key.Incomplete())
 
 	key, err := d.Client.Put(d.Context, key, doc)
 	if err != nil {
-		DS.Logger.Panic(err)
+		logger.Panic(err)
 	}
 	// set id
 	doc.SetID(key)
@@ -173,12 +217,95 @@ func (d *DataStore) Save(doc EntitySpec) *datastore.Key {
 	return key
 }
 
+// Create saves entity in a transaction unless its key exists
+func (d *DataStore) Create(doc EntitySpec) error {
+	key := doc.Key()
+	setTimestamps(doc, true)
+
+	var pending *datastore.PendingKey
+	commit, err := d.Client.RunInTransaction(d.Context, func(tx *datastore.Transaction) error {
+		if !key.Incomplete() {
+			existing := reflect.New(reflect.TypeOf(doc).Elem()).Interface()
+			err := tx.Get(key, existing)
+			if err == nil {
+				return ErrEntityExists
+			}
+			if err != datastore.ErrNoSuchEntity {
+				return err
+			}
+		}
+		var err error
+		pending, err = tx.Put(key, doc)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if key.Incomplete() {
+		key = commit.Key(pending)
+	}
+	doc.SetID(key)
+	return nil
+}
+
+// Update loads and saves entity in a transaction
+func (d *DataStore) Update(entity EntitySpec, update func(exists bool) error) error {
+	key := entity.Key()
+	if key == nil || key.Incomplete() {
+		return datastore.ErrInvalidKey
+	}
+	// retried transactions start from the entity passed in
+	dst := reflect.ValueOf(entity).Elem()
+	orig := copyValue(dst)
+	_, err := d.Client.RunInTransaction(d.Context, func(tx *datastore.Transaction) error {
+		dst.Set(orig)
+		err := tx.Get(key, entity)
+		if err != nil && err != datastore.ErrNoSuchEntity {
+			return err
+		}
+		exists := err == nil
+		if err := update(exists); err != nil {
+			return err
+		}
+		setTimestamps(entity, !exists)
+		_, err = tx.Put(key, entity)
+		return err
+	})
+	return err
+}
+
 // Delete deletes an entity from its kind
 func (d *DataStore) Delete(key *datastore.Key) error {
 	return d.Client.Delete(d.Context, key)
 }
 
+// DeleteMulti deletes multiple entities at once
+func (d *DataStore) DeleteMulti(keys []*datastore.Key) error {
+	return d.Client.DeleteMulti(d.Context, keys)
+}
+
+// PutMulti saves multiple entities at once
+func (d *DataStore) PutMulti(keys []*datastore.Key, entities interface{}) ([]*datastore.Key, error) {
+	return d.Client.PutMulti(d.Context, keys, entities)
+}
+
 // Close closes a datastore client
+func (d *DataStore) Close() error {
+	return d.Client.Close()
+}
+
+// setTimestamps sets Created on new entities and Updated on every save
+func setTimestamps(doc EntitySpec, created bool) {
+	val := reflect.ValueOf(doc).Elem()
+	now := reflect.ValueOf(time.Now())
+
+	if created {
+		val.FieldByName("Created").Set(now)
+	}
+	val.FieldByName("Updated").Set(now)
+}
+
+// Close closes the storage backend
 func Close() error {
-	return DS.Client.Close()
+	return DS.Close()
 }
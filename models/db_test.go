@@ -13,7 +13,7 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	Connect()
+	UseStore(NewMemoryStore())
 	m.Run()
 	Close()
 }
@@ -0,0 +1,55 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// ProcessedEventKind kind name for processed webhook events
+const ProcessedEventKind = "ProcessedEvents"
+
+// ProcessedEvent webhook event or job that has been processed,
+// events are kept until they expire to ignore redeliveries
+type ProcessedEvent struct {
+	ID      string    `datastore:"-" json:"id"`
+	Expires time.Time `json:"expires"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Key get key for processed event
+func (m *ProcessedEvent) Key() *datastore.Key {
+	return datastore.NameKey(ProcessedEventKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *ProcessedEvent) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// ClaimEvent records event id as processed, it returns false
+// when the event was claimed before by any replica
+func ClaimEvent(id string, expires time.Time) (bool, error) {
+	err := DS.Create(&ProcessedEvent{ID: id, Expires: expires})
+	if err == ErrEntityExists {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// DeleteExpiredEvents deletes events expired at time t
+func DeleteExpiredEvents(t time.Time) (int, error) {
+	var events []*ProcessedEvent
+	query := NewBaseQuery(ProcessedEventKind, []*Filter{NewFilter("Expires <=", t)})
+	keys, err := DS.GetAll(query, &events)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := DS.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
@@ -0,0 +1,34 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimEvent(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+	id := "mid." + fake.Characters()
+
+	ok, err := ClaimEvent(id, now.Add(time.Hour))
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = ClaimEvent(id, now.Add(time.Hour))
+	assert.NoError(err)
+	assert.False(ok)
+
+	expired := "mid." + fake.Characters()
+	ClaimEvent(expired, now.Add(-time.Minute))
+	n, err := DeleteExpiredEvents(now)
+	assert.NoError(err)
+	assert.Equal(1, n)
+
+	// expired events can be claimed again after they're deleted
+	ok, _ = ClaimEvent(expired, now.Add(time.Hour))
+	assert.True(ok)
+	ok, _ = ClaimEvent(id, now.Add(time.Hour))
+	assert.False(ok)
+}
@@ -0,0 +1,490 @@
+package models
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// MemoryStore in-memory storage backend with the same query
+// semantics as datastore, mostly useful for tests and local runs
+type MemoryStore struct {
+	mu       sync.RWMutex
+	entities map[string]map[string]*memoryEntity
+	nextID   int64
+}
+
+type memoryEntity struct {
+	key   *datastore.Key
+	value reflect.Value
+}
+
+// NewMemoryStore returns new in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entities: map[string]map[string]*memoryEntity{},
+	}
+}
+
+func keyString(k *datastore.Key) string {
+	if k == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s/%s,%s,%d", keyString(k.Parent), k.Kind, k.Name, k.ID)
+}
+
+// copyValue returns a shallow copy of struct value v
+func copyValue(v reflect.Value) reflect.Value {
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	c := reflect.New(v.Type()).Elem()
+	c.Set(v)
+	return c
+}
+
+// ignoredFields returns indexes of fields of struct type t which
+// are not saved, like datastore they're tagged with datastore:"-"
+func ignoredFields(t reflect.Type) []int {
+	var fields []int
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("datastore") == "-" {
+			fields = append(fields, i)
+		}
+	}
+	return fields
+}
+
+// clearIgnored zeroes fields of struct value v which are not saved
+func clearIgnored(v reflect.Value) {
+	for _, i := range ignoredFields(v.Type()) {
+		f := v.Field(i)
+		f.Set(reflect.Zero(f.Type()))
+	}
+}
+
+// noindex checks if property name of struct type t is not indexed
+func noindex(t reflect.Type, name string) bool {
+	f, ok := t.FieldByName(name)
+	if !ok {
+		return false
+	}
+	for _, opt := range strings.Split(f.Tag.Get("datastore"), ",")[1:] {
+		if opt == "noindex" {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIndexes returns error when query filters or sorts by properties
+// of struct type t which are not indexed, datastore doesn't find them
+func checkIndexes(t reflect.Type, opts *Query) error {
+	for _, f := range opts.Filters {
+		if name, _ := f.parse(); noindex(t, name) {
+			return fmt.Errorf("models: filter on unindexed property %s of %s", name, opts.Kind)
+		}
+	}
+	for _, order := range opts.Order {
+		if name := strings.TrimPrefix(order, "-"); noindex(t, name) {
+			return fmt.Errorf("models: order by unindexed property %s of %s", name, opts.Kind)
+		}
+	}
+	return nil
+}
+
+// completeKey allocates an id for incomplete keys
+func (d *MemoryStore) completeKey(key *datastore.Key) *datastore.Key {
+	if key.Incomplete() {
+		d.nextID++
+		return datastore.IDKey(key.Kind, d.nextID, key.Parent)
+	}
+	return key
+}
+
+func (d *MemoryStore) put(key *datastore.Key, v reflect.Value) {
+	kind, ok := d.entities[key.Kind]
+	if !ok {
+		kind = map[string]*memoryEntity{}
+		d.entities[key.Kind] = kind
+	}
+	c := copyValue(v)
+	clearIgnored(c)
+	kind[keyString(key)] = &memoryEntity{key, c}
+}
+
+// get loads entity stored under key
+func (d *MemoryStore) get(key *datastore.Key, entity EntitySpec) error {
+	if key == nil || key.Incomplete() {
+		return datastore.ErrInvalidKey
+	}
+	e, ok := d.entities[key.Kind][keyString(key)]
+	if !ok {
+		return datastore.ErrNoSuchEntity
+	}
+	// fields which are not saved are kept like datastore does
+	dst := reflect.ValueOf(entity).Elem()
+	v := copyValue(e.value)
+	for _, i := range ignoredFields(v.Type()) {
+		v.Field(i).Set(dst.Field(i))
+	}
+	dst.Set(v)
+	return nil
+}
+
+// GetByKey retrive entity by key
+func (d *MemoryStore) GetByKey(entity EntitySpec) error {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	return d.get(entity.Key(), entity)
+}
+
+// GetAll retrieves all entities based on given query
+func (d *MemoryStore) GetAll(opts *Query, entities interface{}) ([]*datastore.Key, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	dst := reflect.ValueOf(entities)
+	if dst.Kind() != reflect.Ptr || dst.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("models: entities must be a pointer to a slice, got %T", entities)
+	}
+
+	elemType := dst.Elem().Type().Elem()
+	structType := elemType
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+	if err := checkIndexes(structType, opts); err != nil {
+		return nil, err
+	}
+
+	var found []*memoryEntity
+	for _, e := range d.entities[opts.Kind] {
+		if hasAncestor(e.key, opts.Ancestor) && matchFilters(e.value, opts.Filters) {
+			found = append(found, e)
+		}
+	}
+	sortEntities(found, opts.Order)
+
+	if opts.Offset >= len(found) {
+		found = nil
+	} else {
+		found = found[opts.Offset:]
+	}
+	if opts.Limit > 0 && len(found) > opts.Limit {
+		found = found[:opts.Limit]
+	}
+
+	slice := dst.Elem()
+	keys := make([]*datastore.Key, len(found))
+	for i, e := range found {
+		v := copyValue(e.value)
+		if elemType.Kind() == reflect.Ptr {
+			p := reflect.New(elemType.Elem())
+			p.Elem().Set(v)
+			v = p
+		}
+		slice = reflect.Append(slice, v)
+		keys[i] = e.key
+	}
+	dst.Elem().Set(slice)
+
+	return keys, nil
+}
+
+// Save saves entity and sets its id
+func (d *MemoryStore) Save(doc EntitySpec) *datastore.Key {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := doc.Key()
+	setTimestamps(doc, key.Incomplete())
+
+	key = d.completeKey(key)
+	doc.SetID(key)
+	d.put(key, reflect.ValueOf(doc))
+
+	return key
+}
+
+// Create saves entity unless its key exists
+func (d *MemoryStore) Create(doc EntitySpec) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := doc.Key()
+	if _, ok := d.entities[key.Kind][keyString(key)]; ok && !key.Incomplete() {
+		return ErrEntityExists
+	}
+	setTimestamps(doc, true)
+
+	key = d.completeKey(key)
+	doc.SetID(key)
+	d.put(key, reflect.ValueOf(doc))
+	return nil
+}
+
+// Update loads and saves entity while the store is locked
+func (d *MemoryStore) Update(entity EntitySpec, update func(exists bool) error) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	key := entity.Key()
+	err := d.get(key, entity)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		return err
+	}
+	exists := err == nil
+	if err := update(exists); err != nil {
+		return err
+	}
+	setTimestamps(entity, !exists)
+	d.put(key, reflect.ValueOf(entity))
+	return nil
+}
+
+// Delete deletes an entity from its kind
+func (d *MemoryStore) Delete(key *datastore.Key) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	delete(d.entities[key.Kind], keyString(key))
+	return nil
+}
+
+// DeleteMulti deletes multiple entities at once
+func (d *MemoryStore) DeleteMulti(keys []*datastore.Key) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, key := range keys {
+		delete(d.entities[key.Kind], keyString(key))
+	}
+	return nil
+}
+
+// PutMulti saves multiple entities at once
+func (d *MemoryStore) PutMulti(keys []*datastore.Key, entities interface{}) ([]*datastore.Key, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	src := reflect.ValueOf(entities)
+	if src.Kind() != reflect.Slice || src.Len() != len(keys) {
+		return nil, fmt.Errorf("models: keys and entities must be slices of equal length")
+	}
+	out := make([]*datastore.Key, len(keys))
+	for i, key := range keys {
+		out[i] = d.completeKey(key)
+		d.put(out[i], src.Index(i))
+	}
+	return out, nil
+}
+
+// Close clears the store
+func (d *MemoryStore) Close() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.entities = map[string]map[string]*memoryEntity{}
+	return nil
+}
+
+// hasAncestor checks if ancestor is key or one of its parents,
+// every key matches a nil ancestor
+func hasAncestor(key, ancestor *datastore.Key) bool {
+	if ancestor == nil {
+		return true
+	}
+	for k := key; k != nil; k = k.Parent {
+		if k.Equal(ancestor) {
+			return true
+		}
+	}
+	return false
+}
+
+// parse splits filter key into property name and operator
+func (f *Filter) parse() (string, string) {
+	parts := strings.Fields(f.key)
+	if len(parts) < 2 {
+		return f.key, "="
+	}
+	return parts[0], parts[1]
+}
+
+func matchFilters(v reflect.Value, fs []*Filter) bool {
+	for _, f := range fs {
+		if !matchFilter(v, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func matchFilter(v reflect.Value, f *Filter) bool {
+	name, op := f.parse()
+	field := v.FieldByName(name)
+	if !field.IsValid() {
+		return false
+	}
+	// multi-valued properties match if any of their values match
+	if field.Kind() == reflect.Slice && field.Type().Elem().Kind() != reflect.Uint8 {
+		for i := 0; i < field.Len(); i++ {
+			if matchValue(field.Index(i).Interface(), f.value, op) {
+				return true
+			}
+		}
+		return false
+	}
+	return matchValue(field.Interface(), f.value, op)
+}
+
+func matchValue(a, b interface{}, op string) bool {
+	c, ok := compareValues(normalize(a), normalize(b))
+	if !ok {
+		return false
+	}
+	switch op {
+	case "=":
+		return c == 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	}
+	return false
+}
+
+// normalize dereferences pointers and widens numbers so values
+// of different go types can be compared like datastore does
+func normalize(v interface{}) interface{} {
+	if k, ok := v.(*datastore.Key); ok {
+		if k == nil {
+			return nil
+		}
+		return k
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil
+	case reflect.Ptr:
+		if rv.IsNil() {
+			return nil
+		}
+		return normalize(rv.Elem().Interface())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint())
+	case reflect.Float32, reflect.Float64:
+		return rv.Float()
+	}
+	return v
+}
+
+// compareValues compares two normalized values, ok is false
+// when the values are not comparable
+func compareValues(a, b interface{}) (int, bool) {
+	if a == nil || b == nil {
+		switch {
+		case a == nil && b == nil:
+			return 0, true
+		case a == nil:
+			return -1, true
+		default:
+			return 1, true
+		}
+	}
+	switch x := a.(type) {
+	case *datastore.Key:
+		y, ok := b.(*datastore.Key)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(keyString(x), keyString(y)), true
+	case time.Time:
+		y, ok := b.(time.Time)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x.Before(y):
+			return -1, true
+		case x.After(y):
+			return 1, true
+		}
+		return 0, true
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return 0, false
+		}
+		return strings.Compare(x, y), true
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		}
+		return 0, true
+	case bool:
+		y, ok := b.(bool)
+		if !ok {
+			return 0, false
+		}
+		switch {
+		case x == y:
+			return 0, true
+		case !x:
+			return -1, true
+		}
+		return 1, true
+	}
+	return 0, false
+}
+
+// sortEntities sorts entities by key and then by order properties,
+// properties prefixed with "-" are sorted in descending order
+func sortEntities(es []*memoryEntity, orders []string) {
+	sort.Slice(es, func(i, j int) bool {
+		return keyString(es[i].key) < keyString(es[j].key)
+	})
+	sort.SliceStable(es, func(i, j int) bool {
+		for _, order := range orders {
+			name, desc := order, false
+			if strings.HasPrefix(order, "-") {
+				name, desc = order[1:], true
+			}
+			a := es[i].value.FieldByName(name)
+			b := es[j].value.FieldByName(name)
+			if !a.IsValid() || !b.IsValid() {
+				continue
+			}
+			c, ok := compareValues(normalize(a.Interface()), normalize(b.Interface()))
+			if !ok || c == 0 {
+				continue
+			}
+			if desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
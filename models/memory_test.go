@@ -0,0 +1,94 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryStore(t *testing.T) {
+	assert := assert.New(t)
+	st := NewMemoryStore()
+
+	now := time.Now()
+	topic := GetTopicKey("memory")
+	for i, title := range []string{"one", "two", "three", "four"} {
+		pub := now.Add(time.Duration(i) * time.Hour)
+		nw := NewArticle(title, "memory-"+title, "", "", "memory.com", "", &pub, []string{title, "news"})
+		nw.TopicKey = topic
+		st.Save(nw)
+	}
+
+	nw := Article{ID: "memory-two"}
+	assert.NoError(st.GetByKey(&nw))
+	assert.Equal("two", nw.Title)
+	assert.False(nw.Updated.IsZero())
+
+	var articles []*Article
+	q := NewQuery(ArticleKind, []*Filter{NewFilter("Tags =", "news")}, 2, 1, "-Published")
+	keys, err := st.GetAll(q, &articles)
+	assert.NoError(err)
+	assert.Len(keys, 2)
+	assert.Equal("four", articles[0].Title)
+	assert.Equal("three", articles[1].Title)
+
+	articles = nil
+	q = NewQuery(ArticleKind, []*Filter{NewFilter("Tags =", "news")}, 2, 2, "-Published")
+	_, err = st.GetAll(q, &articles)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal("two", articles[0].Title)
+
+	articles = nil
+	fs := []*Filter{
+		NewFilter("TopicKey =", topic),
+		NewFilter("Published >=", now.Add(time.Hour*2)),
+	}
+	_, err = st.GetAll(NewQuery(ArticleKind, fs, 0, 0, "Published"), &articles)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal("three", articles[0].Title)
+
+	articles = nil
+	fs = []*Filter{NewFilter("Tags =", "one"), NewFilter("Domain =", "memory.com")}
+	_, err = st.GetAll(NewBaseQuery(ArticleKind, fs), &articles)
+	assert.NoError(err)
+	assert.Len(articles, 1)
+
+	assert.NoError(st.Delete(nw.Key()))
+	assert.Error(st.GetByKey(&Article{ID: "memory-two"}))
+
+	msgs := []*Message{{Text: "a"}, {Text: "b"}}
+	keys, err = st.PutMulti([]*datastore.Key{NewKey(MessageKind), NewKey(MessageKind)}, msgs)
+	assert.NoError(err)
+	assert.False(keys[0].Incomplete())
+	assert.NotEqual(keys[0], keys[1])
+}
+
+func TestMemoryStoreIgnoredFields(t *testing.T) {
+	assert := assert.New(t)
+	st := NewMemoryStore()
+
+	// ids are not saved, they're set from keys like datastore
+	key := st.Save(&OutboundMessage{Recipient: "memory", Status: OutboundPending})
+	var messages []*OutboundMessage
+	keys, err := st.GetAll(NewBaseQuery(OutboundMessageKind, []*Filter{NewFilter("Recipient =", "memory")}), &messages)
+	assert.NoError(err)
+	if assert.Len(messages, 1) {
+		assert.Equal(key, keys[0])
+		assert.Empty(messages[0].ID)
+	}
+	msg := OutboundMessage{ID: key.Encode()}
+	assert.NoError(st.GetByKey(&msg))
+	assert.Equal(key.Encode(), msg.ID)
+	assert.Equal("memory", msg.Recipient)
+
+	// unindexed properties can't be queried
+	var terms []*SearchTerm
+	_, err = st.GetAll(NewBaseQuery(SearchTermKind, []*Filter{NewFilter("Freq >", 1)}), &terms)
+	assert.Error(err)
+	_, err = st.GetAll(NewQuery(SearchTermKind, nil, 10, 0, "-Freq"), &terms)
+	assert.Error(err)
+}
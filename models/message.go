@@ -9,6 +9,10 @@ import (
 //MessageKind kind name for messages
 const MessageKind = "Messages"
 
+// readBatch maximum number of messages marked read at once,
+// older messages are marked by earlier read receipts
+const readBatch = 20
+
 // Message recieved from facebook
 type Message struct {
 	ID           string         `datastore:"-" json:"id"`
@@ -18,6 +22,7 @@ type Message struct {
 	Response     string         `datastore:",noindex"  json:"response"`
 	Meta         string         `datastore:",noindex"  json:"meta"`
 	DeliveryTime *time.Time     `json:"delivery_time"`
+	ReadTime     *time.Time     `json:"read_time"`
 	Created      time.Time      `json:"created"`
 	Updated      time.Time      `json:"updated"`
 }
@@ -27,14 +32,14 @@ func (m *Message) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(MessageKind)
+		return NewKey(MessageKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
 	// on the one provided.
 	key, err := datastore.DecodeKey(m.ID)
 	if err != nil {
-		DS.Logger.Error("Key not found:", err)
+		logger.Error("Key not found:", err)
 	}
 	return key
 }
@@ -65,7 +70,7 @@ func GetMessage(id string) (*Message, error) {
 
 // Save messages
 func (m *Message) Save() {
-	DS.Logger.Info("Saving message:", m)
+	logger.Info("Saving message:", m)
 	DS.Save(m)
 }
 
@@ -89,6 +94,34 @@ func MarkMessageDelivered(mids []string) error {
 	for _, msg := range messages {
 		msg.DeliveryTime = &now
 	}
-	_, err = DS.Client.PutMulti(DS.Context, keys, messages)
+	_, err = DS.PutMulti(keys, messages)
+	return err
+}
+
+// MarkMessagesRead mark outgoing messages of user logged before
+// the read watermark as read
+func MarkMessagesRead(userID string, watermark time.Time) error {
+	fs := []*Filter{NewFilter("User =", GetUserKey(userID)), NewFilter("Created <=", watermark)}
+	query := NewQuery(MessageKind, fs, readBatch, 0, "-Created")
+	var messages []*Message
+
+	keys, err := DS.GetAll(query, &messages)
+	if err != nil {
+		return err
+	}
+
+	var unread []*Message
+	var unreadKeys []*datastore.Key
+	for i, msg := range messages {
+		if msg.ReadTime == nil {
+			msg.ReadTime = &watermark
+			unread = append(unread, msg)
+			unreadKeys = append(unreadKeys, keys[i])
+		}
+	}
+	if len(unread) == 0 {
+		return nil
+	}
+	_, err = DS.PutMulti(unreadKeys, unread)
 	return err
 }
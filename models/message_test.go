@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/epigos/newsbot/utils"
 	"testing"
+	"time"
 
 	"github.com/icrowley/fake"
 	"github.com/stretchr/testify/assert"
@@ -30,3 +31,32 @@ func TestMessage(t *testing.T) {
 	assert.NoError(err)
 	assert.NotNil(nm.DeliveryTime)
 }
+
+func TestMarkMessagesRead(t *testing.T) {
+	assert := assert.New(t)
+	rid := fake.CharactersN(10)
+
+	om := NewMessage(rid, fake.Sentence(), "[]", "{}", nil)
+	om.Save()
+	watermark := time.Now()
+	later := NewMessage(rid, fake.Sentence(), "[]", "{}", nil)
+	later.Save()
+	later.Created = watermark.Add(time.Minute)
+	later.Save()
+
+	assert.NoError(MarkMessagesRead(rid, watermark))
+
+	nm, err := GetMessage(om.ID)
+	assert.NoError(err)
+	if assert.NotNil(nm.ReadTime) {
+		assert.True(nm.ReadTime.Equal(watermark))
+	}
+	nm, err = GetMessage(later.ID)
+	assert.NoError(err)
+	assert.Nil(nm.ReadTime)
+
+	// read messages keep their read time
+	assert.NoError(MarkMessagesRead(rid, watermark.Add(time.Hour)))
+	nm, _ = GetMessage(om.ID)
+	assert.True(nm.ReadTime.Equal(watermark))
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// OutboundMessageKind kind name for queued outgoing messages
+const OutboundMessageKind = "OutboundMessages"
+
+// Outbound message statuses
+const (
+	OutboundPending = "pending"
+	OutboundDead    = "dead"
+)
+
+// errNotClaimable aborts claims of messages which may not be sent
+var errNotClaimable = errors.New("models: outbound message can't be claimed")
+
+// OutboundMessage outgoing message waiting to be sent to a user, messages
+// of a user are children of the user key and sent in Seq order. Owner
+// is the sender leasing the message until Lease
+type OutboundMessage struct {
+	ID          string         `datastore:"-" json:"id"`
+	Recipient   string         `json:"recipient"`
+	Payload     string         `datastore:",noindex" json:"payload"`
+	Message     *datastore.Key `json:"message_id"`
+	Seq         int64          `json:"seq"`
+	Status      string         `json:"status"`
+	Attempts    int            `json:"attempts"`
+	NextAttempt time.Time      `json:"next_attempt"`
+	LastError   string         `datastore:",noindex" json:"last_error"`
+	Owner       string         `datastore:",noindex" json:"owner"`
+	Lease       time.Time      `datastore:",noindex" json:"lease"`
+	Created     time.Time      `json:"created"`
+	Updated     time.Time      `json:"updated"`
+}
+
+// Key get key for outbound message
+func (m *OutboundMessage) Key() *datastore.Key {
+	if m.ID == "" {
+		return datastore.IncompleteKey(OutboundMessageKind, GetUserKey(m.Recipient))
+	}
+	return DecodeKey(m.ID)
+}
+
+// SetID set id
+func (m *OutboundMessage) SetID(key *datastore.Key) {
+	m.ID = key.Encode()
+}
+
+// NewOutboundMessage creates a pending message of recipient,
+// msg is the logged message its message id is recorded on
+func NewOutboundMessage(recipient, payload string, msg *datastore.Key, seq int64) *OutboundMessage {
+	return &OutboundMessage{
+		Recipient: recipient,
+		Payload:   payload,
+		Message:   msg,
+		Seq:       seq,
+		Status:    OutboundPending,
+	}
+}
+
+// Save outbound message
+func (m *OutboundMessage) Save() {
+	DS.Save(m)
+}
+
+// Delete outbound message
+func (m *OutboundMessage) Delete() error {
+	return DS.Delete(m.Key())
+}
+
+// ClaimOutboundMessage leases pending message m to owner for lease from
+// now on, the message is reloaded. It returns false when the message
+// was sent, is dead or is leased by another owner
+func ClaimOutboundMessage(m *OutboundMessage, owner string, now time.Time, lease time.Duration) (bool, error) {
+	err := DS.Update(m, func(exists bool) error {
+		if !exists || m.Status != OutboundPending || (m.Owner != owner && m.Lease.After(now)) {
+			return errNotClaimable
+		}
+		m.Owner, m.Lease = owner, now.Add(lease)
+		return nil
+	})
+	if err == errNotClaimable {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// GetPendingMessages get pending messages of recipient in the order they
+// must be sent, all recipients when recipient is empty. Messages of a
+// recipient are read with an ancestor query so recent changes are seen
+func GetPendingMessages(recipient string, limit int) ([]*OutboundMessage, error) {
+	fs := []*Filter{NewFilter("Status =", OutboundPending)}
+	var messages []*OutboundMessage
+	query := NewQuery(OutboundMessageKind, fs, limit, 0, "Seq")
+	if recipient != "" {
+		query.Ancestor = GetUserKey(recipient)
+	}
+	keys, err := DS.GetAll(query, &messages)
+	for i, key := range keys {
+		messages[i].SetID(key)
+	}
+	return messages, err
+}
+
+// GetDeadMessages get messages that could not be sent
+func GetDeadMessages(limit, page int) ([]*OutboundMessage, error) {
+	var messages []*OutboundMessage
+	query := NewQuery(OutboundMessageKind, []*Filter{NewFilter("Status =", OutboundDead)}, limit, page, "-Updated")
+	keys, err := DS.GetAll(query, &messages)
+	for i, key := range keys {
+		messages[i].SetID(key)
+	}
+	return messages, err
+}
+
+// AddMessageID records id of a sent part of message
+func AddMessageID(key *datastore.Key, mid string) error {
+	msg := Message{ID: key.Encode()}
+	if err := DS.GetByKey(&msg); err != nil {
+		return err
+	}
+	msg.MID = append(msg.MID, mid)
+	DS.Save(&msg)
+	return nil
+}
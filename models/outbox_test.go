@@ -0,0 +1,122 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutboundMessages(t *testing.T) {
+	assert := assert.New(t)
+	rid := fake.Characters()
+
+	msg := NewMessage(rid, fake.Sentence(), "[]", "{}", nil)
+	msg.Save()
+
+	second := NewOutboundMessage(rid, `{"text":"2"}`, msg.Key(), 2)
+	second.Save()
+	first := NewOutboundMessage(rid, `{"text":"1"}`, msg.Key(), 1)
+	first.Save()
+	NewOutboundMessage(fake.Characters(), `{"text":"3"}`, nil, 3).Save()
+
+	pending, err := GetPendingMessages(rid, 0)
+	assert.NoError(err)
+	if assert.Len(pending, 2) {
+		assert.Equal(first.ID, pending[0].ID)
+		assert.Equal(second.ID, pending[1].ID)
+	}
+	pending, err = GetPendingMessages("", 0)
+	assert.NoError(err)
+	assert.Len(pending, 3)
+
+	first.Status = OutboundDead
+	first.Save()
+	pending, _ = GetPendingMessages(rid, 0)
+	assert.Len(pending, 1)
+	dead, err := GetDeadMessages(10, 1)
+	assert.NoError(err)
+	if assert.Len(dead, 1) {
+		assert.Equal(first.ID, dead[0].ID)
+	}
+
+	assert.NoError(AddMessageID(msg.Key(), "mid.1"))
+	assert.NoError(AddMessageID(msg.Key(), "mid.2"))
+	m, err := GetMessage(msg.ID)
+	assert.NoError(err)
+	assert.Equal([]string{"mid.1", "mid.2"}, m.MID)
+
+	assert.NoError(second.Delete())
+	pending, _ = GetPendingMessages(rid, 0)
+	assert.Empty(pending)
+}
+
+func TestClaimOutboundMessage(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	m := NewOutboundMessage(fake.Characters(), `{"text":"1"}`, nil, 1)
+	m.Save()
+
+	ok, err := ClaimOutboundMessage(m, "a", now, time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("a", m.Owner)
+
+	// leased messages are claimed only by their owner
+	other := &OutboundMessage{ID: m.ID}
+	ok, err = ClaimOutboundMessage(other, "b", now, time.Minute)
+	assert.NoError(err)
+	assert.False(ok)
+	ok, _ = ClaimOutboundMessage(m, "a", now, time.Minute)
+	assert.True(ok)
+
+	// expired leases are claimed by others
+	ok, err = ClaimOutboundMessage(other, "b", now.Add(2*time.Minute), time.Minute)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.Equal("b", other.Owner)
+
+	assert.NoError(other.Delete())
+	ok, err = ClaimOutboundMessage(m, "a", now.Add(time.Hour), time.Minute)
+	assert.NoError(err)
+	assert.False(ok)
+}
+
+func TestOutboundMessagesSQL(t *testing.T) {
+	assert := assert.New(t)
+
+	// the sql store doesn't persist ids, they're set from keys
+	st, err := NewSQLStore("sqlite3", ":memory:")
+	assert.NoError(err)
+	defer st.Close()
+	defer UseStore(DS)
+	UseStore(st)
+
+	rid := fake.Characters()
+	NewOutboundMessage(rid, `{"text":"1"}`, nil, 1).Save()
+	dead := NewOutboundMessage(rid, `{"text":"2"}`, nil, 2)
+	dead.Status = OutboundDead
+	dead.Save()
+
+	pending, err := GetPendingMessages(rid, 0)
+	assert.NoError(err)
+	if assert.Len(pending, 1) {
+		assert.NotEmpty(pending[0].ID)
+		ok, err := ClaimOutboundMessage(pending[0], "a", time.Now(), time.Minute)
+		assert.NoError(err)
+		assert.True(ok)
+		ok, _ = ClaimOutboundMessage(&OutboundMessage{ID: pending[0].ID}, "b", time.Now(), time.Minute)
+		assert.False(ok)
+		assert.NoError(pending[0].Delete())
+	}
+	pending, _ = GetPendingMessages(rid, 0)
+	assert.Empty(pending)
+
+	msgs, err := GetDeadMessages(10, 1)
+	assert.NoError(err)
+	if assert.Len(msgs, 1) {
+		assert.Equal(dead.ID, msgs[0].ID)
+	}
+}
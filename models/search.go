@@ -0,0 +1,408 @@
+package models
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	// SearchTermKind kind name for inverted index postings
+	SearchTermKind = "SearchTerms"
+	// SearchStatsKind kind name for index statistics
+	SearchStatsKind = "SearchStats"
+
+	searchStatsID = "articles"
+	// bm25 parameters
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// prefixWeight weight of terms matched by prefix only
+	prefixWeight = 0.5
+	// minPrefixLen shortest query term expanded by prefix
+	minPrefixLen = 3
+	// maxCandidates maximum articles ranked per query
+	maxCandidates = 100
+	// reindexBatch number of articles read at once by ReindexArticles
+	reindexBatch = 100
+	// writeBatch maximum number of entities written at once by datastore
+	writeBatch = 500
+	// weights of indexed article fields
+	titleWeight       = 3
+	descriptionWeight = 2
+	textWeight        = 1
+)
+
+// indexMu serializes index and statistics updates
+var indexMu sync.Mutex
+
+// SearchTerm a posting of the article search index
+type SearchTerm struct {
+	ID      string         `datastore:"-" json:"id"`
+	Term    string         `json:"term"`
+	Article *datastore.Key `json:"article"`
+	Freq    int            `json:"freq" datastore:",noindex"`
+	Length  int            `json:"length" datastore:",noindex"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+}
+
+// SearchStats statistics of the article search index
+type SearchStats struct {
+	ID          string    `datastore:"-" json:"id"`
+	DocCount    int       `json:"doc_count" datastore:",noindex"`
+	TotalLength int       `json:"total_length" datastore:",noindex"`
+	Created     time.Time `json:"created"`
+	Updated     time.Time `json:"updated"`
+}
+
+// Key get key for search term
+func (m *SearchTerm) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(SearchTermKind)
+	}
+	return datastore.NameKey(SearchTermKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *SearchTerm) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// Key get key for search stats
+func (m *SearchStats) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(SearchStatsKind)
+	}
+	return datastore.NameKey(SearchStatsKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *SearchStats) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+func getSearchStats() *SearchStats {
+	stats := SearchStats{ID: searchStatsID}
+	DS.GetByKey(&stats)
+	return &stats
+}
+
+// updateSearchStats adds docs and length to the index statistics in a transaction
+func updateSearchStats(docs, length int) error {
+	stats := &SearchStats{ID: searchStatsID}
+	return DS.Update(stats, func(exists bool) error {
+		stats.DocCount += docs
+		stats.TotalLength += length
+		return nil
+	})
+}
+
+// getArticleTerms returns index postings of article
+func getArticleTerms(key *datastore.Key) ([]*SearchTerm, []*datastore.Key, error) {
+	var terms []*SearchTerm
+	query := NewBaseQuery(SearchTermKind, []*Filter{NewFilter("Article =", key)})
+	keys, err := DS.GetAll(query, &terms)
+	return terms, keys, err
+}
+
+// deleteArticleTerms deletes index postings of article, it returns
+// the indexed length of article and whether it was indexed
+func deleteArticleTerms(key *datastore.Key) (int, bool, error) {
+	old, oldKeys, err := getArticleTerms(key)
+	if err != nil {
+		return 0, false, err
+	}
+	for i := 0; i < len(oldKeys); i += writeBatch {
+		end := i + writeBatch
+		if end > len(oldKeys) {
+			end = len(oldKeys)
+		}
+		if err := DS.DeleteMulti(oldKeys[i:end]); err != nil {
+			return 0, false, err
+		}
+	}
+	if len(old) == 0 {
+		return 0, false, nil
+	}
+	return old[0].Length, true, nil
+}
+
+// Index adds article to the search index, body is the article text
+func (m *Article) Index(body string) error {
+	freqs := map[string]int{}
+	fields := []struct {
+		text   string
+		weight int
+	}{
+		{m.Title, titleWeight},
+		{m.Description, descriptionWeight},
+		{strings.Join(m.Summary, " "), textWeight},
+		{strings.Join(m.Tags, " "), textWeight},
+		{body, textWeight},
+	}
+	length := 0
+	for _, f := range fields {
+		for _, term := range utils.Tokenize(f.text) {
+			freqs[term] += f.weight
+			length += f.weight
+		}
+	}
+
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	key := m.Key()
+	oldLength, indexed, err := deleteArticleTerms(key)
+	if err != nil {
+		return err
+	}
+
+	keys := make([]*datastore.Key, 0, len(freqs))
+	terms := make([]*SearchTerm, 0, len(freqs))
+	now := time.Now()
+	for term, freq := range freqs {
+		st := &SearchTerm{
+			ID:      m.ID + "|" + term,
+			Term:    term,
+			Article: key,
+			Freq:    freq,
+			Length:  length,
+			Created: now,
+			Updated: now,
+		}
+		keys = append(keys, st.Key())
+		terms = append(terms, st)
+	}
+	for i := 0; i < len(keys); i += writeBatch {
+		end := i + writeBatch
+		if end > len(keys) {
+			end = len(keys)
+		}
+		if _, err := DS.PutMulti(keys[i:end], terms[i:end]); err != nil {
+			return err
+		}
+	}
+
+	docs := 1
+	if indexed {
+		docs = 0
+	}
+	if err := updateSearchStats(docs, length-oldLength); err != nil {
+		return err
+	}
+
+	logger.Debugf("Indexed article %s with %d terms", m.ID, len(terms))
+	return nil
+}
+
+// unindex removes article from the search index
+func (m *Article) unindex() error {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	length, indexed, err := deleteArticleTerms(m.Key())
+	if err != nil || !indexed {
+		return err
+	}
+	return updateSearchStats(-1, -length)
+}
+
+// ReindexArticles adds saved articles which are not indexed yet to the
+// search index, the text of articles isn't stored so their title,
+// description, summary and tags are indexed. It returns the number
+// of articles indexed
+func ReindexArticles() (int, error) {
+	n := 0
+	for page := 1; ; page++ {
+		var articles []*Article
+		query := NewQuery(ArticleKind, nil, reindexBatch, page, "Created")
+		keys, err := DS.GetAll(query, &articles)
+		if err != nil {
+			return n, err
+		}
+		for i, key := range keys {
+			articles[i].SetID(key)
+			terms, _, err := getArticleTerms(key)
+			if err != nil {
+				return n, err
+			}
+			if len(terms) > 0 {
+				continue
+			}
+			if err := articles[i].Index(""); err != nil {
+				return n, err
+			}
+			n++
+		}
+		if len(keys) < reindexBatch {
+			return n, nil
+		}
+	}
+}
+
+// matchTerm returns all postings of index terms matching query
+// term, they are all read to get document frequencies right
+func matchTerm(term string) ([]*SearchTerm, error) {
+	var fs []*Filter
+	if len(term) < minPrefixLen {
+		fs = []*Filter{NewFilter("Term =", term)}
+	} else {
+		fs = []*Filter{NewFilter("Term >=", term), NewFilter("Term <", term+"\uffff")}
+	}
+	var terms []*SearchTerm
+	query := &Query{Kind: SearchTermKind, Filters: fs}
+	_, err := DS.GetAll(query, &terms)
+	return terms, err
+}
+
+type searchHit struct {
+	key   *datastore.Key
+	score float64
+}
+
+// rankTerms scores indexed articles matching query terms with BM25
+func rankTerms(terms []string) ([]*searchHit, error) {
+	stats := getSearchStats()
+	if stats.DocCount < 1 {
+		return nil, nil
+	}
+	n := float64(stats.DocCount)
+	avgLen := float64(stats.TotalLength) / n
+
+	hits := map[string]*searchHit{}
+	for _, term := range utils.SliceUniqMap(terms) {
+		postings, err := matchTerm(term)
+		if err != nil {
+			return nil, err
+		}
+		// effective term frequency and length per article
+		freqs := map[string]float64{}
+		lengths := map[string]float64{}
+		keys := map[string]*datastore.Key{}
+		for _, p := range postings {
+			id := p.Article.Name
+			weight := 1.0
+			if p.Term != term {
+				weight = prefixWeight
+			}
+			freqs[id] += float64(p.Freq) * weight
+			lengths[id] = float64(p.Length)
+			keys[id] = p.Article
+		}
+
+		df := float64(len(freqs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range freqs {
+			norm := tf + bm25K1*(1-bm25B+bm25B*lengths[id]/avgLen)
+			hit, ok := hits[id]
+			if !ok {
+				hit = &searchHit{key: keys[id]}
+				hits[id] = hit
+			}
+			hit.score += idf * tf * (bm25K1 + 1) / norm
+		}
+	}
+
+	out := make([]*searchHit, 0, len(hits))
+	for _, hit := range hits {
+		out = append(out, hit)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].score > out[j].score
+	})
+	return out, nil
+}
+
+// parseDateParam parses date-time search param from dialogflow,
+// periods such as 2018-06-01/2018-06-07 use their start date
+func parseDateParam(dt interface{}) (time.Time, bool) {
+	switch v := dt.(type) {
+	case time.Time:
+		return v, true
+	case *time.Time:
+		return *v, v != nil
+	case string:
+		s := strings.Split(v, "/")[0]
+		for _, layout := range []string{time.RFC3339, "2006-01-02"} {
+			if t, err := time.Parse(layout, s); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// matchParams checks if article matches category, source and date-time params
+func (m *Article) matchParams(params utils.Map) bool {
+	if c := params.Get("category", ""); c != "" {
+		if m.TopicKey == nil || !m.TopicKey.Equal(GetTopicKey(c.(string))) {
+			return false
+		}
+	}
+	if src := params.Get("source", ""); src != "" && m.Domain != src {
+		return false
+	}
+	if dt := params.Get("date-time", ""); dt != "" {
+		if t, ok := parseDateParam(dt); ok && (m.Published == nil || m.Published.Before(t)) {
+			return false
+		}
+	}
+	return true
+}
+
+// searchArticleText full-text search of articles ranked by BM25 and article score
+func searchArticleText(kwd string, params utils.Map, page int) ([]*Article, error) {
+	hits, err := rankTerms(utils.Tokenize(kwd))
+	if err != nil {
+		return nil, err
+	}
+	if len(hits) > maxCandidates {
+		hits = hits[:maxCandidates]
+	}
+
+	type ranked struct {
+		article *Article
+		rank    float64
+	}
+	var results []*ranked
+	for _, hit := range hits {
+		article, err := GetArticle(hit.key.Name)
+		if err != nil || !article.matchParams(params) {
+			continue
+		}
+		results = append(results, &ranked{article, hit.score * (1 + article.Score)})
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].rank > results[j].rank
+	})
+	// the best ranked article of a story is its result
+	stories := map[string]bool{}
+	n := 0
+	for _, r := range results {
+		if key := r.article.StoryKey; key != nil {
+			if stories[key.Name] {
+				continue
+			}
+			stories[key.Name] = true
+		}
+		results[n] = r
+		n++
+	}
+	results = results[:n]
+
+	offset := pageSize * (page - 1)
+	if offset < 0 {
+		offset = 0
+	}
+	articles := []*Article{}
+	for i := offset; i < len(results) && i < offset+pageSize; i++ {
+		articles = append(articles, results[i].article)
+	}
+	return articles, nil
+}
@@ -0,0 +1,183 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	"github.com/epigos/newsbot/utils"
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newIndexedArticle(title, desc, body string, score float64) *Article {
+	pub := time.Now()
+	link := fake.DomainName() + "/" + fake.Characters()
+	article := NewArticle(title, link, desc, link, link, link, &pub, []string{})
+	article.Score = score
+	article.Save()
+	article.Index(body)
+	return article
+}
+
+func uniqueTerm() string {
+	return fmt.Sprintf("qz%d", time.Now().UnixNano())
+}
+
+func TestSearchArticleRanking(t *testing.T) {
+	assert := assert.New(t)
+
+	term := uniqueTerm()
+	inBody := newIndexedArticle(fake.SentencesN(1), fake.SentencesN(1), term, 0)
+	inTitle := newIndexedArticle(term, fake.SentencesN(1), fake.SentencesN(2), 0)
+
+	articles, err := SearchArticle(utils.Map{"keyword": term}, 1)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal(inTitle.ID, articles[0].ID)
+	assert.Equal(inBody.ID, articles[1].ID)
+}
+
+func TestSearchArticleScore(t *testing.T) {
+	assert := assert.New(t)
+
+	term := uniqueTerm()
+	low := newIndexedArticle(term, "", "", 0.1)
+	high := newIndexedArticle(term, "", "", 0.9)
+
+	articles, err := SearchArticle(utils.Map{"keyword": term}, 1)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal(high.ID, articles[0].ID)
+	assert.Equal(low.ID, articles[1].ID)
+}
+
+func TestSearchArticleStemAndPrefix(t *testing.T) {
+	assert := assert.New(t)
+
+	term := uniqueTerm()
+	plural := newIndexedArticle(term+"s", "", "", 0)
+
+	articles, err := SearchArticle(utils.Map{"keyword": term}, 1)
+	assert.NoError(err)
+	assert.Len(articles, 1)
+	assert.Equal(plural.ID, articles[0].ID)
+
+	articles, err = SearchArticle(utils.Map{"keyword": term[:len(term)-3]}, 1)
+	assert.NoError(err)
+	assert.NotEmpty(articles)
+
+	articles, err = SearchArticle(utils.Map{"keyword": uniqueTerm()}, 1)
+	assert.NoError(err)
+	assert.Empty(articles)
+}
+
+func TestIndexReplacesTerms(t *testing.T) {
+	assert := assert.New(t)
+
+	term, other := uniqueTerm(), uniqueTerm()
+	article := newIndexedArticle(term, "", "", 0)
+	stats := getSearchStats()
+
+	article.Title = other
+	assert.NoError(article.Index(""))
+	assert.Equal(stats.DocCount, getSearchStats().DocCount)
+
+	articles, err := SearchArticle(utils.Map{"keyword": term}, 1)
+	assert.NoError(err)
+	assert.Empty(articles)
+
+	articles, err = SearchArticle(utils.Map{"keyword": other}, 1)
+	assert.NoError(err)
+	assert.Len(articles, 1)
+}
+
+func TestArticleDeleteUnindexes(t *testing.T) {
+	assert := assert.New(t)
+
+	term := uniqueTerm()
+	stats := getSearchStats()
+	article := newIndexedArticle(term, "", "", 0)
+	indexed := getSearchStats()
+	assert.Equal(stats.DocCount+1, indexed.DocCount)
+
+	assert.NoError(article.Delete())
+	terms, _, err := getArticleTerms(article.Key())
+	assert.NoError(err)
+	assert.Empty(terms)
+	assert.Equal(stats.DocCount, getSearchStats().DocCount)
+	assert.Equal(stats.TotalLength, getSearchStats().TotalLength)
+}
+
+// batchStore records the largest batch written to store
+type batchStore struct {
+	Store
+	max int
+}
+
+func (d *batchStore) PutMulti(keys []*datastore.Key, entities interface{}) ([]*datastore.Key, error) {
+	if len(keys) > d.max {
+		d.max = len(keys)
+	}
+	return d.Store.PutMulti(keys, entities)
+}
+
+func (d *batchStore) DeleteMulti(keys []*datastore.Key) error {
+	if len(keys) > d.max {
+		d.max = len(keys)
+	}
+	return d.Store.DeleteMulti(keys)
+}
+
+func TestIndexBatches(t *testing.T) {
+	assert := assert.New(t)
+
+	st := &batchStore{Store: DS}
+	defer UseStore(DS)
+	UseStore(st)
+
+	var words []string
+	for i := 0; i < 2*writeBatch+10; i++ {
+		words = append(words, fmt.Sprintf("qz%dx", i))
+	}
+	stats := getSearchStats()
+	article := newIndexedArticle(uniqueTerm(), "", strings.Join(words, " "), 0)
+	terms, _, err := getArticleTerms(article.Key())
+	assert.NoError(err)
+	assert.Len(terms, len(words)+1)
+	assert.Equal(stats.DocCount+1, getSearchStats().DocCount)
+
+	assert.NoError(article.Delete())
+	terms, _, _ = getArticleTerms(article.Key())
+	assert.Empty(terms)
+	assert.Equal(writeBatch, st.max)
+	assert.Equal(stats.TotalLength, getSearchStats().TotalLength)
+}
+
+func TestReindexArticles(t *testing.T) {
+	assert := assert.New(t)
+
+	term := uniqueTerm()
+	pub := time.Now()
+	link := fake.DomainName() + "/" + fake.Characters()
+	article := NewArticle(term, link, "", link, link, link, &pub, []string{})
+	article.Save()
+	body := uniqueTerm()
+	newIndexedArticle(uniqueTerm(), "", body, 0)
+
+	n, err := ReindexArticles()
+	assert.NoError(err)
+	assert.True(n >= 1)
+	articles, err := SearchArticle(utils.Map{"keyword": term}, 1)
+	assert.NoError(err)
+	if assert.Len(articles, 1) {
+		assert.Equal(article.ID, articles[0].ID)
+	}
+
+	// indexed articles keep the terms of their text
+	articles, err = SearchArticle(utils.Map{"keyword": body}, 1)
+	assert.NoError(err)
+	assert.Len(articles, 1)
+}
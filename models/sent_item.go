@@ -0,0 +1,77 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// SentItemKind kind name for items pushed to users
+const SentItemKind = "SentItem"
+
+// SentItem records an item that has been pushed to a user
+type SentItem struct {
+	ID      string         `datastore:"-" json:"id"`
+	User    *datastore.Key `json:"user_id"`
+	Item    *datastore.Key `json:"item_id"`
+	Created time.Time      `json:"created"`
+	Updated time.Time      `json:"updated"`
+}
+
+// Key get key for sent item
+func (m *SentItem) Key() *datastore.Key {
+	// if there is no Id, we want to generate an "incomplete"
+	// one and let datastore determine the key/Id for us
+	if m.ID == "" {
+		return NewKey(SentItemKind)
+	}
+
+	// if Id is already set, we'll just build the Key based
+	// on the one provided.
+	return datastore.NameKey(SentItemKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *SentItem) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// NewSentItem returns new sent item for user
+func NewSentItem(userID string, itemKey *datastore.Key) *SentItem {
+	return &SentItem{
+		ID:   sentItemID(userID, itemKey),
+		User: GetUserKey(userID),
+		Item: itemKey,
+	}
+}
+
+func sentItemID(userID string, itemKey *datastore.Key) string {
+	return userID + ":" + itemKey.Name
+}
+
+// Save sent item
+func (m *SentItem) Save() {
+	DS.Save(m)
+}
+
+// Delete deletes sent item
+func (m *SentItem) Delete() error {
+	return DS.Delete(m.Key())
+}
+
+// ClaimSentItem records item as pushed to user unless it was
+// recorded before, it returns false when the item was sent
+func ClaimSentItem(userID string, itemKey *datastore.Key) (bool, error) {
+	err := DS.Create(NewSentItem(userID, itemKey))
+	if err == ErrEntityExists {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// IsItemSent checks if item has already been pushed to user
+func IsItemSent(userID string, itemKey *datastore.Key) bool {
+	entity := SentItem{ID: sentItemID(userID, itemKey)}
+	err := DS.GetByKey(&entity)
+	return err == nil
+}
@@ -0,0 +1,40 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSentItem(t *testing.T) {
+	assert := assert.New(t)
+
+	user := NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	user.Save()
+
+	pub := time.Now()
+	link := fake.DomainName()
+	article := NewArticle(fake.SentencesN(1), link, fake.SentencesN(2), link, link, link, &pub, []string{})
+
+	assert.False(IsItemSent(user.ID, article.Key()))
+
+	item := NewSentItem(user.ID, article.Key())
+	item.Save()
+	assert.Equal(item.User, user.Key())
+	assert.Equal(item.Item, article.Key())
+
+	assert.True(IsItemSent(user.ID, article.Key()))
+
+	// items are claimed once
+	other := GetArticleKey(fake.Characters())
+	ok, err := ClaimSentItem(user.ID, other)
+	assert.NoError(err)
+	assert.True(ok)
+	ok, err = ClaimSentItem(user.ID, other)
+	assert.NoError(err)
+	assert.False(ok)
+	assert.NoError(NewSentItem(user.ID, other).Delete())
+	assert.False(IsItemSent(user.ID, other))
+}
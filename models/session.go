@@ -0,0 +1,75 @@
+package models
+
+import (
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// SessionKind kind name for chatbot sessions
+const SessionKind = "Sessions"
+
+// Session conversation state of a user
+type Session struct {
+	ID      string    `datastore:"-" json:"id"`
+	Data    string    `datastore:",noindex" json:"data"`
+	Expires time.Time `json:"expires"`
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Key get key for session
+func (m *Session) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(SessionKind)
+	}
+	return datastore.NameKey(SessionKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *Session) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// NewSession returns new session of user
+func NewSession(userID, data string, expires time.Time) *Session {
+	return &Session{ID: userID, Data: data, Expires: expires}
+}
+
+// IsExpired checks if session has expired at time t
+func (m *Session) IsExpired(t time.Time) bool {
+	return !m.Expires.After(t)
+}
+
+// Save session
+func (m *Session) Save() {
+	DS.Save(m)
+}
+
+// Delete session
+func (m *Session) Delete() error {
+	return DS.Delete(m.Key())
+}
+
+// GetSession get session of user
+func GetSession(userID string) (*Session, error) {
+	entity := Session{ID: userID}
+	err := DS.GetByKey(&entity)
+	return &entity, err
+}
+
+// DeleteExpiredSessions deletes sessions expired at time t
+func DeleteExpiredSessions(t time.Time) (int, error) {
+	var sessions []*Session
+	query := NewBaseQuery(SessionKind, []*Filter{NewFilter("Expires <=", t)})
+	keys, err := DS.GetAll(query, &sessions)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := DS.Delete(key); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
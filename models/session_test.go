@@ -0,0 +1,35 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSession(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Now()
+
+	sess := NewSession(fake.Characters(), `{"page":1}`, now.Add(time.Hour))
+	sess.Save()
+	assert.False(sess.IsExpired(now))
+	assert.True(sess.IsExpired(now.Add(time.Hour)))
+
+	s, err := GetSession(sess.ID)
+	assert.NoError(err)
+	assert.Equal(sess.Data, s.Data)
+
+	expired := NewSession(fake.Characters(), "{}", now.Add(-time.Minute))
+	expired.Save()
+	n, err := DeleteExpiredSessions(now)
+	assert.NoError(err)
+	assert.Equal(1, n)
+	_, err = GetSession(expired.ID)
+	assert.Error(err)
+
+	assert.NoError(sess.Delete())
+	_, err = GetSession(sess.ID)
+	assert.Error(err)
+}
@@ -0,0 +1,779 @@
+package models
+
+import (
+	"crypto/rand"
+	"database/sql"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/datastore"
+)
+
+// sqlDialect describes differences between supported sql databases
+type sqlDialect struct {
+	blobType string
+	noLimit  string
+	numbered bool // placeholders are numbered like $1
+	maxConns int
+	// lockRows suffix of selects locking rows in transactions
+	lockRows string
+}
+
+var sqlDialects = map[string]*sqlDialect{
+	"postgres": {blobType: "BYTEA", noLimit: "ALL", numbered: true, lockRows: " FOR UPDATE"},
+	"sqlite3":  {blobType: "BLOB", noLimit: "-1", maxConns: 1},
+}
+
+// updateAttempts attempts of Update when entities are created concurrently
+const updateAttempts = 3
+
+// SQLStore storage backend for PostgreSQL and SQLite databases
+type SQLStore struct {
+	DB      *sql.DB
+	dialect *sqlDialect
+}
+
+// NewSQLStore opens sql database and applies pending schema migrations
+func NewSQLStore(driver, source string) (*SQLStore, error) {
+	dialect, ok := sqlDialects[driver]
+	if !ok {
+		return nil, fmt.Errorf("models: unsupported sql driver %q", driver)
+	}
+	db, err := sql.Open(driver, source)
+	if err != nil {
+		return nil, err
+	}
+	if dialect.maxConns > 0 {
+		db.SetMaxOpenConns(dialect.maxConns)
+	}
+	d := &SQLStore{DB: db, dialect: dialect}
+	if err := d.Migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return d, nil
+}
+
+// Rebind replaces ? placeholders in query with the ones used by the dialect
+func (d *SQLStore) Rebind(query string) string {
+	if !d.dialect.numbered {
+		return query
+	}
+	var buf strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// Migrate applies schema migrations which have not been applied yet
+func (d *SQLStore) Migrate() error {
+	_, err := d.DB.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied TIMESTAMP)`)
+	if err != nil {
+		return err
+	}
+	var current int
+	row := d.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`)
+	if err := row.Scan(&current); err != nil {
+		return err
+	}
+
+	for _, m := range sqlMigrations(d.dialect) {
+		if m.version <= current {
+			continue
+		}
+		tx, err := d.DB.Begin()
+		if err != nil {
+			return err
+		}
+		for _, stmt := range m.statements {
+			if _, err := tx.Exec(stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("models: migration %d: %v", m.version, err)
+			}
+		}
+		_, err = tx.Exec(d.Rebind(`INSERT INTO schema_migrations (version, applied) VALUES (?, ?)`), m.version, time.Now().UTC())
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		logger.Infof("Applied schema migration %d", m.version)
+	}
+	return nil
+}
+
+func (d *SQLStore) table(kind string) (*sqlTable, error) {
+	t, ok := sqlTables[kind]
+	if !ok {
+		return nil, fmt.Errorf("models: no sql table for kind %q", kind)
+	}
+	return t, nil
+}
+
+// keyID returns the id column value of key
+func keyID(k *datastore.Key) string {
+	if k.Name != "" {
+		return k.Name
+	}
+	return strconv.FormatInt(k.ID, 10)
+}
+
+// columnKey builds key of kind stored as id in a column,
+// kinds of tables with numeric ids have id keys
+func columnKey(kind, id string, parent *datastore.Key) *datastore.Key {
+	if t, ok := sqlTables[kind]; ok && t.idKeys {
+		n, _ := strconv.ParseInt(id, 10, 64)
+		return datastore.IDKey(kind, n, parent)
+	}
+	return datastore.NameKey(kind, id, parent)
+}
+
+// checkKeyType returns error unless complete key has the key
+// type of its kind, an id key when its table has numeric ids
+func checkKeyType(key *datastore.Key) error {
+	t, ok := sqlTables[key.Kind]
+	if !ok || key.Incomplete() || t.idKeys == (key.Name == "") {
+		return nil
+	}
+	if t.idKeys {
+		return fmt.Errorf("models: %s keys must be id keys", key.Kind)
+	}
+	return fmt.Errorf("models: %s keys must be name keys", key.Kind)
+}
+
+// entityKey builds key of entity value v stored in table t
+func (t *sqlTable) entityKey(id string, v reflect.Value) *datastore.Key {
+	var parent *datastore.Key
+	if t.parent != nil {
+		parent = columnKey(t.parent.kind, v.FieldByName(t.parent.field).String(), nil)
+	}
+	return columnKey(t.kind, id, parent)
+}
+
+// checkParent returns error unless parent of key is stored in column
+// of entity value v, keys have a parent only when the table has one
+func (t *sqlTable) checkParent(key *datastore.Key, v reflect.Value) error {
+	if t.parent == nil {
+		if key.Parent != nil {
+			return fmt.Errorf("models: %s keys have no parent", t.kind)
+		}
+		return nil
+	}
+	p := key.Parent
+	if p == nil || p.Parent != nil || p.Kind != t.parent.kind || p.Name != v.FieldByName(t.parent.field).String() {
+		return fmt.Errorf("models: parent of %s keys must be the %s key of %s", t.kind, t.parent.kind, t.parent.field)
+	}
+	return nil
+}
+
+// newID allocates a random positive numeric id
+func newID() int64 {
+	var b [8]byte
+	rand.Read(b[:])
+	return int64(binary.BigEndian.Uint64(b[:]) >> 1)
+}
+
+// toSQL converts a property value into a sql parameter
+func toSQL(v interface{}, c *sqlColumn) (interface{}, error) {
+	switch x := v.(type) {
+	case *datastore.Key:
+		if x == nil {
+			return nil, nil
+		}
+		if c.kind != "" {
+			if x.Kind != c.kind {
+				return nil, fmt.Errorf("models: %s keys can't be stored in %s", x.Kind, c.name)
+			}
+			return keyID(x), checkKeyType(x)
+		}
+		return x.Encode(), nil
+	case time.Time:
+		return x.UTC(), nil
+	case *time.Time:
+		if x == nil {
+			return nil, nil
+		}
+		return x.UTC(), nil
+	}
+
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return nil, nil
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Struct:
+		if rv.Kind() != reflect.Struct && rv.IsNil() {
+			return nil, nil
+		}
+		if rv.Kind() == reflect.Slice && rv.Type().Elem().Kind() == reflect.Uint8 {
+			return v, nil
+		}
+		bs, err := json.Marshal(v)
+		return string(bs), err
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), nil
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), nil
+	}
+	return v, nil
+}
+
+func rawString(raw interface{}) string {
+	switch x := raw.(type) {
+	case []byte:
+		return string(x)
+	case string:
+		return x
+	}
+	return fmt.Sprint(raw)
+}
+
+func rawTime(raw interface{}) (time.Time, error) {
+	if t, ok := raw.(time.Time); ok {
+		return t, nil
+	}
+	s := rawString(raw)
+	for _, layout := range []string{"2006-01-02 15:04:05.999999999-07:00", time.RFC3339Nano, "2006-01-02 15:04:05"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("models: invalid timestamp %q", s)
+}
+
+// fromSQL sets field from a raw sql value
+func fromSQL(field reflect.Value, raw interface{}, c *sqlColumn) error {
+	if raw == nil {
+		return nil
+	}
+	switch field.Interface().(type) {
+	case *datastore.Key:
+		s := rawString(raw)
+		if c.kind != "" {
+			field.Set(reflect.ValueOf(columnKey(c.kind, s, nil)))
+			return nil
+		}
+		key, err := datastore.DecodeKey(s)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(key))
+		return nil
+	case time.Time:
+		t, err := rawTime(raw)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t.Local()))
+		return nil
+	case *time.Time:
+		t, err := rawTime(raw)
+		if err != nil {
+			return err
+		}
+		t = t.Local()
+		field.Set(reflect.ValueOf(&t))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.Slice, reflect.Map, reflect.Ptr, reflect.Struct:
+		if b, ok := raw.([]byte); ok && field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes(append([]byte{}, b...))
+			return nil
+		}
+		return json.Unmarshal([]byte(rawString(raw)), field.Addr().Interface())
+	case reflect.String:
+		field.SetString(rawString(raw))
+	case reflect.Bool:
+		switch x := raw.(type) {
+		case bool:
+			field.SetBool(x)
+		case int64:
+			field.SetBool(x != 0)
+		default:
+			b, _ := strconv.ParseBool(rawString(raw))
+			field.SetBool(b)
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		switch x := raw.(type) {
+		case int64:
+			field.SetInt(x)
+		case float64:
+			field.SetInt(int64(x))
+		default:
+			n, _ := strconv.ParseInt(rawString(raw), 10, 64)
+			field.SetInt(n)
+		}
+	case reflect.Float32, reflect.Float64:
+		switch x := raw.(type) {
+		case float64:
+			field.SetFloat(x)
+		case int64:
+			field.SetFloat(float64(x))
+		default:
+			n, _ := strconv.ParseFloat(rawString(raw), 64)
+			field.SetFloat(n)
+		}
+	}
+	return nil
+}
+
+// columnNames returns names of the table columns stored in its own row
+func (t *sqlTable) columnNames() []string {
+	names := []string{"id"}
+	for _, c := range t.columns {
+		if c.list == "" {
+			names = append(names, c.name)
+		}
+	}
+	return names
+}
+
+type sqlQuerier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// loadLists loads multi-valued properties of entity with id
+func (d *SQLStore) loadLists(q sqlQuerier, t *sqlTable, id string, v reflect.Value) error {
+	for _, c := range t.columns {
+		if c.list == "" {
+			continue
+		}
+		query := fmt.Sprintf("SELECT %s FROM %s WHERE owner_id = ? ORDER BY position", c.name, c.list)
+		rows, err := q.Query(d.Rebind(query), id)
+		if err != nil {
+			return err
+		}
+		var values []string
+		for rows.Next() {
+			var s string
+			if err := rows.Scan(&s); err != nil {
+				rows.Close()
+				return err
+			}
+			values = append(values, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		v.FieldByName(c.field).Set(reflect.ValueOf(values))
+	}
+	return nil
+}
+
+// scanRows scans rows of table t into struct values of type typ
+func (d *SQLStore) scanRows(rows *sql.Rows, t *sqlTable, typ reflect.Type) ([]string, []reflect.Value, error) {
+	var ids []string
+	var values []reflect.Value
+
+	names := t.columnNames()
+	for rows.Next() {
+		raw := make([]interface{}, len(names))
+		dest := make([]interface{}, len(names))
+		for i := range raw {
+			dest[i] = &raw[i]
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+		v := reflect.New(typ).Elem()
+		i := 1
+		for _, c := range t.columns {
+			if c.list != "" {
+				continue
+			}
+			if err := fromSQL(v.FieldByName(c.field), raw[i], c); err != nil {
+				return nil, nil, err
+			}
+			i++
+		}
+		ids = append(ids, rawString(raw[0]))
+		values = append(values, v)
+	}
+	return ids, values, rows.Err()
+}
+
+// get loads entity value val stored under key, rows are locked within transactions
+func (d *SQLStore) get(q sqlQuerier, key *datastore.Key, val reflect.Value, lock bool) error {
+	if key == nil || key.Incomplete() {
+		return datastore.ErrInvalidKey
+	}
+	t, err := d.table(key.Kind)
+	if err != nil {
+		return err
+	}
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE id = ?", strings.Join(t.columnNames(), ", "), t.name)
+	if lock {
+		query += d.dialect.lockRows
+	}
+	rows, err := q.Query(d.Rebind(query), keyID(key))
+	if err != nil {
+		return err
+	}
+	ids, values, err := d.scanRows(rows, t, val.Type())
+	rows.Close()
+	if err != nil {
+		return err
+	}
+	if len(values) < 1 {
+		return datastore.ErrNoSuchEntity
+	}
+	if err := d.loadLists(q, t, ids[0], values[0]); err != nil {
+		return err
+	}
+	// keep the id of the entity, it is not a stored property
+	values[0].FieldByName("ID").Set(val.FieldByName("ID"))
+	val.Set(values[0])
+	return nil
+}
+
+// GetByKey retrive entity by key
+func (d *SQLStore) GetByKey(entity EntitySpec) error {
+	return d.get(d.DB, entity.Key(), reflect.ValueOf(entity).Elem(), false)
+}
+
+// predicate translates filter into a sql predicate and its argument
+func (d *SQLStore) predicate(t *sqlTable, f *Filter) (string, interface{}, error) {
+	name, op := f.parse()
+	switch op {
+	case "=", "<", "<=", ">", ">=":
+	default:
+		return "", nil, fmt.Errorf("models: unsupported filter operator %q", op)
+	}
+	c := t.column(name)
+	if c == nil {
+		return "", nil, fmt.Errorf("models: unknown property %q of %s", name, t.kind)
+	}
+	arg, err := toSQL(f.value, c)
+	if err != nil {
+		return "", nil, err
+	}
+	if c.list != "" {
+		pred := fmt.Sprintf("EXISTS (SELECT 1 FROM %[1]s WHERE %[1]s.owner_id = %[2]s.id AND %[1]s.%[3]s %[4]s ?)", c.list, t.name, c.name, op)
+		return pred, arg, nil
+	}
+	return fmt.Sprintf("%s %s ?", c.name, op), arg, nil
+}
+
+// GetAll retrieves all entities based on given query
+func (d *SQLStore) GetAll(opts *Query, entities interface{}) ([]*datastore.Key, error) {
+	t, err := d.table(opts.Kind)
+	if err != nil {
+		return nil, err
+	}
+	dst := reflect.ValueOf(entities)
+	if dst.Kind() != reflect.Ptr || dst.Elem().Kind() != reflect.Slice {
+		return nil, fmt.Errorf("models: entities must be a pointer to a slice, got %T", entities)
+	}
+
+	var where, order []string
+	var args []interface{}
+	if a := opts.Ancestor; a != nil {
+		if t.parent == nil || a.Kind != t.parent.kind || a.Parent != nil {
+			return nil, fmt.Errorf("models: %s entities have no %s ancestors", t.kind, a.Kind)
+		}
+		where = append(where, t.parent.name+" = ?")
+		args = append(args, keyID(a))
+	}
+	for _, f := range opts.Filters {
+		pred, arg, err := d.predicate(t, f)
+		if err != nil {
+			return nil, err
+		}
+		where = append(where, pred)
+		args = append(args, arg)
+	}
+	for _, o := range opts.Order {
+		name, dir := o, "ASC"
+		if strings.HasPrefix(o, "-") {
+			name, dir = o[1:], "DESC"
+		}
+		c := t.column(name)
+		if c == nil || c.list != "" {
+			return nil, fmt.Errorf("models: cannot order %s by %q", t.kind, name)
+		}
+		order = append(order, c.name+" "+dir)
+	}
+	order = append(order, "id ASC")
+
+	query := fmt.Sprintf("SELECT %s FROM %s", strings.Join(t.columnNames(), ", "), t.name)
+	if len(where) > 0 {
+		query += " WHERE " + strings.Join(where, " AND ")
+	}
+	query += " ORDER BY " + strings.Join(order, ", ")
+	limit := d.dialect.noLimit
+	if opts.Limit > 0 {
+		limit = strconv.Itoa(opts.Limit)
+	}
+	query += fmt.Sprintf(" LIMIT %s OFFSET %d", limit, opts.Offset)
+
+	logger.Debugf("%s %v", query, args)
+
+	slice := dst.Elem()
+	elemType := slice.Type().Elem()
+	structType := elemType
+	if elemType.Kind() == reflect.Ptr {
+		structType = elemType.Elem()
+	}
+
+	rows, err := d.DB.Query(d.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, values, err := d.scanRows(rows, t, structType)
+	rows.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]*datastore.Key, len(values))
+	for i, v := range values {
+		if err := d.loadLists(d.DB, t, ids[i], v); err != nil {
+			return nil, err
+		}
+		keys[i] = t.entityKey(ids[i], v)
+		if elemType.Kind() == reflect.Ptr {
+			p := reflect.New(structType)
+			p.Elem().Set(v)
+			v = p
+		}
+		slice = reflect.Append(slice, v)
+	}
+	dst.Elem().Set(slice)
+	return keys, nil
+}
+
+// put upserts entity value v stored under key within transaction tx,
+// existing entities are not replaced when create is true
+func (d *SQLStore) put(tx *sql.Tx, key *datastore.Key, v reflect.Value, create bool) (*datastore.Key, error) {
+	t, err := d.table(key.Kind)
+	if err != nil {
+		return nil, err
+	}
+	if v.Kind() == reflect.Ptr {
+		v = v.Elem()
+	}
+	if err := t.checkParent(key, v); err != nil {
+		return nil, err
+	}
+	if err := checkKeyType(key); err != nil {
+		return nil, err
+	}
+	if key.Incomplete() {
+		key = datastore.IDKey(key.Kind, newID(), key.Parent)
+	}
+	id := keyID(key)
+
+	names := t.columnNames()
+	args := []interface{}{id}
+	var updates []string
+	for _, c := range t.columns {
+		if c.list != "" {
+			continue
+		}
+		arg, err := toSQL(v.FieldByName(c.field).Interface(), c)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		updates = append(updates, fmt.Sprintf("%[1]s = excluded.%[1]s", c.name))
+	}
+	conflict := "DO UPDATE SET " + strings.Join(updates, ", ")
+	if create {
+		conflict = "DO NOTHING"
+	}
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (?%s) ON CONFLICT (id) %s",
+		t.name, strings.Join(names, ", "), strings.Repeat(", ?", len(names)-1), conflict)
+	res, err := tx.Exec(d.Rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	if create {
+		n, err := res.RowsAffected()
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 {
+			return nil, ErrEntityExists
+		}
+	}
+
+	for _, c := range t.columns {
+		if c.list == "" {
+			continue
+		}
+		if _, err := tx.Exec(d.Rebind(fmt.Sprintf("DELETE FROM %s WHERE owner_id = ?", c.list)), id); err != nil {
+			return nil, err
+		}
+		field := v.FieldByName(c.field)
+		insert := d.Rebind(fmt.Sprintf("INSERT INTO %s (owner_id, position, %s) VALUES (?, ?, ?)", c.list, c.name))
+		for i := 0; i < field.Len(); i++ {
+			if _, err := tx.Exec(insert, id, i, field.Index(i).Interface()); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return key, nil
+}
+
+// Save saves entity and sets its id
+func (d *SQLStore) Save(doc EntitySpec) *datastore.Key {
+	key := doc.Key()
+	setTimestamps(doc, key.Incomplete())
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		logger.Panic(err)
+	}
+	key, err = d.put(tx, key, reflect.ValueOf(doc), false)
+	if err != nil {
+		tx.Rollback()
+		logger.Panic(err)
+	}
+	if err := tx.Commit(); err != nil {
+		logger.Panic(err)
+	}
+	// set id
+	doc.SetID(key)
+
+	return key
+}
+
+// Create saves entity unless its key exists
+func (d *SQLStore) Create(doc EntitySpec) error {
+	key := doc.Key()
+	setTimestamps(doc, true)
+
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	key, err = d.put(tx, key, reflect.ValueOf(doc), true)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	doc.SetID(key)
+	return nil
+}
+
+// Update loads and saves entity in a transaction, the row of entity is
+// locked when the database supports it. Update is retried when the
+// entity is created by another transaction meanwhile
+func (d *SQLStore) Update(entity EntitySpec, update func(exists bool) error) error {
+	key := entity.Key()
+	dst := reflect.ValueOf(entity).Elem()
+	orig := copyValue(dst)
+	for attempt := 1; ; attempt++ {
+		dst.Set(orig)
+		err := d.update(key, entity, update)
+		if err != ErrEntityExists || attempt == updateAttempts {
+			return err
+		}
+	}
+}
+
+func (d *SQLStore) update(key *datastore.Key, entity EntitySpec, update func(exists bool) error) error {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	err = d.get(tx, key, reflect.ValueOf(entity).Elem(), true)
+	if err != nil && err != datastore.ErrNoSuchEntity {
+		tx.Rollback()
+		return err
+	}
+	exists := err == nil
+	if err := update(exists); err != nil {
+		tx.Rollback()
+		return err
+	}
+	setTimestamps(entity, !exists)
+	if _, err := d.put(tx, key, reflect.ValueOf(entity), !exists); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// Delete deletes an entity from its kind
+func (d *SQLStore) Delete(key *datastore.Key) error {
+	return d.DeleteMulti([]*datastore.Key{key})
+}
+
+// DeleteMulti deletes multiple entities at once
+func (d *SQLStore) DeleteMulti(keys []*datastore.Key) error {
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return err
+	}
+	for _, key := range keys {
+		if err := d.delete(tx, key); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// delete deletes an entity and its list rows in transaction tx
+func (d *SQLStore) delete(tx *sql.Tx, key *datastore.Key) error {
+	t, err := d.table(key.Kind)
+	if err != nil {
+		return err
+	}
+	for _, c := range t.columns {
+		if c.list == "" {
+			continue
+		}
+		if _, err := tx.Exec(d.Rebind(fmt.Sprintf("DELETE FROM %s WHERE owner_id = ?", c.list)), keyID(key)); err != nil {
+			return err
+		}
+	}
+	_, err = tx.Exec(d.Rebind(fmt.Sprintf("DELETE FROM %s WHERE id = ?", t.name)), keyID(key))
+	return err
+}
+
+// PutMulti saves multiple entities at once
+func (d *SQLStore) PutMulti(keys []*datastore.Key, entities interface{}) ([]*datastore.Key, error) {
+	src := reflect.ValueOf(entities)
+	if src.Kind() != reflect.Slice || src.Len() != len(keys) {
+		return nil, fmt.Errorf("models: keys and entities must be slices of equal length")
+	}
+	tx, err := d.DB.Begin()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]*datastore.Key, len(keys))
+	for i, key := range keys {
+		if out[i], err = d.put(tx, key, src.Index(i), false); err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	return out, tx.Commit()
+}
+
+// Close closes the database
+func (d *SQLStore) Close() error {
+	return d.DB.Close()
+}
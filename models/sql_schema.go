@@ -0,0 +1,523 @@
+package models
+
+import (
+	"strings"
+)
+
+// sqlTable maps an entity kind to a sql table
+type sqlTable struct {
+	kind    string
+	name    string
+	idKeys  bool // entities use numeric ids allocated on save
+	columns []*sqlColumn
+	// parent column of the name of parent keys of entities, its kind is the parent kind
+	parent *sqlColumn
+}
+
+// sqlColumn maps an entity property to a sql column
+type sqlColumn struct {
+	field string // struct field name
+	name  string // column name
+	kind  string // kind of key properties, empty to store encoded keys
+	list  string // join table for multi-valued properties
+}
+
+func col(field, name string) *sqlColumn {
+	return &sqlColumn{field: field, name: name}
+}
+
+func keyCol(field, name, kind string) *sqlColumn {
+	return &sqlColumn{field: field, name: name, kind: kind}
+}
+
+func listCol(field, name, table string) *sqlColumn {
+	return &sqlColumn{field: field, name: name, list: table}
+}
+
+var sqlTables = map[string]*sqlTable{
+	ArticleKind: {
+		kind: ArticleKind,
+		name: "articles",
+		columns: []*sqlColumn{
+			col("Title", "title"),
+			col("Description", "description"),
+			col("Summary", "summary"),
+			col("Link", "link"),
+			col("Domain", "domain"),
+			keyCol("TopicKey", "topic_id", TopicKind),
+			keyCol("StoryKey", "story_id", StoryKind),
+			col("Author", "author"),
+			col("Image", "image"),
+			listCol("Tags", "tag", "article_tags"),
+			col("Assessment", "assessment"),
+			col("Score", "score"),
+			col("Published", "published"),
+			col("Breaking", "breaking"),
+			col("CheckedScore", "checked_score"),
+			col("ScoreChecked", "score_checked"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	UserKind: {
+		kind: UserKind,
+		name: "users",
+		columns: []*sqlColumn{
+			col("FirstName", "first_name"),
+			col("LastName", "last_name"),
+			col("Avatar", "avatar"),
+			col("Locale", "locale"),
+			col("TimeZone", "time_zone"),
+			col("Gender", "gender"),
+			col("LastInteraction", "last_interaction"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	UserActionKind: {
+		kind:   UserActionKind,
+		name:   "user_actions",
+		idKeys: true,
+		columns: []*sqlColumn{
+			keyCol("UserKey", "user_id", UserKind),
+			keyCol("ItemKey", "item_id", ""),
+			col("Action", "action"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	TopicKind: {
+		kind: TopicKind,
+		name: "topics",
+		columns: []*sqlColumn{
+			col("Name", "name"),
+			col("Tags", "tags"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	SubscriptionKind: {
+		kind:   SubscriptionKind,
+		name:   "subscriptions",
+		idKeys: true,
+		columns: []*sqlColumn{
+			keyCol("User", "user_id", UserKind),
+			keyCol("Topic", "topic_id", TopicKind),
+			col("Schedule", "schedule"),
+			col("LastDigest", "last_digest"),
+			col("NotificationToken", "notification_token"),
+			col("TokenExpiry", "token_expiry"),
+			col("LastNotified", "last_notified"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	MessageKind: {
+		kind:   MessageKind,
+		name:   "messages",
+		idKeys: true,
+		columns: []*sqlColumn{
+			keyCol("User", "user_id", UserKind),
+			col("Text", "text"),
+			listCol("MID", "mid", "message_mids"),
+			col("Response", "response"),
+			col("Meta", "meta"),
+			col("DeliveryTime", "delivery_time"),
+			col("ReadTime", "read_time"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	AuditRequestKind: {
+		kind:   AuditRequestKind,
+		name:   "audit_requests",
+		idKeys: true,
+		columns: []*sqlColumn{
+			keyCol("UserKey", "user_id", UserKind),
+			col("Path", "path"),
+			col("Proto", "proto"),
+			col("StatusCode", "status_code"),
+			col("UserAgent", "user_agent"),
+			col("IPAddress", "ip_address"),
+			col("Duration", "duration"),
+			col("Method", "method"),
+			col("Query", "query"),
+			col("Body", "body"),
+			col("Referrer", "referrer"),
+			col("IsRobot", "is_robot"),
+			col("Size", "size"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	StoryKind: {
+		kind: StoryKind,
+		name: "stories",
+		columns: []*sqlColumn{
+			col("Title", "title"),
+			keyCol("Lead", "lead_id", ArticleKind),
+			col("Articles", "articles"),
+			col("Domains", "domains"),
+			col("Terms", "terms"),
+			col("Signature", "signature"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	CrawledItemKind: {
+		kind: CrawledItemKind,
+		name: "crawled_items",
+		columns: []*sqlColumn{
+			col("Link", "link"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	CrawlRunKind: {
+		kind:   CrawlRunKind,
+		name:   "crawl_runs",
+		idKeys: true,
+		columns: []*sqlColumn{
+			col("Started", "started"),
+			col("Duration", "duration"),
+			col("Spiders", "spiders"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	SentItemKind: {
+		kind: SentItemKind,
+		name: "sent_items",
+		columns: []*sqlColumn{
+			keyCol("User", "user_id", UserKind),
+			keyCol("Item", "item_id", ArticleKind),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	SearchTermKind: {
+		kind: SearchTermKind,
+		name: "search_terms",
+		columns: []*sqlColumn{
+			col("Term", "term"),
+			keyCol("Article", "article_id", ArticleKind),
+			col("Freq", "freq"),
+			col("Length", "length"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	SearchStatsKind: {
+		kind: SearchStatsKind,
+		name: "search_stats",
+		columns: []*sqlColumn{
+			col("DocCount", "doc_count"),
+			col("TotalLength", "total_length"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	SessionKind: {
+		kind: SessionKind,
+		name: "sessions",
+		columns: []*sqlColumn{
+			col("Data", "data"),
+			col("Expires", "expires"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	OutboundMessageKind: {
+		kind:   OutboundMessageKind,
+		name:   "outbound_messages",
+		idKeys: true,
+		parent: keyCol("Recipient", "recipient", UserKind),
+		columns: []*sqlColumn{
+			col("Recipient", "recipient"),
+			col("Payload", "payload"),
+			keyCol("Message", "message_id", MessageKind),
+			col("Seq", "seq"),
+			col("Status", "status"),
+			col("Attempts", "attempts"),
+			col("NextAttempt", "next_attempt"),
+			col("LastError", "last_error"),
+			col("Owner", "owner"),
+			col("Lease", "lease"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+	ProcessedEventKind: {
+		kind: ProcessedEventKind,
+		name: "processed_events",
+		columns: []*sqlColumn{
+			col("Expires", "expires"),
+			col("Created", "created"),
+			col("Updated", "updated"),
+		},
+	},
+}
+
+// column returns column mapped to struct field
+func (t *sqlTable) column(field string) *sqlColumn {
+	for _, c := range t.columns {
+		if c.field == field {
+			return c
+		}
+	}
+	return nil
+}
+
+// sqlMigration a versioned list of schema statements
+type sqlMigration struct {
+	version    int
+	statements []string
+}
+
+// sqlMigrations returns schema migrations in the order they must be applied,
+// {{blob}} is replaced with the binary column type of the dialect
+func sqlMigrations(d *sqlDialect) []*sqlMigration {
+	ms := []*sqlMigration{
+		{1, []string{
+			`CREATE TABLE articles (
+				id TEXT PRIMARY KEY,
+				title TEXT,
+				description TEXT,
+				summary TEXT,
+				link TEXT,
+				domain TEXT,
+				topic_id TEXT,
+				author TEXT,
+				image TEXT,
+				assessment TEXT,
+				score DOUBLE PRECISION,
+				published TIMESTAMP,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX articles_topic_published ON articles (topic_id, published)`,
+			`CREATE INDEX articles_domain_published ON articles (domain, published)`,
+			`CREATE TABLE article_tags (
+				owner_id TEXT NOT NULL,
+				position INTEGER NOT NULL,
+				tag TEXT NOT NULL,
+				PRIMARY KEY (owner_id, position)
+			)`,
+			`CREATE INDEX article_tags_tag ON article_tags (tag)`,
+			`CREATE TABLE users (
+				id TEXT PRIMARY KEY,
+				first_name TEXT,
+				last_name TEXT,
+				avatar TEXT,
+				locale TEXT,
+				timezone INTEGER,
+				gender TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE user_actions (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				item_id TEXT,
+				action TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE topics (
+				id TEXT PRIMARY KEY,
+				name TEXT,
+				tags TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE subscriptions (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				topic_id TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX subscriptions_user ON subscriptions (user_id)`,
+			`CREATE TABLE messages (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				text TEXT,
+				response TEXT,
+				meta TEXT,
+				delivery_time TIMESTAMP,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE message_mids (
+				owner_id TEXT NOT NULL,
+				position INTEGER NOT NULL,
+				mid TEXT NOT NULL,
+				PRIMARY KEY (owner_id, position)
+			)`,
+			`CREATE INDEX message_mids_mid ON message_mids (mid)`,
+			`CREATE TABLE audit_requests (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				path TEXT,
+				proto TEXT,
+				status_code INTEGER,
+				user_agent TEXT,
+				ip_address TEXT,
+				duration TEXT,
+				method TEXT,
+				query TEXT,
+				body TEXT,
+				referrer TEXT,
+				is_robot BOOLEAN,
+				size INTEGER,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE sent_items (
+				id TEXT PRIMARY KEY,
+				user_id TEXT,
+				item_id TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE TABLE certs (
+				id TEXT PRIMARY KEY,
+				data {{blob}}
+			)`,
+		}},
+		{2, []string{
+			`CREATE TABLE search_terms (
+				id TEXT PRIMARY KEY,
+				term TEXT,
+				article_id TEXT,
+				freq INTEGER,
+				length INTEGER,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX search_terms_term ON search_terms (term)`,
+			`CREATE INDEX search_terms_article ON search_terms (article_id)`,
+			`CREATE TABLE search_stats (
+				id TEXT PRIMARY KEY,
+				doc_count INTEGER,
+				total_length INTEGER,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+		}},
+		{3, []string{
+			`CREATE TABLE sessions (
+				id TEXT PRIMARY KEY,
+				data TEXT,
+				expires TIMESTAMP,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX sessions_expires ON sessions (expires)`,
+		}},
+		{4, []string{
+			`CREATE INDEX articles_link ON articles (link)`,
+		}},
+		{5, []string{
+			`CREATE TABLE outbound_messages (
+				id TEXT PRIMARY KEY,
+				recipient TEXT,
+				payload TEXT,
+				message_id TEXT,
+				seq BIGINT,
+				status TEXT,
+				attempts INTEGER,
+				next_attempt TIMESTAMP,
+				last_error TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX outbound_messages_status_recipient ON outbound_messages (status, recipient, seq)`,
+		}},
+		{6, []string{
+			`CREATE TABLE processed_events (
+				id TEXT PRIMARY KEY,
+				expires TIMESTAMP,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX processed_events_expires ON processed_events (expires)`,
+		}},
+		{7, []string{
+			`ALTER TABLE messages ADD COLUMN read_time TIMESTAMP`,
+			`CREATE INDEX messages_user_created ON messages (user_id, created)`,
+		}},
+		{8, []string{
+			`ALTER TABLE subscriptions ADD COLUMN schedule TEXT`,
+			`ALTER TABLE subscriptions ADD COLUMN last_digest TIMESTAMP`,
+		}},
+		{9, []string{
+			`ALTER TABLE users ADD COLUMN last_interaction TIMESTAMP`,
+			`ALTER TABLE messages ADD COLUMN skip_reason TEXT`,
+		}},
+		{10, []string{
+			`ALTER TABLE subscriptions ADD COLUMN notification_token TEXT`,
+			`ALTER TABLE subscriptions ADD COLUMN token_expiry TIMESTAMP`,
+			`ALTER TABLE subscriptions ADD COLUMN last_notified TIMESTAMP`,
+		}},
+		{11, []string{
+			`ALTER TABLE articles ADD COLUMN breaking TIMESTAMP`,
+			`CREATE INDEX articles_created ON articles (created)`,
+			`CREATE INDEX subscriptions_topic ON subscriptions (topic_id)`,
+			`CREATE INDEX user_actions_user_action ON user_actions (user_id, action, created)`,
+		}},
+		{12, []string{
+			`ALTER TABLE articles ADD COLUMN story_id TEXT`,
+			`CREATE TABLE stories (
+				id TEXT PRIMARY KEY,
+				title TEXT,
+				lead_id TEXT,
+				articles TEXT,
+				domains TEXT,
+				terms TEXT,
+				signature TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX stories_created ON stories (created)`,
+		}},
+		{13, []string{
+			`CREATE TABLE crawled_items (
+				id TEXT PRIMARY KEY,
+				link TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+		}},
+		{14, []string{
+			`CREATE TABLE crawl_runs (
+				id TEXT PRIMARY KEY,
+				started TIMESTAMP,
+				duration DOUBLE PRECISION,
+				spiders TEXT,
+				created TIMESTAMP,
+				updated TIMESTAMP
+			)`,
+			`CREATE INDEX crawl_runs_started ON crawl_runs (started)`,
+		}},
+		{15, []string{
+			`ALTER TABLE outbound_messages ADD COLUMN owner TEXT`,
+			`ALTER TABLE outbound_messages ADD COLUMN lease TIMESTAMP`,
+			`CREATE INDEX outbound_messages_recipient_status ON outbound_messages (recipient, status, seq)`,
+		}},
+		{16, []string{
+			`ALTER TABLE users ADD COLUMN time_zone DOUBLE PRECISION`,
+			`UPDATE users SET time_zone = timezone`,
+		}},
+		{17, []string{
+			`ALTER TABLE articles ADD COLUMN checked_score DOUBLE PRECISION`,
+			`ALTER TABLE articles ADD COLUMN score_checked TIMESTAMP`,
+		}},
+	}
+	for _, m := range ms {
+		for i, stmt := range m.statements {
+			m.statements[i] = strings.Replace(stmt, "{{blob}}", d.blobType, -1)
+		}
+	}
+	return ms
+}
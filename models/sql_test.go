@@ -0,0 +1,112 @@
+package models
+
+import (
+	"testing"
+	"time"
+
+	"cloud.google.com/go/datastore"
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQLStore(t *testing.T) {
+	assert := assert.New(t)
+
+	st, err := NewSQLStore("sqlite3", ":memory:")
+	assert.NoError(err)
+	defer st.Close()
+	// migrations are applied only once
+	assert.NoError(st.Migrate())
+
+	now := time.Now()
+	topic := GetTopicKey("sql")
+	for i, title := range []string{"one", "two", "three"} {
+		pub := now.Add(time.Duration(i) * time.Hour)
+		nw := NewArticle(title, "sql-"+title, "", "", "sql.com", "", &pub, []string{title, "news"})
+		nw.TopicKey = topic
+		nw.Summary = []string{title}
+		nw.Assessment = &Assessment{ReadingTime: "1 min"}
+		st.Save(nw)
+	}
+
+	nw := Article{ID: "sql-two"}
+	assert.NoError(st.GetByKey(&nw))
+	assert.Equal("two", nw.Title)
+	assert.Equal([]string{"two", "news"}, nw.Tags)
+	assert.Equal(topic, nw.TopicKey)
+	assert.Equal("1 min", nw.Assessment.ReadingTime)
+	assert.True(nw.Published.Equal(now.Add(time.Hour)))
+
+	var articles []*Article
+	fs := []*Filter{NewFilter("Tags =", "news"), NewFilter("TopicKey =", topic)}
+	keys, err := st.GetAll(NewQuery(ArticleKind, fs, 2, 1, "-Published"), &articles)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal("three", articles[0].Title)
+	assert.Equal("sql-three", keys[0].Name)
+
+	// keywords are matched with AND semantics
+	articles = nil
+	fs = []*Filter{NewFilter("Tags =", "news"), NewFilter("Tags =", "one")}
+	_, err = st.GetAll(NewBaseQuery(ArticleKind, fs), &articles)
+	assert.NoError(err)
+	assert.Len(articles, 1)
+
+	articles = nil
+	fs = []*Filter{NewFilter("Published >=", now.Add(time.Hour))}
+	_, err = st.GetAll(NewQuery(ArticleKind, fs, 0, 0, "Published"), &articles)
+	assert.NoError(err)
+	assert.Len(articles, 2)
+	assert.Equal("two", articles[0].Title)
+
+	_, err = st.GetAll(NewBaseQuery(ArticleKind, []*Filter{NewFilter("Tags !=", "x")}), &articles)
+	assert.Error(err)
+
+	assert.NoError(st.Delete(nw.Key()))
+	assert.Equal(datastore.ErrNoSuchEntity, st.GetByKey(&Article{ID: "sql-two"}))
+
+	msg := &Message{Text: "hi", User: GetUserKey("sql"), MID: []string{"m1", "m2"}}
+	key := st.Save(msg)
+	assert.False(key.Incomplete())
+
+	var msgs []*Message
+	keys, err = st.GetAll(NewBaseQuery(MessageKind, []*Filter{NewFilter("MID =", "m2")}), &msgs)
+	assert.NoError(err)
+	assert.Len(msgs, 1)
+	msgs[0].DeliveryTime = &now
+	_, err = st.PutMulti(keys, msgs)
+	assert.NoError(err)
+
+	found := Message{ID: msg.ID}
+	assert.NoError(st.GetByKey(&found))
+	assert.NotNil(found.DeliveryTime)
+	assert.Equal("sql", found.User.Name)
+
+	// keys of kinds with numeric ids are loaded as id keys
+	out := NewOutboundMessage("sql", "{}", key, 1)
+	st.Save(out)
+	var outs []*OutboundMessage
+	_, err = st.GetAll(NewBaseQuery(OutboundMessageKind, nil), &outs)
+	assert.NoError(err)
+	if assert.Len(outs, 1) {
+		assert.True(key.Equal(outs[0].Message), outs[0].Message)
+	}
+	// keys of the wrong type are rejected
+	assert.Error(st.Create(&Message{ID: datastore.NameKey(MessageKind, "m", nil).Encode()}))
+	assert.Error(st.Create(&OutboundMessage{Recipient: "sql", Message: datastore.NameKey(MessageKind, "m", nil)}))
+	assert.Error(st.Create(&OutboundMessage{Recipient: "sql", Message: GetUserKey("sql")}))
+
+	bu := NewUser("sql-user", "first", "last", "", "", "", 5.5)
+	st.Save(bu)
+	user := User{ID: bu.ID}
+	assert.NoError(st.GetByKey(&user))
+	assert.Equal(5.5, user.TimeZone)
+
+	// existing entities are not replaced by create
+	ev := &ProcessedEvent{ID: "mid.1", Expires: now}
+	assert.NoError(st.Create(ev))
+	assert.Equal(ErrEntityExists, st.Create(&ProcessedEvent{ID: "mid.1", Expires: now.Add(time.Hour)}))
+	claimed := ProcessedEvent{ID: "mid.1"}
+	assert.NoError(st.GetByKey(&claimed))
+	assert.True(claimed.Expires.Equal(now))
+}
@@ -0,0 +1,243 @@
+package models
+
+import (
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"cloud.google.com/go/datastore"
+)
+
+const (
+	// StoryKind kind name for stories
+	StoryKind = "Stories"
+	// defaultStoryWindow how long stories take articles of other sources
+	defaultStoryWindow = 48 * time.Hour
+	// defaultStorySimilarity minimum text similarity of articles of a story
+	defaultStorySimilarity = 0.3
+	// storyTitleSimilarity minimum title similarity of articles of a story
+	storyTitleSimilarity = 0.5
+	// recentStories maximum number of stories compared with an article
+	recentStories = 500
+)
+
+// storyMu serializes assignments of articles to stories
+var storyMu sync.Mutex
+
+// Story cluster of near-duplicate articles of different sources
+// about the same news, the first article reported is the lead
+type Story struct {
+	ID        string           `json:"id" datastore:"-"`
+	Title     string           `json:"title"`
+	Lead      *datastore.Key   `json:"lead"`
+	Articles  []*datastore.Key `json:"articles" datastore:",noindex"`
+	Domains   []string         `json:"domains" datastore:",noindex"`
+	Terms     []string         `json:"-" datastore:",noindex"`
+	Signature []int64          `json:"-" datastore:",noindex"`
+	Created   time.Time        `json:"created"`
+	Updated   time.Time        `json:"updated"`
+}
+
+// Key get key for story
+func (m *Story) Key() *datastore.Key {
+	if m.ID == "" {
+		return NewKey(StoryKind)
+	}
+	return datastore.NameKey(StoryKind, m.ID, nil)
+}
+
+// SetID set id
+func (m *Story) SetID(key *datastore.Key) {
+	m.ID = key.Name
+}
+
+// Save story
+func (m *Story) Save() {
+	DS.Save(m)
+}
+
+// GetStoryKey get story key
+func GetStoryKey(id string) *datastore.Key {
+	entity := Story{ID: id}
+	return entity.Key()
+}
+
+// GetStory returns story with id
+func GetStory(id string) (*Story, error) {
+	entity := Story{ID: id}
+	err := DS.GetByKey(&entity)
+	return &entity, err
+}
+
+// GetArticles returns articles of story, the lead first
+func (m *Story) GetArticles() ([]*Article, error) {
+	articles := make([]*Article, 0, len(m.Articles))
+	for _, key := range m.Articles {
+		article, err := GetArticle(key.Name)
+		if err == datastore.ErrNoSuchEntity {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		articles = append(articles, article)
+	}
+	return articles, nil
+}
+
+// Sources returns number of sites which reported story
+func (m *Story) Sources() int {
+	return len(m.Domains)
+}
+
+// has checks if article is in story
+func (m *Story) has(key *datastore.Key) bool {
+	for _, k := range m.Articles {
+		if k.Equal(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// add adds article to story
+func (m *Story) add(article *Article) {
+	if m.has(article.Key()) {
+		return
+	}
+	m.Articles = append(m.Articles, article.Key())
+	for _, d := range m.Domains {
+		if d == article.Domain {
+			return
+		}
+	}
+	m.Domains = append(m.Domains, article.Domain)
+}
+
+// similarity returns similarity of story and article
+// with title terms and text signature
+func (m *Story) similarity(terms []string, sig []int64) float64 {
+	title := utils.Jaccard(m.Terms, terms)
+	if title >= storyTitleSimilarity {
+		return title
+	}
+	return utils.SignatureSimilarity(m.Signature, sig)
+}
+
+// getRecentStories returns stories created after since, newest first
+func getRecentStories(since time.Time, limit int) ([]*Story, error) {
+	var stories []*Story
+
+	filters := []*Filter{NewFilter("Created >=", since)}
+	query := NewQuery(StoryKind, filters, limit, 1, "-Created")
+
+	keys, err := DS.GetAll(query, &stories)
+	for i, key := range keys {
+		stories[i].SetID(key)
+	}
+	return stories, err
+}
+
+// storySettings returns window and minimum similarity
+// of stories from STORY_WINDOW and STORY_SIMILARITY
+func storySettings() (time.Duration, float64) {
+	window, similarity := defaultStoryWindow, defaultStorySimilarity
+	if d, err := time.ParseDuration(os.Getenv("STORY_WINDOW")); err == nil && d > 0 {
+		window = d
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("STORY_SIMILARITY"), 64); err == nil && f > 0 {
+		similarity = f
+	}
+	return window, similarity
+}
+
+// currentStory returns story article was assigned to or the story it leads
+func (m *Article) currentStory() *Story {
+	id := m.ID
+	if m.StoryKey != nil {
+		id = m.StoryKey.Name
+	}
+	story, err := GetStory(id)
+	if err != nil {
+		return nil
+	}
+	return story
+}
+
+// AssignStory adds saved article to its story, the most similar recent story
+// or to a new story led by article, ta is the analysis of the article text.
+// The article is saved again when its story changes
+func (m *Article) AssignStory(ta *utils.TextAnalysis) (*Story, error) {
+	storyMu.Lock()
+	defer storyMu.Unlock()
+
+	terms := utils.Tokenize(m.Title)
+	sig := ta.Signature(m.Title)
+	best := m.currentStory()
+	if best == nil {
+		window, minSimilarity := storySettings()
+		stories, err := getRecentStories(time.Now().Add(-window), recentStories)
+		if err != nil {
+			return nil, err
+		}
+		bestSim := minSimilarity
+		for _, s := range stories {
+			if sim := s.similarity(terms, sig); sim >= bestSim {
+				best, bestSim = s, sim
+			}
+		}
+	}
+	if best == nil {
+		best = &Story{
+			ID:        m.ID,
+			Title:     m.Title,
+			Lead:      m.Key(),
+			Terms:     terms,
+			Signature: sig,
+			Created:   time.Now(),
+		}
+	}
+	if !best.has(m.Key()) {
+		best.add(m)
+		best.Save()
+	}
+	if key := best.Key(); m.StoryKey == nil || m.StoryKey.Name != key.Name {
+		m.StoryKey = key
+		m.Save()
+	}
+	return best, nil
+}
+
+// GroupStories keeps the first of articles of each story
+func GroupStories(articles []*Article) []*Article {
+	out := make([]*Article, 0, len(articles))
+	seen := map[string]bool{}
+	for _, a := range articles {
+		if a.StoryKey != nil {
+			if seen[a.StoryKey.Name] {
+				continue
+			}
+			seen[a.StoryKey.Name] = true
+		}
+		out = append(out, a)
+	}
+	return out
+}
+
+// ToStoryElement converts article to messenger template, articles of
+// stories of several sources link to the other sources instead of sharing
+func (m *Article) ToStoryElement(userID string) *utils.Element {
+	el := m.ToMessengerElement(userID)
+	if m.StoryKey == nil {
+		return el
+	}
+	story, err := GetStory(m.StoryKey.Name)
+	if err != nil || story.Sources() < 2 {
+		return el
+	}
+	el.Buttons[len(el.Buttons)-1] = utils.NewPostbackButton(utils.PostBackOtherSources, story.ID)
+	return el
+}
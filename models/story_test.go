@@ -0,0 +1,139 @@
+package models
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/utils"
+
+	"github.com/icrowley/fake"
+	"github.com/stretchr/testify/assert"
+)
+
+func newStoryArticle(title, text, domain string) (*Article, *utils.TextAnalysis) {
+	pub := time.Now()
+	link := "https://" + domain + "/" + fake.Characters()
+	article := NewArticle(title, link, fake.Sentence(), link, domain, link, &pub, []string{})
+	ta := utils.NewTextAnalysis(text, "")
+	article.AddAssessment(ta)
+	article.SetTopic(fake.Word(), []string{})
+	return article, ta
+}
+
+func TestAssignStory(t *testing.T) {
+	assert := assert.New(t)
+
+	word := fake.Characters()
+	text := "Heavy rains caused floods in parts of " + word + " on Monday, leaving many residents stranded as roads were cut off by the rising water"
+	a, ta := newStoryArticle("Floods hit "+word, text, "citinewsroom.com")
+	a.Save()
+	story, err := a.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(a.ID, story.ID)
+	assert.Equal(story.Key(), a.StoryKey)
+
+	// the same text reported by another source
+	b, ta := newStoryArticle("Residents stranded after downpour", text+" in the capital", "myjoyonline.com")
+	b.Save()
+	story, err = b.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(a.ID, story.ID)
+
+	// near-duplicate titles of different texts
+	c, ta := newStoryArticle("Floods hit "+word+" again", fake.SentencesN(5), "myjoyonline.com")
+	c.Save()
+	story, err = c.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(a.ID, story.ID)
+	assert.Equal(2, story.Sources())
+
+	d, ta := newStoryArticle(fake.Sentence(), fake.SentencesN(5), "ghanaweb.com")
+	d.Save()
+	story, err = d.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(d.ID, story.ID)
+
+	// crawled again
+	story, err = b.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(a.ID, story.ID)
+
+	story, err = GetStory(a.ID)
+	assert.NoError(err)
+	assert.Equal(a.Key(), story.Lead)
+	articles, err := story.GetArticles()
+	assert.NoError(err)
+	if assert.Len(articles, 3) {
+		assert.Equal(a.ID, articles[0].ID)
+		assert.Equal(c.ID, articles[2].ID)
+	}
+
+	assert.Equal([]*Article{a, d}, GroupStories([]*Article{a, b, d, c}))
+
+	el := a.ToStoryElement("id")
+	assert.Equal(utils.PostBackOtherSources, el.Buttons[2].Title)
+	assert.Equal(a.ID, el.Buttons[2].Payload)
+	el = d.ToStoryElement("id")
+	assert.Equal(utils.ButtonTypeShare, el.Buttons[2].Type)
+}
+
+func TestAssignStoryAfterWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	text := fake.SentencesN(5)
+	a, ta := newStoryArticle(fake.Sentence(), text, "citinewsroom.com")
+	a.Save()
+	a.AssignStory(ta)
+	b, tb := newStoryArticle(fake.Sentence(), text, "myjoyonline.com")
+	b.Save()
+	story, err := b.AssignStory(tb)
+	assert.NoError(err)
+	assert.Equal(a.ID, story.ID)
+
+	// articles crawled again after the window keep their story
+	os.Setenv("STORY_WINDOW", "1ns")
+	defer os.Unsetenv("STORY_WINDOW")
+	a = NewArticle(a.Title, a.ID, a.Description, a.Link, a.Domain, a.Image, a.Published, a.Tags)
+	a.Save()
+	story, err = a.AssignStory(ta)
+	assert.NoError(err)
+	assert.Equal(2, story.Sources())
+
+	story, err = GetStory(a.ID)
+	assert.NoError(err)
+	assert.Len(story.Articles, 2)
+	b, _ = GetArticle(b.ID)
+	assert.Equal(story.Key(), b.StoryKey)
+}
+
+func TestAssignStoryConcurrently(t *testing.T) {
+	assert := assert.New(t)
+
+	text := fake.SentencesN(5)
+	articles := make([]*Article, 20)
+	for i := range articles {
+		articles[i], _ = newStoryArticle(fake.Sentence(), text, fmt.Sprintf("source%d.com", i))
+		articles[i].Save()
+	}
+	var wg sync.WaitGroup
+	for _, a := range articles {
+		wg.Add(1)
+		go func(a *Article) {
+			defer wg.Done()
+			a.AssignStory(utils.NewTextAnalysis(text, ""))
+		}(a)
+	}
+	wg.Wait()
+
+	// the reports of the same news are one story
+	story, err := GetStory(articles[0].StoryKey.Name)
+	assert.NoError(err)
+	assert.Len(story.Articles, 20)
+	assert.Equal(20, story.Sources())
+	for _, a := range articles {
+		assert.Equal(story.Key(), a.StoryKey)
+	}
+}
@@ -1,6 +1,7 @@
 package models
 
 import (
+	"errors"
 	"fmt"
 	"github.com/epigos/newsbot/utils"
 	"time"
@@ -11,13 +12,48 @@ import (
 //SubscriptionKind kind name for subscriptions
 const SubscriptionKind = "Subscriptions"
 
-// Subscription is a model for content subscriptions
+// delivery schedules of subscriptions
+const (
+	// ScheduleRealtime articles are pushed after every crawl
+	ScheduleRealtime = "realtime"
+	// ScheduleMorning daily digest in the morning
+	ScheduleMorning = "morning"
+	// ScheduleEvening daily digest in the evening
+	ScheduleEvening = "evening"
+	// ScheduleWeekly weekly digest
+	ScheduleWeekly = "weekly"
+)
+
+// local hours and day digests are sent at
+const (
+	morningHour = 7
+	eveningHour = 18
+	weeklyDay   = time.Monday
+)
+
+// notifyInterval minimum time between notifications sent with a token
+const notifyInterval = 24 * time.Hour
+
+// errDigestNotDue aborts claims of digests which were sent
+var errDigestNotDue = errors.New("models: digest is not due")
+
+// Schedules delivery schedules users can choose
+var Schedules = []string{ScheduleRealtime, ScheduleMorning, ScheduleEvening, ScheduleWeekly}
+
+// Subscription is a model for content subscriptions,
+// an empty Schedule means realtime delivery, NotificationToken
+// allows notifications outside the standard messaging window
 type Subscription struct {
-	ID      string         `datastore:"-" json:"id"`
-	User    *datastore.Key `json:"user_id"`
-	Topic   *datastore.Key `json:"topic"`
-	Created time.Time      `json:"created"`
-	Updated time.Time      `json:"updated"`
+	ID                string         `datastore:"-" json:"id"`
+	User              *datastore.Key `json:"user_id"`
+	Topic             *datastore.Key `json:"topic"`
+	Schedule          string         `json:"schedule"`
+	LastDigest        *time.Time     `json:"last_digest"`
+	NotificationToken string         `json:"-" datastore:",noindex"`
+	TokenExpiry       *time.Time     `json:"token_expiry"`
+	LastNotified      *time.Time     `json:"last_notified"`
+	Created           time.Time      `json:"created"`
+	Updated           time.Time      `json:"updated"`
 }
 
 // Key get key for article
@@ -25,14 +61,14 @@ func (m *Subscription) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(SubscriptionKind)
+		return NewKey(SubscriptionKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
 	// on the one provided.
 	key, err := datastore.DecodeKey(m.ID)
 	if err != nil {
-		DS.Logger.Error("Key not found:", err)
+		logger.Error("Key not found:", err)
 	}
 	return key
 }
@@ -55,9 +91,115 @@ func (m *Subscription) String() string {
 
 // Description description of subscription
 func (m *Subscription) Description() string {
+	switch m.Schedule {
+	case ScheduleMorning, ScheduleEvening:
+		return fmt.Sprintf("You'll receive %s news every %s", m.Topic.Name, m.Schedule)
+	case ScheduleWeekly:
+		return fmt.Sprintf("You'll receive %s news every week", m.Topic.Name)
+	}
 	return fmt.Sprintf("You'll receive %s news throughout the day", m.Topic.Name)
 }
 
+// IsRealtime reports whether articles are pushed as they're crawled
+func (m *Subscription) IsRealtime() bool {
+	return m.Schedule == "" || m.Schedule == ScheduleRealtime
+}
+
+// DigestTime returns the latest digest time of subscription at
+// or before now, loc is the time zone of the user
+func (m *Subscription) DigestTime(now time.Time, loc *time.Location) time.Time {
+	local := now.In(loc)
+	hour := morningHour
+	if m.Schedule == ScheduleEvening {
+		hour = eveningHour
+	}
+	t := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	if m.Schedule == ScheduleWeekly {
+		t = t.AddDate(0, 0, -int((local.Weekday()-weeklyDay+7)%7))
+	}
+	if t.After(local) {
+		if m.Schedule == ScheduleWeekly {
+			return t.AddDate(0, 0, -7)
+		}
+		return t.AddDate(0, 0, -1)
+	}
+	return t
+}
+
+// DigestDue reports whether a digest of subscription is due at now,
+// the first digest is sent at the first digest time after subscribing
+func (m *Subscription) DigestDue(now time.Time, loc *time.Location) bool {
+	if m.IsRealtime() {
+		return false
+	}
+	last := m.Created
+	if m.LastDigest != nil {
+		last = *m.LastDigest
+	}
+	return last.Before(m.DigestTime(now, loc))
+}
+
+// ClaimDigest records now as the last digest of subscription m in a
+// transaction, the subscription is reloaded. It returns false when the
+// digest isn't due anymore, e.g. it was claimed by another process
+func ClaimDigest(m *Subscription, now time.Time, loc *time.Location) (bool, error) {
+	err := DS.Update(m, func(exists bool) error {
+		if !exists || !m.DigestDue(now, loc) {
+			return errDigestNotDue
+		}
+		t := now
+		m.LastDigest = &t
+		return nil
+	})
+	if err == errDigestNotDue {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// SetNotificationToken sets notification token of subscription
+// and its expiry, an empty token removes the token
+func (m *Subscription) SetNotificationToken(token string, expiry *time.Time) {
+	m.NotificationToken = token
+	m.TokenExpiry = expiry
+	if token == "" {
+		m.TokenExpiry = nil
+	}
+}
+
+// CanNotify reports whether a notification can be sent with the
+// token of subscription at now, one notification is sent a day
+func (m *Subscription) CanNotify(now time.Time) bool {
+	if m.NotificationToken == "" || (m.TokenExpiry != nil && !now.Before(*m.TokenExpiry)) {
+		return false
+	}
+	return m.LastNotified == nil || now.Sub(*m.LastNotified) >= notifyInterval
+}
+
+// GetUserSubscription get subscription of user to topic
+func GetUserSubscription(uid, topic string) (*Subscription, error) {
+	subs, err := GetUserSubscriptions(uid)
+	if err != nil {
+		return nil, err
+	}
+	for _, sub := range subs {
+		if sub.Topic.Name == topic {
+			return sub, nil
+		}
+	}
+	return nil, datastore.ErrNoSuchEntity
+}
+
+// IsSchedule reports whether schedule is a valid delivery schedule
+func IsSchedule(schedule string) bool {
+	for _, s := range Schedules {
+		if s == schedule {
+			return true
+		}
+	}
+	return false
+}
+
 // StopButton get messenger stop button
 func (m *Subscription) StopButton() []*utils.Button {
 	title := fmt.Sprintf("Stop %s", m.Topic.Name)
@@ -66,13 +208,13 @@ func (m *Subscription) StopButton() []*utils.Button {
 
 // Save saves subscription
 func (m *Subscription) Save() {
-	DS.Logger.Info("Saving subscription:", m)
+	logger.Info("Saving subscription:", m)
 	DS.Save(m)
 }
 
 // Delete deletes subscription
 func (m *Subscription) Delete() {
-	DS.Logger.Info("Deleting subscription:", m)
+	logger.Info("Deleting subscription:", m)
 	DS.Delete(m.Key())
 }
 
@@ -94,6 +236,30 @@ func GetUserSubscriptions(uid string) ([]*Subscription, error) {
 
 }
 
+// GetTopicSubscriptions get subscriptions to topic
+func GetTopicSubscriptions(topic *datastore.Key) ([]*Subscription, error) {
+	query := NewBaseQuery(SubscriptionKind, []*Filter{NewFilter("Topic =", topic)})
+	var subs []*Subscription
+
+	keys, err := DS.GetAll(query, &subs)
+	for i, key := range keys {
+		subs[i].SetID(key)
+	}
+	return subs, err
+}
+
+// GetAllSubscriptions get subscriptions of all users
+func GetAllSubscriptions() ([]*Subscription, error) {
+	query := NewBaseQuery(SubscriptionKind, []*Filter{})
+	var subs []*Subscription
+
+	keys, err := DS.GetAll(query, &subs)
+	for i, key := range keys {
+		subs[i].SetID(key)
+	}
+	return subs, err
+}
+
 // GetUnsubscribedTopics get unsubscribed topics
 func GetUnsubscribedTopics(uid string, limit int) []*Topic {
 	ts, _ := GetTopics()
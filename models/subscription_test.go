@@ -2,6 +2,7 @@ package models
 
 import (
 	"testing"
+	"time"
 
 	"github.com/icrowley/fake"
 
@@ -26,3 +27,122 @@ func TestSubscription(t *testing.T) {
 	topics := GetUnsubscribedTopics(bu.ID, 5)
 	assert.True(len(topics) > 0)
 }
+
+func TestGetAllSubscriptions(t *testing.T) {
+	assert := assert.New(t)
+
+	bu := NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	bu.Save()
+	NewSubscription(bu.ID, fake.Word()).Save()
+
+	subs, err := GetAllSubscriptions()
+	assert.NoError(err)
+	assert.NotEmpty(subs)
+}
+
+func TestSubscriptionDigest(t *testing.T) {
+	assert := assert.New(t)
+
+	bu := NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), -5)
+	loc := bu.Location()
+	// wednesday 10:00 utc, 05:00 for the user
+	now := time.Date(2018, 6, 13, 10, 0, 0, 0, time.UTC)
+
+	sub := NewSubscription(bu.ID, fake.Word())
+	assert.True(sub.IsRealtime())
+	assert.False(sub.DigestDue(now, loc))
+
+	sub.Schedule = ScheduleMorning
+	assert.Equal(time.Date(2018, 6, 12, 7, 0, 0, 0, loc), sub.DigestTime(now, loc))
+	assert.Equal(time.Date(2018, 6, 13, 7, 0, 0, 0, loc), sub.DigestTime(now.Add(2*time.Hour), loc))
+
+	sub.Schedule = ScheduleEvening
+	assert.Equal(time.Date(2018, 6, 12, 18, 0, 0, 0, loc), sub.DigestTime(now, loc))
+
+	sub.Schedule = ScheduleWeekly
+	assert.Equal(time.Date(2018, 6, 11, 7, 0, 0, 0, loc), sub.DigestTime(now, loc))
+	monday := time.Date(2018, 6, 18, 6, 0, 0, 0, loc)
+	assert.Equal(time.Date(2018, 6, 11, 7, 0, 0, 0, loc), sub.DigestTime(monday, loc))
+	assert.Equal(monday.Add(time.Hour), sub.DigestTime(monday.Add(time.Hour), loc))
+
+	// the first digest is sent after subscribing
+	sub.Schedule = ScheduleMorning
+	sub.Created = now
+	assert.False(sub.DigestDue(now, loc))
+	assert.True(sub.DigestDue(now.Add(2*time.Hour), loc))
+
+	last := now.Add(2 * time.Hour)
+	sub.LastDigest = &last
+	assert.False(sub.DigestDue(now.Add(3*time.Hour), loc))
+	assert.True(sub.DigestDue(now.Add(26*time.Hour), loc))
+
+	assert.True(IsSchedule(ScheduleWeekly))
+	assert.False(IsSchedule("hourly"))
+
+	// time zones may be offset by fractions of an hour
+	bu.TimeZone = 5.5
+	_, offset := now.In(bu.Location()).Zone()
+	assert.Equal(19800, offset)
+	bu.TimeZone, bu.HourOffset = 0, 3
+	_, offset = now.In(bu.Location()).Zone()
+	assert.Equal(10800, offset)
+}
+
+func TestClaimDigest(t *testing.T) {
+	assert := assert.New(t)
+	now := time.Date(2018, 6, 13, 10, 0, 0, 0, time.UTC)
+
+	sub := NewSubscription(fake.Characters(), fake.Word())
+	sub.Schedule = ScheduleMorning
+	sub.Save()
+	sub.Created = now.Add(-48 * time.Hour)
+	sub.Save()
+	stale := *sub
+
+	ok, err := ClaimDigest(sub, now, time.UTC)
+	assert.NoError(err)
+	assert.True(ok)
+	assert.True(sub.LastDigest.Equal(now))
+
+	// the digest is claimed once, stale copies are reloaded
+	ok, err = ClaimDigest(&stale, now.Add(time.Minute), time.UTC)
+	assert.NoError(err)
+	assert.False(ok)
+	assert.True(stale.LastDigest.Equal(now))
+
+	ok, _ = ClaimDigest(sub, now.Add(24*time.Hour), time.UTC)
+	assert.True(ok)
+}
+
+func TestSubscriptionNotificationToken(t *testing.T) {
+	assert := assert.New(t)
+
+	bu := NewUser(fake.Characters(), fake.FirstName(), fake.LastName(), fake.DomainName(), fake.Language(), fake.Gender(), 0)
+	topic := fake.Word()
+	NewSubscription(bu.ID, topic).Save()
+
+	sub, err := GetUserSubscription(bu.ID, topic)
+	assert.NoError(err)
+	now := time.Now()
+	assert.False(sub.CanNotify(now))
+
+	expiry := now.Add(48 * time.Hour)
+	sub.SetNotificationToken("token", &expiry)
+	sub.Save()
+	sub, _ = GetUserSubscription(bu.ID, topic)
+	assert.Equal("token", sub.NotificationToken)
+	assert.True(sub.CanNotify(now))
+	assert.False(sub.CanNotify(expiry))
+
+	// one notification is sent a day
+	sub.LastNotified = &now
+	assert.False(sub.CanNotify(now.Add(time.Hour)))
+	assert.True(sub.CanNotify(now.Add(notifyInterval)))
+
+	sub.SetNotificationToken("", &expiry)
+	assert.Nil(sub.TokenExpiry)
+	assert.False(sub.CanNotify(now))
+
+	_, err = GetUserSubscription(bu.ID, fake.Word())
+	assert.Error(err)
+}
@@ -25,7 +25,7 @@ func (m *Topic) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(TopicKind)
+		return NewKey(TopicKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
@@ -67,7 +67,7 @@ func GetOrCreateTopic(name string, ts []string) *Topic {
 
 // Save topic
 func (m *Topic) Save() {
-	DS.Logger.Info("Saving topic:", m)
+	logger.Info("Saving topic:", m)
 	DS.Save(m)
 }
 
@@ -79,6 +79,12 @@ func GetTopicKey(name string) *datastore.Key {
 
 // GetTopics get all topics
 func GetTopics() ([]*Topic, error) {
+	topics, err := GetAllTopics()
+	return ShuffleTopics(topics), err
+}
+
+// GetAllTopics get all topics in the order they're stored
+func GetAllTopics() ([]*Topic, error) {
 
 	query := NewBaseQuery(TopicKind, []*Filter{})
 	var topics []*Topic
@@ -88,7 +94,7 @@ func GetTopics() ([]*Topic, error) {
 		topics[i].SetID(key)
 	}
 
-	return ShuffleTopics(topics), err
+	return topics, err
 }
 
 // ShuffleTopics shuffles topics
@@ -30,4 +30,8 @@ func TestTopic(t *testing.T) {
 	topics, err := GetTopics()
 	assert.NoError(err)
 	assert.True(len(topics) > 0)
+
+	all, err := GetAllTopics()
+	assert.NoError(err)
+	assert.ElementsMatch(topics, all)
 }
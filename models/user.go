@@ -12,17 +12,21 @@ const UserKind = "Users"
 // UserActionKind kind name for user actions
 const UserActionKind = "UserActions"
 
-// User represent facebook user
+// User represent facebook user, LastInteraction is the time
+// the user last messaged the page, HourOffset is the whole
+// hour time zone saved in datastore by earlier versions
 type User struct {
-	ID        string    `json:"id" datastore:"-"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Avatar    string    `json:"profile_pic" datastore:",noindex"`
-	Locale    string    `json:"locale" datastore:",noindex"`
-	TimeZone  int32     `json:"timezone" datastore:",noindex"`
-	Gender    string    `json:"gender"`
-	Created   time.Time `json:"created"`
-	Updated   time.Time `json:"updated"`
+	ID              string     `json:"id" datastore:"-"`
+	FirstName       string     `json:"first_name"`
+	LastName        string     `json:"last_name"`
+	Avatar          string     `json:"profile_pic" datastore:",noindex"`
+	Locale          string     `json:"locale" datastore:",noindex"`
+	TimeZone        float64    `json:"timezone" datastore:"UTCOffset,noindex"`
+	HourOffset      int32      `json:"-" datastore:"TimeZone,noindex"`
+	Gender          string     `json:"gender"`
+	LastInteraction *time.Time `json:"last_interaction" datastore:",noindex"`
+	Created         time.Time  `json:"created"`
+	Updated         time.Time  `json:"updated"`
 }
 
 // UserAction record user actions
@@ -40,7 +44,7 @@ func (m *User) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(UserKind)
+		return NewKey(UserKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
@@ -54,7 +58,7 @@ func (m *User) SetID(key *datastore.Key) {
 }
 
 // NewUser returns new model
-func NewUser(id, firstName, lastName, avatar, locale, gender string, timezone int32) *User {
+func NewUser(id, firstName, lastName, avatar, locale, gender string, timezone float64) *User {
 	return &User{
 		ID:        id,
 		FirstName: firstName,
@@ -71,6 +75,21 @@ func (m *User) String() string {
 	return m.FirstName + " " + m.LastName
 }
 
+// Location returns time zone of user from its utc offset in hours,
+// some time zones are offset by fractions of an hour
+func (m *User) Location() *time.Location {
+	tz := m.TimeZone
+	if tz == 0 {
+		tz = float64(m.HourOffset)
+	}
+	return time.FixedZone("", int(tz*3600))
+}
+
+// InWindow reports whether the last interaction of user is within window before now
+func (m *User) InWindow(now time.Time, window time.Duration) bool {
+	return m.LastInteraction != nil && now.Sub(*m.LastInteraction) < window
+}
+
 // GetUser article
 func GetUser(id string) (*User, error) {
 	entity := User{ID: id}
@@ -80,7 +99,7 @@ func GetUser(id string) (*User, error) {
 
 // Save users
 func (m *User) Save() {
-	DS.Logger.Info("Saving user:", m)
+	logger.Info("Saving user:", m)
 	DS.Save(m)
 }
 
@@ -95,14 +114,14 @@ func (m *UserAction) Key() *datastore.Key {
 	// if there is no Id, we want to generate an "incomplete"
 	// one and let datastore determine the key/Id for us
 	if m.ID == "" {
-		return DS.NewKey(UserActionKind)
+		return NewKey(UserActionKind)
 	}
 
 	// if Id is already set, we'll just build the Key based
 	// on the one provided.
 	key, err := datastore.DecodeKey(m.ID)
 	if err != nil {
-		DS.Logger.Error("Key not found:", err)
+		logger.Error("Key not found:", err)
 	}
 	return key
 }
@@ -123,6 +142,6 @@ func NewUserAction(userID string, itemKey *datastore.Key, action string) *UserAc
 
 // Save UserAction
 func (m *UserAction) Save() {
-	DS.Logger.Info("Saving bot user action:", m)
+	logger.Info("Saving bot user action:", m)
 	DS.Save(m)
 }
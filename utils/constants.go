@@ -15,6 +15,8 @@ const (
 	PostBackGetSummary = "Summary"
 	// PostBackShare postback button
 	PostBackShare = "Share"
+	// PostBackOtherSources other sources of a story title
+	PostBackOtherSources = "Other sources"
 	// ActionNewsSearch news search action
 	ActionNewsSearch = "news.search"
 	// ActionNewsSearchNext news search next action
@@ -41,6 +43,42 @@ const (
 	SummaryScore = 0.01
 	// ViewScore scroe for viewing article
 	ViewScore = 0.02
+	// DefaultResponseText response when the input is not understood
+	DefaultResponseText = "Sorry, I didn't get that. You can ask me for news about a topic."
+	// SharedLinkText response for shared articles
+	SharedLinkText = "I've got this article, tap Summary to read a short summary."
+	// UnknownLinkText response for shared links which are not crawled articles
+	UnknownLinkText = "Sorry, I don't know this article yet."
+	// LocationText response for shared locations
+	LocationText = "Thanks! Here are the latest stories."
+	// ImageText response for images and stickers
+	ImageText = "Nice one! Would you like news updates?"
+	// AudioText response for audio clips
+	AudioText = "Sorry, I can't listen to audio yet. Please type your message."
+	// ReferralSubscribeText response when subscribing from a referral
+	ReferralSubscribeText = "Welcome! You'll now get %s news from me."
+	// AccountLinkedText response when user links an account
+	AccountLinkedText = "Your account is now linked."
+	// AccountUnlinkedText response when user unlinks an account
+	AccountUnlinkedText = "Your account has been unlinked."
+	// DigestText intro of scheduled digests
+	DigestText = "Here's your %s news digest 📰"
+	// ActionSchedule changes delivery schedule of subscriptions
+	ActionSchedule = "subscription.schedule"
+	// ScheduleText asks for a delivery schedule
+	ScheduleText = "When would you like to get your news?"
+	// ScheduleSetText response after changing the delivery schedule
+	ScheduleSetText = "Done! I'll send your news %s."
+	// NotifyPayload prefix of payloads of notification requests, followed by the topic
+	NotifyPayload = "notify:"
+	// NotifyTitle title of notification requests of topics
+	NotifyTitle = "Get breaking %s news alerts"
+	// NotifyOptinText response when user opts in to notifications
+	NotifyOptinText = "Thanks! I'll alert you about breaking %s news."
+	// BreakingTitle title of breaking news alerts
+	BreakingTitle = "BREAKING: %s"
+	// OtherSourcesText intro of other sources of a story
+	OtherSourcesText = "%d sources reported this story"
 	// NoSubscriptionText no subs text
 	NoSubscriptionText = "You currently don't have any subscriptions"
 	dev                = "dev"
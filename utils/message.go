@@ -15,15 +15,27 @@ type NotificationType string
 // ContentType for quick replies
 type ContentType string
 
+// MessagingType purpose of sent messages
+type MessagingType string
+
+// MessageTag tag of messages sent outside the standard messaging window
+type MessageTag string
+
+// NotificationFrequency how often notifications may be sent with a token
+type NotificationFrequency string
+
 // Message interface that represents all type of messages that we can send to Facebook Messenger
 type Message interface {
 	serialize()
 	String() string
+	SetMessagingType(t MessagingType, tag MessageTag)
+	SetNotificationToken(token string)
 }
 
-func (m TextMessage) serialize()       {} // Message interface
-func (m GenericMessage) serialize()    {} // Message interface
-func (m QuickReplyMessage) serialize() {} // Message interface
+func (m TextMessage) serialize()                {} // Message interface
+func (m GenericMessage) serialize()             {} // Message interface
+func (m QuickReplyMessage) serialize()          {} // Message interface
+func (m NotificationRequestMessage) serialize() {} // Message interface
 
 const (
 	// ButtonTypeWebURL is type for web links
@@ -52,11 +64,31 @@ const (
 
 	// QuickReplyTextType quick reply text type
 	QuickReplyTextType = ContentType("text")
+
+	// MessagingTypeResponse for replies to messages of users
+	MessagingTypeResponse = MessagingType("RESPONSE")
+
+	// MessagingTypeUpdate for proactive messages within the standard messaging window
+	MessagingTypeUpdate = MessagingType("UPDATE")
+
+	// MessagingTypeMessageTag for tagged messages outside the standard messaging window
+	MessagingTypeMessageTag = MessagingType("MESSAGE_TAG")
+
+	// TemplateTypeNotificationMessages for notification request templates
+	TemplateTypeNotificationMessages = TemplateType("notification_messages")
+
+	// NotificationFrequencyDaily one notification every day
+	NotificationFrequencyDaily = NotificationFrequency("DAILY")
+
+	// NotificationFrequencyWeekly one notification every week
+	NotificationFrequencyWeekly = NotificationFrequency("WEEKLY")
 )
 
-// Recipient represents facebook recipient
+// Recipient represents facebook recipient, messages sent with
+// a notification token have no recipient id
 type Recipient struct {
-	ID string `json:"id"`
+	ID                string `json:"id,omitempty"`
+	NotificationToken string `json:"notification_messages_token,omitempty"`
 }
 
 // TextMessage struct used for sending text messages to messenger
@@ -64,6 +96,8 @@ type TextMessage struct {
 	Message          textMessageContent `json:"message"`
 	Recipient        Recipient          `json:"recipient"`
 	NotificationType NotificationType   `json:"notification_type,omitempty"`
+	MessagingType    MessagingType      `json:"messaging_type,omitempty"`
+	Tag              MessageTag         `json:"tag,omitempty"`
 }
 
 // GenericMessage struct used for sending structural messages to messenger (messages with images, links, and buttons)
@@ -71,12 +105,42 @@ type GenericMessage struct {
 	Message          genericMessageContent `json:"message"`
 	Recipient        Recipient             `json:"recipient"`
 	NotificationType NotificationType      `json:"notification_type,omitempty"`
+	MessagingType    MessagingType         `json:"messaging_type,omitempty"`
+	Tag              MessageTag            `json:"tag,omitempty"`
 }
 
 // QuickReplyMessage struct used for sending quick replies
 type QuickReplyMessage struct {
-	Message   quickReplyMessageContent `json:"message"`
-	Recipient Recipient                `json:"recipient"`
+	Message       quickReplyMessageContent `json:"message"`
+	Recipient     Recipient                `json:"recipient"`
+	MessagingType MessagingType            `json:"messaging_type,omitempty"`
+	Tag           MessageTag               `json:"tag,omitempty"`
+}
+
+// NotificationRequestMessage asks user for permission to send
+// notifications outside the standard messaging window
+type NotificationRequestMessage struct {
+	Message       notificationRequestContent `json:"message"`
+	Recipient     Recipient                  `json:"recipient"`
+	MessagingType MessagingType              `json:"messaging_type,omitempty"`
+	Tag           MessageTag                 `json:"tag,omitempty"`
+}
+
+type notificationRequestContent struct {
+	Attachment notificationAttachment `json:"attachment"`
+}
+
+type notificationAttachment struct {
+	Type    string              `json:"type"`
+	Payload notificationPayload `json:"payload"`
+}
+
+type notificationPayload struct {
+	TemplateType TemplateType          `json:"template_type"`
+	Title        string                `json:"title"`
+	ImageURL     string                `json:"image_url,omitempty"`
+	Payload      string                `json:"payload"`
+	Frequency    NotificationFrequency `json:"notification_messages_frequency"`
 }
 
 type quickReplyMessageContent struct {
@@ -139,6 +203,61 @@ func (m *QuickReplyMessage) String() string {
 	return m.Message.Text
 }
 
+func (m *NotificationRequestMessage) String() string {
+	return m.Message.Attachment.Payload.Title
+}
+
+// SetMessagingType sets messaging type and tag of message,
+// tag is only used with MessagingTypeMessageTag
+func (m *TextMessage) SetMessagingType(t MessagingType, tag MessageTag) {
+	m.MessagingType, m.Tag = t, messageTag(t, tag)
+}
+
+// SetMessagingType sets messaging type and tag of message,
+// tag is only used with MessagingTypeMessageTag
+func (m *GenericMessage) SetMessagingType(t MessagingType, tag MessageTag) {
+	m.MessagingType, m.Tag = t, messageTag(t, tag)
+}
+
+// SetMessagingType sets messaging type and tag of message,
+// tag is only used with MessagingTypeMessageTag
+func (m *QuickReplyMessage) SetMessagingType(t MessagingType, tag MessageTag) {
+	m.MessagingType, m.Tag = t, messageTag(t, tag)
+}
+
+// SetMessagingType sets messaging type and tag of message,
+// tag is only used with MessagingTypeMessageTag
+func (m *NotificationRequestMessage) SetMessagingType(t MessagingType, tag MessageTag) {
+	m.MessagingType, m.Tag = t, messageTag(t, tag)
+}
+
+// SetNotificationToken sends message with notification token instead of the recipient id
+func (m *TextMessage) SetNotificationToken(token string) {
+	m.Recipient = Recipient{NotificationToken: token}
+}
+
+// SetNotificationToken sends message with notification token instead of the recipient id
+func (m *GenericMessage) SetNotificationToken(token string) {
+	m.Recipient = Recipient{NotificationToken: token}
+}
+
+// SetNotificationToken sends message with notification token instead of the recipient id
+func (m *QuickReplyMessage) SetNotificationToken(token string) {
+	m.Recipient = Recipient{NotificationToken: token}
+}
+
+// SetNotificationToken sends message with notification token instead of the recipient id
+func (m *NotificationRequestMessage) SetNotificationToken(token string) {
+	m.Recipient = Recipient{NotificationToken: token}
+}
+
+func messageTag(t MessagingType, tag MessageTag) MessageTag {
+	if t != MessagingTypeMessageTag {
+		return ""
+	}
+	return tag
+}
+
 // AddNewElement adds element to Generic template message with defined title, subtitle, link url and image url
 // Title param is mandatory. If not used set "" for other params and nil for buttons param
 // Generic messages can have up to 10 elements which are scolled horizontaly in Facebook messenger
@@ -217,8 +336,9 @@ func (e *Element) AddShareButton() {
 // probably use shorthand version SentTextMessage which sends message immediatly
 func NewTextMessage(userID string, text string) *TextMessage {
 	return &TextMessage{
-		Recipient: Recipient{ID: userID},
-		Message:   textMessageContent{Text: text},
+		Recipient:     Recipient{ID: userID},
+		Message:       textMessageContent{Text: text},
+		MessagingType: MessagingTypeResponse,
 	}
 }
 
@@ -226,7 +346,8 @@ func NewTextMessage(userID string, text string) *TextMessage {
 // Generic template messages are used for structured messages with images, links, buttons and postbacks
 func NewGenericMessage(userID string) *GenericMessage {
 	return &GenericMessage{
-		Recipient: Recipient{ID: userID},
+		Recipient:     Recipient{ID: userID},
+		MessagingType: MessagingTypeResponse,
 		Message: genericMessageContent{
 			Attachment: &attachment{
 				Type:    "template",
@@ -236,10 +357,31 @@ func NewGenericMessage(userID string) *GenericMessage {
 	}
 }
 
+// NewNotificationRequest creates a new notification request message for userID,
+// payload is sent back with the notification token when the user opts in
+func NewNotificationRequest(userID, title, payload string, frequency NotificationFrequency) *NotificationRequestMessage {
+	return &NotificationRequestMessage{
+		Recipient:     Recipient{ID: userID},
+		MessagingType: MessagingTypeResponse,
+		Message: notificationRequestContent{
+			Attachment: notificationAttachment{
+				Type: string(AttachmentTypeTemplate),
+				Payload: notificationPayload{
+					TemplateType: TemplateTypeNotificationMessages,
+					Title:        title,
+					Payload:      payload,
+					Frequency:    frequency,
+				},
+			},
+		},
+	}
+}
+
 // NewQuickReply creates a new quick reply message for userID
 func NewQuickReply(userID, text string) *QuickReplyMessage {
 	return &QuickReplyMessage{
-		Recipient: Recipient{ID: userID},
+		Recipient:     Recipient{ID: userID},
+		MessagingType: MessagingTypeResponse,
 		Message: quickReplyMessageContent{
 			Text:         text,
 			QuickReplies: []quickReply{},
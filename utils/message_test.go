@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -60,3 +61,33 @@ func TestElement(t *testing.T) {
 	m.AddPostbackButton(pb.Title, pb.Payload)
 	assert.Contains(m.Buttons, pb)
 }
+
+func TestMessagingType(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewQuickReply("id", "Hi")
+	assert.Equal(MessagingTypeResponse, m.MessagingType)
+
+	tag := MessageTag("ACCOUNT_UPDATE")
+	m.SetMessagingType(MessagingTypeUpdate, tag)
+	assert.Equal(MessagingTypeUpdate, m.MessagingType)
+	assert.Empty(m.Tag)
+
+	m.SetMessagingType(MessagingTypeMessageTag, tag)
+	assert.Equal(tag, m.Tag)
+}
+
+func TestNotificationRequest(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewNotificationRequest("id", "Get news alerts", "notify:sports", NotificationFrequencyDaily)
+	assert.Equal("Get news alerts", m.String())
+	bs, err := json.Marshal(m)
+	assert.NoError(err)
+	assert.Contains(string(bs), `"template_type":"notification_messages"`)
+	assert.Contains(string(bs), `"notification_messages_frequency":"DAILY"`)
+
+	m.SetNotificationToken("token")
+	bs, _ = json.Marshal(m)
+	assert.Contains(string(bs), `"recipient":{"notification_messages_token":"token"}`)
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"hash/fnv"
+	"math"
+)
+
+const (
+	// SignatureSize number of hashes of minhash signatures
+	SignatureSize = 64
+	// ShingleSize number of tokens of text shingles
+	ShingleSize = 2
+)
+
+// Shingles returns set of shingles of n consecutive tokens,
+// texts shorter than n tokens are a single shingle
+func Shingles(tokens []string, n int) []string {
+	if len(tokens) == 0 {
+		return []string{}
+	}
+	if len(tokens) < n {
+		n = len(tokens)
+	}
+	shingles := make([]string, 0, len(tokens)-n+1)
+	for i := 0; i+n <= len(tokens); i++ {
+		s := tokens[i]
+		for _, t := range tokens[i+1 : i+n] {
+			s += " " + t
+		}
+		shingles = append(shingles, s)
+	}
+	return SliceUniqMap(shingles)
+}
+
+// MinHash returns minhash signature of set of shingles, equal values
+// of two signatures estimate the jaccard similarity of their sets
+func MinHash(shingles []string) []int64 {
+	mins := make([]uint64, SignatureSize)
+	for i := range mins {
+		mins[i] = math.MaxUint64
+	}
+	for _, s := range shingles {
+		h := fnv.New64a()
+		h.Write([]byte(s))
+		x := h.Sum64()
+		for i := range mins {
+			if v := mix(x ^ uint64(i+1)*0x9e3779b97f4a7c15); v < mins[i] {
+				mins[i] = v
+			}
+		}
+	}
+	sig := make([]int64, SignatureSize)
+	for i, v := range mins {
+		sig[i] = int64(v)
+	}
+	return sig
+}
+
+// mix splitmix64 finalizer, makes hashes of the same shingle independent
+func mix(x uint64) uint64 {
+	x = (x ^ (x >> 30)) * 0xbf58476d1ce4e5b9
+	x = (x ^ (x >> 27)) * 0x94d049bb133111eb
+	return x ^ (x >> 31)
+}
+
+// SignatureSimilarity returns estimated jaccard similarity of minhash signatures
+func SignatureSimilarity(a, b []int64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+	equal := 0
+	for i := range a {
+		if a[i] == b[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(len(a))
+}
+
+// Jaccard returns jaccard similarity of sets of words
+func Jaccard(a, b []string) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	set := map[string]bool{}
+	for _, w := range a {
+		set[w] = true
+	}
+	common, seen := 0, map[string]bool{}
+	for _, w := range b {
+		if seen[w] {
+			continue
+		}
+		seen[w] = true
+		if set[w] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(set)+len(seen)-common)
+}
@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestShingles(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.ElementsMatch([]string{"flood hit", "hit accra"}, Shingles([]string{"flood", "hit", "accra"}, 2))
+	assert.Equal([]string{"flood"}, Shingles([]string{"flood"}, 2))
+	assert.Empty(Shingles([]string{}, 2))
+}
+
+func TestMinHash(t *testing.T) {
+	assert := assert.New(t)
+
+	text := "Heavy rains caused floods in parts of Accra on Monday, leaving many residents stranded as roads were cut off"
+	a := MinHash(Shingles(Tokenize(text), ShingleSize))
+	assert.Len(a, SignatureSize)
+	assert.Equal(a, MinHash(Shingles(Tokenize(text), ShingleSize)))
+	assert.Equal(1.0, SignatureSimilarity(a, a))
+
+	b := MinHash(Shingles(Tokenize(text+" and the national disaster agency sent relief teams"), ShingleSize))
+	sim := SignatureSimilarity(a, b)
+	assert.True(sim > 0.4 && sim < 1, "similarity %v", sim)
+
+	c := MinHash(Shingles(Tokenize("The Black Stars beat Nigeria two goals to one in the qualifier"), ShingleSize))
+	assert.True(SignatureSimilarity(a, c) < 0.2)
+	assert.Equal(0.0, SignatureSimilarity(a, nil))
+
+	ta := NewTextAnalysis(text, "")
+	assert.Equal(1.0, SignatureSimilarity(ta.Signature("Floods in Accra"), ta.Signature("Floods in Accra")))
+}
+
+func TestJaccard(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(0.5, Jaccard([]string{"flood", "accra", "flood"}, []string{"accra", "rain", "flood", "kumasi"}))
+	assert.Equal(0.0, Jaccard(nil, []string{"flood"}))
+}
@@ -0,0 +1,268 @@
+package utils
+
+import (
+	"encoding/json"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"golang.org/x/net/html"
+)
+
+const (
+	// minParagraphLength minimum length of scored paragraphs
+	minParagraphLength = 25
+	// minContentLength minimum length of extracted content
+	minContentLength = 140
+	// minTextDensity characters per element below which blocks are penalized
+	minTextDensity = 25.0
+	// maxLinkDensity maximum share of link text of extracted paragraphs
+	maxLinkDensity = 0.33
+)
+
+var (
+	unlikelyContent = regexp.MustCompile(`(?i)comment|sidebar|footer|menu|nav|share|social|related|promo|advert|banner|popup|subscribe|newsletter|cookie|widget`)
+	likelyContent   = regexp.MustCompile(`(?i)article|body|content|entry|main|post|story|text`)
+	// datetime layouts of published dates of articles
+	dateLayouts = []string{time.RFC3339, "2006-01-02T15:04:05Z0700", "2006-01-02T15:04:05", "2006-01-02 15:04:05", "2006-01-02"}
+)
+
+// ExtractContent returns main text of html page, blocks are scored by the length
+// and commas of their paragraphs and their class names, and reduced by their link
+// and text density. An empty string is returned when no content is found
+func ExtractContent(doc *goquery.Document) string {
+	body := doc.Find("body")
+	if body.Length() == 0 {
+		body = doc.Selection
+	}
+	body = body.Clone()
+	body.Find("script,style,noscript,iframe,form,nav,header,footer,aside,button").Remove()
+	body.Find("*").Each(func(i int, s *goquery.Selection) {
+		names := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+		if unlikelyContent.MatchString(names) && !likelyContent.MatchString(names) && !s.Is("article,main") {
+			s.Remove()
+		}
+	})
+
+	scores := map[*html.Node]float64{}
+	var candidates []*goquery.Selection
+	body.Find("p,pre,td").Each(func(i int, p *goquery.Selection) {
+		text := strings.TrimSpace(p.Text())
+		if len(text) < minParagraphLength {
+			return
+		}
+		score := 1 + float64(strings.Count(text, ",")) + math.Min(float64(len(text))/100, 3)
+		// parents get the score of their paragraphs, grand parents half of it
+		for level, s := range []*goquery.Selection{p.Parent(), p.Parent().Parent()} {
+			if s.Length() == 0 {
+				continue
+			}
+			n := s.Get(0)
+			if _, ok := scores[n]; !ok {
+				scores[n] = classWeight(s)
+				candidates = append(candidates, s)
+			}
+			scores[n] += score / float64(level+1)
+		}
+	})
+
+	var top *goquery.Selection
+	best := 0.0
+	for _, s := range candidates {
+		n := s.Get(0)
+		scores[n] *= (1 - linkDensity(s)) * math.Min(1, textDensity(s)/minTextDensity)
+		if scores[n] > best {
+			top, best = s, scores[n]
+		}
+	}
+	if top == nil {
+		return ""
+	}
+
+	// siblings of the top block with a good score are part of the content
+	threshold := math.Max(10, best*0.2)
+	siblings := top.Parent().Children()
+	if siblings.Length() == 0 {
+		siblings = top
+	}
+	var paragraphs []string
+	siblings.Each(func(i int, s *goquery.Selection) {
+		n := s.Get(0)
+		if n != top.Get(0) && scores[n] < threshold && !s.Is("p") {
+			return
+		}
+		blocks := s.Find("p,pre")
+		if s.Is("p,pre") || blocks.Length() == 0 {
+			blocks = s
+		}
+		blocks.Each(func(i int, p *goquery.Selection) {
+			text := strings.TrimSpace(p.Text())
+			if text != "" && linkDensity(p) <= maxLinkDensity && (n == top.Get(0) || len(text) >= minParagraphLength) {
+				paragraphs = append(paragraphs, text)
+			}
+		})
+	})
+	content := strings.Join(paragraphs, "\n")
+	if len(content) < minContentLength {
+		return ""
+	}
+	return content
+}
+
+// classWeight scores class names and id of element
+func classWeight(s *goquery.Selection) float64 {
+	names := s.AttrOr("class", "") + " " + s.AttrOr("id", "")
+	weight := 0.0
+	if likelyContent.MatchString(names) || s.Is("article,main") {
+		weight += 25
+	}
+	if unlikelyContent.MatchString(names) {
+		weight -= 25
+	}
+	return weight
+}
+
+// linkDensity returns share of text of element in links
+func linkDensity(s *goquery.Selection) float64 {
+	length := len(strings.TrimSpace(s.Text()))
+	if length == 0 {
+		return 0
+	}
+	links := 0
+	s.Find("a").Each(func(i int, a *goquery.Selection) {
+		links += len(strings.TrimSpace(a.Text()))
+	})
+	return math.Min(1, float64(links)/float64(length))
+}
+
+// textDensity returns characters of text of element per descendant element
+func textDensity(s *goquery.Selection) float64 {
+	return float64(len(strings.TrimSpace(s.Text()))) / float64(s.Find("*").Length()+1)
+}
+
+// ArticleMeta lead image, byline and published date of an article page
+type ArticleMeta struct {
+	Image     string
+	Author    string
+	Published *time.Time
+}
+
+// ExtractArticleMeta extracts metadata of article page from its JSON-LD
+// article and its og: and article: meta tags, relative image urls
+// are resolved with the url of the document
+func ExtractArticleMeta(doc *goquery.Document) *ArticleMeta {
+	am := &ArticleMeta{}
+	og, _ := ExtractMetaTags(doc, "og:")
+	article, _ := ExtractMetaTags(doc, "article:")
+	ld := jsonLDArticle(doc)
+
+	am.Image, _ = og.Get("image", "").(string)
+	if am.Image == "" {
+		am.Image = jsonLDString(ld["image"], "url")
+	}
+	am.Author = jsonLDString(ld["author"], "name")
+	if am.Author == "" {
+		// article:author is often the url of the author page
+		if author, _ := article.Get("author", "").(string); !strings.HasPrefix(author, "http") {
+			am.Author = author
+		}
+	}
+	if am.Author == "" {
+		am.Author = strings.TrimSpace(doc.Find(`meta[name="author"]`).AttrOr("content", ""))
+	}
+	published := jsonLDString(ld["datePublished"], "")
+	if published == "" {
+		published, _ = article.Get("published_time", "").(string)
+	}
+	am.Published = ParseDate(published)
+
+	if am.Image != "" && doc.Url != nil {
+		if u, err := doc.Url.Parse(am.Image); err == nil {
+			am.Image = u.String()
+		}
+	}
+	return am
+}
+
+// jsonLDArticle returns the first article object of JSON-LD scripts of page
+func jsonLDArticle(doc *goquery.Document) map[string]interface{} {
+	var article map[string]interface{}
+	doc.Find(`script[type="application/ld+json"]`).EachWithBreak(func(i int, s *goquery.Selection) bool {
+		var data interface{}
+		if err := json.Unmarshal([]byte(s.Text()), &data); err != nil {
+			return true
+		}
+		for _, obj := range jsonLDObjects(data) {
+			if isArticleType(obj["@type"]) {
+				article = obj
+				return false
+			}
+		}
+		return true
+	})
+	return article
+}
+
+// jsonLDObjects flattens lists and graphs of JSON-LD data
+func jsonLDObjects(data interface{}) []map[string]interface{} {
+	var objects []map[string]interface{}
+	switch v := data.(type) {
+	case []interface{}:
+		for _, item := range v {
+			objects = append(objects, jsonLDObjects(item)...)
+		}
+	case map[string]interface{}:
+		objects = append(objects, v)
+		if graph, ok := v["@graph"]; ok {
+			objects = append(objects, jsonLDObjects(graph)...)
+		}
+	}
+	return objects
+}
+
+func isArticleType(t interface{}) bool {
+	switch v := t.(type) {
+	case string:
+		return strings.HasSuffix(v, "Article") || v == "BlogPosting"
+	case []interface{}:
+		for _, item := range v {
+			if isArticleType(item) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// jsonLDString returns JSON-LD value as string, key of objects
+// or the first value of lists is used
+func jsonLDString(v interface{}, key string) string {
+	switch value := v.(type) {
+	case string:
+		return strings.TrimSpace(value)
+	case map[string]interface{}:
+		if key != "" {
+			return jsonLDString(value[key], "")
+		}
+	case []interface{}:
+		for _, item := range value {
+			if s := jsonLDString(item, key); s != "" {
+				return s
+			}
+		}
+	}
+	return ""
+}
+
+// ParseDate parses published date of article, nil is returned when it's invalid
+func ParseDate(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
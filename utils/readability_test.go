@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+const testArticlePage = `<html><head>
+<meta property="og:title" content="Floods hit Accra">
+<meta property="article:author" content="https://example.com/authors/ama">
+<meta property="article:published_time" content="2018-06-19T08:30:00+00:00">
+<script type="application/ld+json">{"@context": "https://schema.org", "@graph": [
+	{"@type": "WebSite", "name": "Example"},
+	{"@type": "NewsArticle", "headline": "Floods hit Accra", "image": [{"@type": "ImageObject", "url": "/img/floods.jpg"}],
+	 "author": {"@type": "Person", "name": "Ama Mensah"}, "datePublished": "2018-06-19T08:00:00Z"}
+]}</script>
+</head><body>
+<div id="nav"><ul><li><a href="/">Home</a></li><li><a href="/news">News</a></li><li><a href="/sports">Sports</a></li></ul></div>
+<div id="medsection1">
+	<h1>Floods hit Accra</h1>
+	<p>Heavy rains on Monday night flooded several parts of Accra, leaving many residents stranded.</p>
+	<p>The National Disaster Management Organisation said, in a statement, that rescue teams had been deployed to the worst affected areas.</p>
+	<p>Residents of Kaneshie, Odawna and Adabraka, who lost their belongings, called on the government to clear the drains.</p>
+	<p class="share"><a href="/share">Share this article on Facebook and Twitter now</a></p>
+</div>
+<div class="sidebar"><p>Read also: <a href="/1">Ghana beat Nigeria in a thrilling encounter at Accra</a></p></div>
+<div class="comments"><p>Great article, thanks for sharing this with us all.</p></div>
+</body></html>`
+
+func TestExtractContent(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(testArticlePage))
+	assert.NoError(err)
+
+	content := ExtractContent(doc)
+	assert.True(strings.HasPrefix(content, "Heavy rains on Monday night"), content)
+	assert.Contains(content, "clear the drains")
+	assert.NotContains(content, "Share this article")
+	assert.NotContains(content, "Ghana beat Nigeria")
+	assert.NotContains(content, "Great article")
+	// the document is not changed
+	assert.Equal(1, doc.Find(".comments").Length())
+
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<html><body><div><a href="/">Home</a></div><p>Page not found</p></body></html>`))
+	assert.Equal("", ExtractContent(doc))
+}
+
+func TestExtractArticleMeta(t *testing.T) {
+	assert := assert.New(t)
+
+	doc, _ := goquery.NewDocumentFromReader(strings.NewReader(testArticlePage))
+	doc.Url, _ = url.Parse("https://example.com/news/floods")
+
+	am := ExtractArticleMeta(doc)
+	assert.Equal("https://example.com/img/floods.jpg", am.Image)
+	assert.Equal("Ama Mensah", am.Author)
+	if assert.NotNil(am.Published) {
+		assert.True(am.Published.Equal(time.Date(2018, 6, 19, 8, 0, 0, 0, time.UTC)))
+	}
+
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<html><head>
+		<meta property="og:image" content="https://cdn.example.com/lead.jpg">
+		<meta property="article:author" content="Kofi Boateng">
+		<meta property="article:published_time" content="2018-06-19">
+		</head><body></body></html>`))
+	am = ExtractArticleMeta(doc)
+	assert.Equal("https://cdn.example.com/lead.jpg", am.Image)
+	assert.Equal("Kofi Boateng", am.Author)
+	assert.NotNil(am.Published)
+
+	doc, _ = goquery.NewDocumentFromReader(strings.NewReader(`<html><body><p>No metadata</p></body></html>`))
+	assert.Equal(&ArticleMeta{}, ExtractArticleMeta(doc))
+}
@@ -1,9 +1,12 @@
 package utils
 
 import (
+	"strings"
 	"time"
+	"unicode"
 
 	textrank "github.com/DavidBelicza/TextRank"
+	porterstemmer "github.com/blevesearch/go-porterstemmer"
 	"github.com/jdkato/prose/summarize"
 )
 
@@ -11,11 +14,26 @@ const (
 	wordsPerMinute = 200
 )
 
+// stopWords common words which are not indexed for search
+var stopWords = map[string]bool{
+	"a": true, "about": true, "after": true, "all": true, "also": true, "an": true,
+	"and": true, "any": true, "are": true, "as": true, "at": true, "be": true,
+	"been": true, "but": true, "by": true, "can": true, "for": true, "from": true,
+	"has": true, "have": true, "he": true, "her": true, "his": true, "how": true,
+	"i": true, "in": true, "into": true, "is": true, "it": true, "its": true,
+	"me": true, "my": true, "news": true, "no": true, "not": true, "of": true,
+	"on": true, "or": true, "our": true, "she": true, "so": true, "that": true,
+	"the": true, "their": true, "them": true, "there": true, "they": true, "this": true,
+	"to": true, "up": true, "was": true, "we": true, "were": true, "what": true,
+	"when": true, "which": true, "who": true, "will": true, "with": true, "you": true,
+}
+
 // TextAnalysis text analysis struct
 type TextAnalysis struct {
 	Text        *textrank.TextRank
 	Description *textrank.TextRank
 	Doc         *summarize.Document
+	Tokens      []string
 }
 
 func rankText(t string) *textrank.TextRank {
@@ -42,6 +60,7 @@ func NewTextAnalysis(text, desc string) *TextAnalysis {
 		Text:        rankText(text),
 		Description: rankText(desc),
 		Doc:         summarize.NewDocument(text),
+		Tokens:      Tokenize(text),
 	}
 
 	return ta
@@ -71,6 +90,14 @@ func (t *TextAnalysis) Sentences(length int) []string {
 	return TrimSpacesList(out)
 }
 
+// Signature returns minhash signature of the title
+// words and text shingles, see SignatureSimilarity
+func (t *TextAnalysis) Signature(title string) []int64 {
+	shingles := Shingles(t.Tokens, ShingleSize)
+	shingles = append(shingles, Tokenize(title)...)
+	return MinHash(shingles)
+}
+
 // ReadingTime estimates how long an article will take to read
 // based on 200 words per minutes
 func (t *TextAnalysis) ReadingTime() *time.Duration {
@@ -78,3 +105,33 @@ func (t *TextAnalysis) ReadingTime() *time.Duration {
 	rtime := time.Second * time.Duration(minutes)
 	return &rtime
 }
+
+// Words splits text into lower cased words
+func Words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// IsStopWord checks if word is a common word without meaning on its own
+func IsStopWord(word string) bool {
+	return stopWords[word]
+}
+
+// Stem returns the stem of a lower cased word
+func Stem(word string) string {
+	return porterstemmer.StemString(word)
+}
+
+// Tokenize splits text into lower cased and stemmed search terms,
+// stop words and single characters are dropped
+func Tokenize(text string) []string {
+	terms := []string{}
+	for _, word := range Words(text) {
+		if len(word) < 2 || stopWords[word] {
+			continue
+		}
+		terms = append(terms, Stem(word))
+	}
+	return terms
+}
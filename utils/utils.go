@@ -9,7 +9,9 @@ import (
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
 	"strings"
 	"time"
 
@@ -162,6 +164,22 @@ func FileExists(dir string) bool {
 	return !info.IsDir()
 }
 
+// RepoPath resolves path of a file of the repository, relative paths which don't
+// exist in the working directory are resolved with the source directory
+func RepoPath(path string) string {
+	if filepath.IsAbs(path) || FileExists(path) {
+		return path
+	}
+	_, file, _, ok := runtime.Caller(0)
+	if !ok {
+		return path
+	}
+	if p := filepath.Join(filepath.Dir(filepath.Dir(file)), path); FileExists(p) {
+		return p
+	}
+	return path
+}
+
 // Urlencode is a helper method that converts a map into URL-encoded form data.
 // It is a useful when constructing HTTP POST requests.
 func Urlencode(data map[string]string) string {
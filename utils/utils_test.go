@@ -22,6 +22,11 @@ func TestFileFunctions(t *testing.T) {
 	assert.True(f)
 	f = FileExists("none.go")
 	assert.False(f)
+
+	// files of the repository are found outside its root
+	assert.Equal("utils.go", RepoPath("utils.go"))
+	assert.True(FileExists(RepoPath("crawler/sources.yml")))
+	assert.Equal("none.yml", RepoPath("none.yml"))
 }
 
 func TestURLFunctions(t *testing.T) {
@@ -130,3 +135,15 @@ func TestTextAnalysis(t *testing.T) {
 	tags := ta.Tags()
 	assert.Contains(tags, "lorem")
 }
+
+func TestTokenize(t *testing.T) {
+	assert := assert.New(t)
+
+	terms := Tokenize("The Black Stars are playing in Kumasi, news at 10!")
+	assert.Equal([]string{"black", "star", "plai", "kumasi", "10"}, terms)
+	assert.Empty(Tokenize("the news"))
+
+	assert.Equal([]string{"show", "me", "bbc", "news"}, Words("Show me BBC-news"))
+	assert.True(IsStopWord("the"))
+	assert.Equal("plai", Stem("playing"))
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"crypto/subtle"
+	"expvar"
+	"os"
+	"strconv"
+
+	"github.com/epigos/newsbot/models"
+)
+
+// crawlRunsLimit number of crawl runs per page of admin
+const crawlRunsLimit = 20
+
+// Admin handles requests to admin url path, requests must be
+// authenticated with ADMIN_USER and ADMIN_PASSWORD
+func (s *Server) Admin(path string, handler httpHandler, methods ...string) {
+	s.Handle(path, func(ctx *Context) *HTTPError {
+		if !isAdmin(ctx) {
+			ctx.SetHeader("WWW-Authenticate", `Basic realm="admin"`, true)
+			ctx.Unauthorized()
+			return nil
+		}
+		return handler(ctx)
+	}, methods...)
+}
+
+// isAdmin checks basic auth credentials of request,
+// admin urls are disabled when ADMIN_PASSWORD is not set
+func isAdmin(ctx *Context) bool {
+	password := os.Getenv("ADMIN_PASSWORD")
+	if password == "" {
+		return false
+	}
+	u, p, err := ctx.GetBasicAuth()
+	if err != nil {
+		return false
+	}
+	userOK := subtle.ConstantTimeCompare([]byte(u), []byte(os.Getenv("ADMIN_USER"))) == 1
+	passwordOK := subtle.ConstantTimeCompare([]byte(p), []byte(password)) == 1
+	return userOK && passwordOK
+}
+
+// metricsView handler for runtime metrics published with expvar
+func metricsView(ctx *Context) *HTTPError {
+	expvar.Handler().ServeHTTP(ctx, ctx.Request())
+	return nil
+}
+
+// crawlRunsView handler for the latest crawl runs and health of spiders
+func crawlRunsView(ctx *Context) *HTTPError {
+	page, _ := strconv.Atoi(ctx.GetQuery().Get("page"))
+	runs, err := models.GetCrawlRuns(crawlRunsLimit, page)
+	if err != nil {
+		return ctx.ServerError(err)
+	}
+	return ctx.WriteJSON(map[string]interface{}{
+		"spiders": models.LatestSpiderRuns(runs),
+		"runs":    runs,
+	})
+}
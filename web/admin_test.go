@@ -0,0 +1,68 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/epigos/newsbot/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdmin(t *testing.T) {
+	assert := assert.New(t)
+	defer os.Unsetenv("ADMIN_USER")
+	defer os.Unsetenv("ADMIN_PASSWORD")
+
+	newContext := func(user, password string) *Context {
+		req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+		if user != "" {
+			req.SetBasicAuth(user, password)
+		}
+		return NewContext(httptest.NewRecorder(), req, srv)
+	}
+
+	// admin urls are disabled without a password
+	assert.False(isAdmin(newContext("", "")))
+	assert.False(isAdmin(newContext("admin", "")))
+
+	os.Setenv("ADMIN_USER", "admin")
+	os.Setenv("ADMIN_PASSWORD", "secret")
+	assert.False(isAdmin(newContext("", "")))
+	assert.False(isAdmin(newContext("admin", "wrong")))
+	assert.False(isAdmin(newContext("root", "secret")))
+	assert.True(isAdmin(newContext("admin", "secret")))
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/metrics", nil)
+	metricsView(NewContext(rec, req, srv))
+	assert.Equal(http.StatusOK, rec.Code)
+	assert.Contains(rec.Body.String(), "memstats")
+}
+
+func TestCrawlRunsView(t *testing.T) {
+	assert := assert.New(t)
+
+	run := &models.CrawlRun{Started: time.Now(), Spiders: []models.SpiderRun{
+		{Name: "citinewsroom", Fetched: 2, Saved: 2},
+		{Name: "myjoyonline", Feeds: []models.FeedRun{{Status: models.FeedError, Error: "503"}}},
+	}}
+	run.Save()
+
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/admin/crawl_runs", nil)
+	crawlRunsView(NewContext(rec, req, srv))
+	assert.Equal(http.StatusOK, rec.Code)
+
+	var res struct {
+		Spiders map[string]models.SpiderRun `json:"spiders"`
+		Runs    []*models.CrawlRun          `json:"runs"`
+	}
+	assert.NoError(json.Unmarshal(rec.Body.Bytes(), &res))
+	assert.NotEmpty(res.Runs)
+	assert.True(res.Spiders["citinewsroom"].Healthy())
+	assert.False(res.Spiders["myjoyonline"].Healthy())
+}
@@ -2,6 +2,9 @@ package web
 
 import (
 	"context"
+	"database/sql"
+
+	"github.com/epigos/newsbot/models"
 
 	"cloud.google.com/go/datastore"
 	"golang.org/x/crypto/acme/autocert"
@@ -9,6 +12,7 @@ import (
 
 const (
 	certsKind = "Certs"
+	certsDir  = "certs"
 )
 
 type (
@@ -19,6 +23,10 @@ type (
 	DatastoreCertCache struct {
 		client *datastore.Client
 	}
+	// SQLCertCache sql database cache
+	SQLCertCache struct {
+		store *models.SQLStore
+	}
 )
 
 // NewDatastoreCertCache returns new datastore cache
@@ -28,6 +36,17 @@ func NewDatastoreCertCache(client *datastore.Client) *DatastoreCertCache {
 	}
 }
 
+// newCertCache returns cert cache for the configured storage backend
+func newCertCache() autocert.Cache {
+	switch ds := models.DS.(type) {
+	case *models.DataStore:
+		return NewDatastoreCertCache(ds.Client)
+	case *models.SQLStore:
+		return NewSQLCertCache(ds)
+	}
+	return autocert.DirCache(certsDir)
+}
+
 // Get get cache data
 func (d *DatastoreCertCache) Get(ctx context.Context, key string) ([]byte, error) {
 	var cert letsEncryptCert
@@ -61,3 +80,36 @@ func (d *DatastoreCertCache) Delete(ctx context.Context, key string) error {
 	k := datastore.NameKey(certsKind, key, nil)
 	return d.client.Delete(ctx, k)
 }
+
+// NewSQLCertCache returns new sql database cache
+func NewSQLCertCache(store *models.SQLStore) *SQLCertCache {
+	return &SQLCertCache{
+		store: store,
+	}
+}
+
+// Get get cache data
+func (d *SQLCertCache) Get(ctx context.Context, key string) ([]byte, error) {
+	var data []byte
+	row := d.store.DB.QueryRowContext(ctx, d.store.Rebind("SELECT data FROM certs WHERE id = ?"), key)
+	if err := row.Scan(&data); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, autocert.ErrCacheMiss
+		}
+		return nil, err
+	}
+	return data, nil
+}
+
+// Put stores the data in the cache under the specified key.
+func (d *SQLCertCache) Put(ctx context.Context, key string, data []byte) error {
+	query := "INSERT INTO certs (id, data) VALUES (?, ?) ON CONFLICT (id) DO UPDATE SET data = excluded.data"
+	_, err := d.store.DB.ExecContext(ctx, d.store.Rebind(query), key, data)
+	return err
+}
+
+// Delete removes a certificate data from the cache under the specified key.
+func (d *SQLCertCache) Delete(ctx context.Context, key string) error {
+	_, err := d.store.DB.ExecContext(ctx, d.store.Rebind("DELETE FROM certs WHERE id = ?"), key)
+	return err
+}
@@ -1,9 +1,11 @@
 package web
 
 import (
+	"bytes"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
+	"io/ioutil"
 	"mime"
 	"net/http"
 	"net/url"
@@ -20,6 +22,7 @@ import (
 
 const (
 	postDataKey key = "Data"
+	rawBodyKey  key = "RawBody"
 )
 
 type key string
@@ -31,13 +34,14 @@ type Context struct {
 	Server *Server
 	Params map[string]string
 	Query  url.Values
+	body   []byte
 }
 
 // NewContext creates new instance of context
 func NewContext(w http.ResponseWriter, req *http.Request, s *Server) *Context {
 	p := mux.Vars(req)
 	sw := negroni.NewResponseWriter(w)
-	ctx := &Context{sw, req, s, p, req.URL.Query()}
+	ctx := &Context{sw, req, s, p, req.URL.Query(), nil}
 	return ctx
 }
 
@@ -186,6 +190,25 @@ func (ctx *Context) PostValues() *utils.Map {
 	return &p
 }
 
+// RawBody returns the raw request body, it's read by withPostData
+// or at the first call when the middleware is not used
+func (ctx *Context) RawBody() ([]byte, error) {
+	if b, ok := ctx.Request().Context().Value(rawBodyKey).([]byte); ok {
+		return b, nil
+	}
+	if ctx.body == nil {
+		b, err := ioutil.ReadAll(ctx.Request().Body)
+		ctx.Request().Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		ctx.body = b
+		// keep body readable for PostValues
+		ctx.Request().Body = ioutil.NopCloser(bytes.NewReader(b))
+	}
+	return ctx.body, nil
+}
+
 // GetBasicAuth returns the decoded user and password from the context's
 // 'Authorization' header.
 func (ctx *Context) GetBasicAuth() (string, string, error) {
@@ -103,3 +103,20 @@ func TestAuth(t *testing.T) {
 		assert.Equal(pwd, "password")
 	}
 }
+
+func TestRawBody(t *testing.T) {
+	assert := assert.New(t)
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(userJSON))
+	rec := httptest.NewRecorder()
+	c := NewContext(rec, req, srv)
+
+	body, err := c.RawBody()
+	assert.NoError(err)
+	assert.Equal(userJSON, string(body))
+
+	// body can be read again
+	body, err = c.RawBody()
+	assert.NoError(err)
+	assert.Equal(userJSON, string(body))
+	assert.Equal("Jon Snow", c.PostValues().Get("name", ""))
+}
@@ -3,6 +3,7 @@ package web
 import (
 	"context"
 	"encoding/json"
+	"io/ioutil"
 	"net/http"
 	"time"
 
@@ -12,18 +13,25 @@ import (
 	"github.com/urfave/negroni"
 )
 
-// withPostData injects post data into http.Request
+// withPostData injects post data and the raw body into http.Request,
+// the raw body is kept for verifying request signatures
 func withPostData(w http.ResponseWriter, r *http.Request, next http.HandlerFunc) {
 	var data utils.Map
+	var body []byte
 	defer r.Body.Close()
 	// form data
 	if r.Method == http.MethodPost {
-		err := json.NewDecoder(r.Body).Decode(&data)
+		var err error
+		body, err = ioutil.ReadAll(r.Body)
+		if err == nil {
+			err = json.Unmarshal(body, &data)
+		}
 		if err != nil {
 			logger.Error(err)
 		}
 	}
 	ctx := context.WithValue(r.Context(), postDataKey, data)
+	ctx = context.WithValue(ctx, rawBodyKey, body)
 	next(w, r.WithContext(ctx))
 }
 
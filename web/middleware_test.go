@@ -25,6 +25,11 @@ func TestWithPostData(t *testing.T) {
 		// c := NewContext(rec, req, s)
 		// p["data"] = c.PostValues()
 		buf.WriteString("0")
+		c := NewContext(w, r, srv)
+		body, err := c.RawBody()
+		assert.NoError(err)
+		assert.Equal(userJSON, string(body))
+		assert.Equal("Jon Snow", c.PostValues().Get("name", ""))
 	})
 
 	assert.Equal("0", buf.String())
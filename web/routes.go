@@ -8,4 +8,7 @@ func (s *Server) ConfigureRoute() {
 	s.Get("/search", searchAPI)
 	// add api urls
 	s.Get("/a/v1/facebook_users", facebookUsersView)
+	// admin urls
+	s.Admin("/admin/metrics", metricsView, "GET")
+	s.Admin("/admin/crawl_runs", crawlRunsView, "GET")
 }
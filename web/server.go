@@ -9,7 +9,6 @@ import (
 	"os/signal"
 	"time"
 
-	"github.com/epigos/newsbot/models"
 	"github.com/epigos/newsbot/utils"
 
 	"github.com/gorilla/mux"
@@ -28,6 +27,8 @@ type Server struct {
 	Mux    *mux.Router
 	n      *negroni.Negroni
 	Logger *utils.Logger
+	// shutdown functions called on graceful shutdown
+	shutdown []func()
 }
 
 // New creates a new server
@@ -57,7 +58,7 @@ func New(h string) *Server {
 func (s *Server) Run() {
 	host := s.Config.Get("host", nil).(string)
 
-	certcache := NewDatastoreCertCache(models.DS.Client)
+	certcache := newCertCache()
 
 	certManager := autocert.Manager{
 		Cache:      certcache,
@@ -113,13 +114,20 @@ func (s *Server) Run() {
 	// Doesn't block if no connections, but will otherwise wait
 	// until the timeout deadline.
 	srv.Shutdown(ctx)
-	// Optionally, you could run srv.Shutdown in a goroutine and block on
-	// <-ctx.Done() if your application should wait for other services
-	// to finalize based on context cancellation.
+	// let other services finish their work
+	for _, fn := range s.shutdown {
+		fn()
+	}
 	s.Logger.Info("shutting down")
 	os.Exit(0)
 }
 
+// OnShutdown registers fn to be called on graceful shutdown
+// after the server has stopped accepting requests
+func (s *Server) OnShutdown(fn func()) {
+	s.shutdown = append(s.shutdown, fn)
+}
+
 // Handle custom routes
 func (s *Server) Handle(path string, handler httpHandler, methods ...string) {
 	s.Mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
@@ -54,7 +54,7 @@ func TestMain(m *testing.M) {
 	if err != nil {
 		log.Fatal("Error loading .env file")
 	}
-	models.Connect()
+	models.UseStore(models.NewMemoryStore())
 
 	srv = New("0.0.0.0:5059")
 	m.Run()
@@ -16,7 +16,7 @@ func articleRedirectView(ctx *Context) *HTTPError {
 	articleID := ctx.GetParam("articleID")
 	userID := ctx.GetParam("userID")
 
-	akey := models.DS.DecodeKey(articleID)
+	akey := models.DecodeKey(articleID)
 
 	go func(uid, aid string) {
 		if article, err := models.GetArticle(aid); err == nil {